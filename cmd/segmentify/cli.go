@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"segmentify/internal/config"
+	"segmentify/internal/storage/postgres"
+)
+
+// Exit codes of the commands.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// dispatch runs the command named by the first argument.
+func dispatch(name string, commands []command, args []string) int {
+	usage := func(w io.Writer) {
+		fmt.Fprintf(w, "usage: %s <command> [flags] [args]\n\ncommands:\n", name)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, c := range commands {
+			fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+		}
+		tw.Flush()
+	}
+
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		usage(os.Stdout)
+		return exitOK
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", name, args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+// newFlagSet returns a flag set taking the configuration flags, and a
+// function loading the configuration once it is parsed. Positional arguments
+// of the command are described by argsUsage.
+func newFlagSet(name, argsUsage string) (*flag.FlagSet, func() (*config.Config, error)) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] %s\n\nflags:\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+
+	return fs, config.Flags(fs)
+}
+
+// parseArgs parses args and checks that exactly n positional arguments are
+// left, any number if n is negative. If ok is false, the command must exit
+// with code.
+func parseArgs(fs *flag.FlagSet, args []string, n int) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+
+	if n >= 0 && fs.NArg() != n {
+		fmt.Fprintf(fs.Output(), "expected %d argument(s), got %d\n", n, fs.NArg())
+		fs.Usage()
+		return exitUsage, false
+	}
+
+	return exitOK, true
+}
+
+// failed reports err and returns the failure exit code.
+func failed(err error) int {
+	fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	return exitFailure
+}
+
+// withStorage loads the configuration, connects to the database and calls f.
+// The context passed to f is cancelled on SIGINT and SIGTERM.
+func withStorage(load func() (*config.Config, error), f func(ctx context.Context, cfg *config.Config, storage *postgres.Storage) int) int {
+	cfg, err := load()
+	if err != nil {
+		return failed(fmt.Errorf("load config: %w", err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	storage, err := postgres.New(ctx, cfg.Postgres)
+	if err != nil {
+		return failed(fmt.Errorf("connect to storage: %w", err))
+	}
+	defer storage.Close()
+
+	return f(ctx, cfg, storage)
+}
+
+// cliLogger logs warnings and errors of the commands to stderr, keeping
+// stdout for their output.
+func cliLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+}
+
+// outputFlag defines the -o flag taking one of formats, the first being the
+// default.
+func outputFlag(fs *flag.FlagSet, formats ...string) *string {
+	format := formats[0]
+
+	fs.Func("o", fmt.Sprintf("output format: %s (default %q)", strings.Join(formats, ", "), formats[0]), func(value string) error {
+		if !slices.Contains(formats, value) {
+			return fmt.Errorf("unknown format %q", value)
+		}
+		format = value
+		return nil
+	})
+
+	return &format
+}
+
+// write prints v as JSON, or its rows under the header as a table.
+func write(w io.Writer, format string, v any, header []string, rows [][]string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
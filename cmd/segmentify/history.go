@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	"segmentify/internal/config"
+	"segmentify/internal/models"
+	"segmentify/internal/storage/postgres"
+)
+
+var historyCommands = []command{
+	{name: "export", summary: "export the segments history of a user for a month", run: exportHistory},
+}
+
+type historyEntry struct {
+	UserID    string `json:"user_id"`
+	Segment   string `json:"segment"`
+	Operation string `json:"operation"`
+	CreatedAt string `json:"created_at"`
+}
+
+func exportHistory(args []string) int {
+	fs, load := newFlagSet("segmentify history export", "")
+	project := fs.String("project", models.DefaultProject, "project slug")
+	user := fs.Int64("user", 0, "user ID")
+	period := fs.String("period", time.Now().Format("2006-01"), "year and month, e.g. 2023-09")
+	output := outputFlag(fs, "csv", "table", "json")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	month, err := time.Parse("2006-01", *period)
+	if err != nil {
+		fmt.Fprintf(fs.Output(), "invalid -period %q, expected yyyy-mm\n", *period)
+		return exitUsage
+	}
+	if *user == 0 {
+		fmt.Fprintln(fs.Output(), "-user is required")
+		return exitUsage
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		report, err := storage.GetUserSegmentsHistory(ctx, *project, *user, month)
+		if err != nil {
+			return failed(err)
+		}
+
+		if *output == "csv" {
+			w := csv.NewWriter(os.Stdout)
+			if err := w.WriteAll(report); err != nil {
+				return failed(err)
+			}
+			return exitOK
+		}
+
+		entries := make([]historyEntry, 0, len(report))
+		for _, row := range report {
+			entries = append(entries, historyEntry{UserID: row[0], Segment: row[1], Operation: row[2], CreatedAt: row[3]})
+		}
+
+		if err := write(os.Stdout, *output, entries, []string{"USER ID", "SEGMENT", "OPERATION", "CREATED AT"}, report); err != nil {
+			return failed(err)
+		}
+
+		return exitOK
+	})
+}
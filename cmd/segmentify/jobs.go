@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"segmentify/internal/config"
+	"segmentify/internal/models"
+	"segmentify/internal/scheduler"
+	"segmentify/internal/storage/postgres"
+)
+
+// jobAliases are the short names the jobs commands accept.
+var jobAliases = map[string]string{
+	"expire":       "expire_users_segments",
+	"prune-outbox": "prune_outbox",
+}
+
+var jobsCommands = []command{
+	{name: "list", summary: "list jobs with their schedules and last runs", run: listJobs},
+	{name: "run", summary: "run a job now, e.g. expire", run: runJob},
+}
+
+// jobSchedules returns the schedules of the jobs by their names.
+func jobSchedules(cfg *config.Config) map[string]string {
+	return map[string]string{
+		"expire_users_segments": cfg.Scheduler.ExpireUsersSegments,
+		"prune_outbox":          cfg.Scheduler.PruneOutbox,
+	}
+}
+
+// newScheduler returns a scheduler with the background jobs registered.
+func newScheduler(log *slog.Logger, storage *postgres.Storage, cfg *config.Config) (*scheduler.Scheduler, error) {
+	jobs := scheduler.New(log, storage)
+	schedules := jobSchedules(cfg)
+
+	if err := jobs.Register(
+		"expire_users_segments",
+		schedules["expire_users_segments"],
+		storage.DeleteExpiredUsersSegments,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := jobs.Register(
+		"prune_outbox",
+		schedules["prune_outbox"],
+		func(ctx context.Context) (int64, error) {
+			return storage.PruneOutbox(ctx, time.Now().Add(-cfg.Outbox.Retention))
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func listJobs(args []string) int {
+	fs, load := newFlagSet("segmentify jobs list", "")
+	output := outputFlag(fs, "table", "json")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	return withStorage(load, func(ctx context.Context, cfg *config.Config, storage *postgres.Storage) int {
+		jobs, err := newScheduler(cliLogger(), storage, cfg)
+		if err != nil {
+			return failed(err)
+		}
+
+		list, err := jobs.Jobs(ctx)
+		if err != nil {
+			return failed(err)
+		}
+
+		rows := [][]string{}
+		for _, job := range list {
+			lastRun, lastError := "", ""
+			if job.LastRun != nil {
+				lastRun = job.LastRun.StartedAt.Format(time.RFC3339)
+				lastError = job.LastRun.Error
+			}
+			rows = append(rows, []string{job.Name, job.Schedule, job.NextRun.Format(time.RFC3339), lastRun, lastError})
+		}
+
+		if err := write(os.Stdout, *output, list, []string{"NAME", "SCHEDULE", "NEXT RUN", "LAST RUN", "LAST ERROR"}, rows); err != nil {
+			return failed(err)
+		}
+
+		return exitOK
+	})
+}
+
+func runJob(args []string) int {
+	fs, load := newFlagSet("segmentify jobs run", "JOB")
+	output := outputFlag(fs, "table", "json")
+	if code, ok := parseArgs(fs, args, 1); !ok {
+		return code
+	}
+
+	name := fs.Arg(0)
+	if alias, ok := jobAliases[name]; ok {
+		name = alias
+	}
+
+	return withStorage(load, func(ctx context.Context, cfg *config.Config, storage *postgres.Storage) int {
+		jobs, err := newScheduler(cliLogger(), storage, cfg)
+		if err != nil {
+			return failed(err)
+		}
+
+		run, err := jobs.Trigger(ctx, name)
+		if err != nil {
+			return failed(err)
+		}
+
+		if err := write(os.Stdout, *output, run, []string{"JOB", "ROWS AFFECTED", "DURATION", "ERROR"}, [][]string{{
+			run.JobName,
+			strconv.FormatInt(run.RowsAffected, 10),
+			runDuration(run).String(),
+			run.Error,
+		}}); err != nil {
+			return failed(err)
+		}
+
+		if run.Error != "" {
+			return exitFailure
+		}
+
+		return exitOK
+	})
+}
+
+func runDuration(run models.JobRun) time.Duration {
+	if run.FinishedAt == nil {
+		return 0
+	}
+
+	return run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond)
+}
@@ -1,154 +1,70 @@
 package main
 
 import (
-	"context"
-	"log/slog"
-	"net/http"
+	"fmt"
 	"os"
-	"time"
-
-	"segmentify/internal/config"
-	createSegment "segmentify/internal/httpserver/handlers/segments/create"
-	deleteSegment "segmentify/internal/httpserver/handlers/segments/delete"
-	getSegment "segmentify/internal/httpserver/handlers/segments/get"
-	createUser "segmentify/internal/httpserver/handlers/users/create"
-	getUserSegments "segmentify/internal/httpserver/handlers/users/get"
-	downloadUserSegmentsHistory "segmentify/internal/httpserver/handlers/users/gethistory"
-	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
-	mwLogger "segmentify/internal/httpserver/middleware/logger"
-	"segmentify/internal/lib/logger/sl"
-	"segmentify/internal/storage/postgres"
+	"strings"
 
 	_ "segmentify/docs"
 
 	_ "github.com/swaggo/swag"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	httpSwagger "github.com/swaggo/http-swagger/v2"
-)
-
-const (
-	envTest = "test"
-	envDev  = "dev"
-	envProd = "prod"
 )
 
-// @title			Segmentify
-// @description	Dynamic user segmentation service
+// @title						Segmentify
+// @description				Dynamic user segmentation service
+// @securityDefinitions.apikey	ApiKeyAuth
+// @in							header
+// @name						X-API-Key
 func main() {
-	cfg := config.MustLoad()
-
-	log := setupLogger(cfg.Env)
-
-	log.Info("starting segmentify", slog.String("env", cfg.Env))
-	log.Debug("debug messages are enabled")
-
-	ctx := context.Background()
-
-	storage, err := postgres.New(ctx, cfg.PostgresURL)
-	if err != nil {
-		log.Error("failed to start storage", sl.Err(err))
-		os.Exit(1)
-	}
-	defer storage.Close()
+	os.Exit(run(os.Args[1:]))
+}
 
-	if err := storage.Init(ctx); err != nil {
-		log.Error("failed to init storage", sl.Err(err))
-		os.Exit(1)
+// run executes the command named by the first argument. Without one, or if
+// the arguments start with flags, the service is started as before
+// subcommands existed.
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serve(args)
 	}
 
-	router := chi.NewRouter()
-
-	router.Use(
-		middleware.RequestID,
-		middleware.Logger,
-		mwLogger.New(log),
-		middleware.Recoverer,
-	)
-
-	router.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("/swagger/doc.json"),
-	))
-
-	router.Route("/segments", func(r chi.Router) {
-		r.Post("/", createSegment.New(ctx, log, storage))
-		r.Delete("/{slug}", deleteSegment.New(ctx, log, storage))
-		r.Get("/{slug}", getSegment.New(ctx, log, storage))
-	})
-
-	router.Route("/users", func(r chi.Router) {
-		r.Post("/", createUser.New(ctx, log, storage))
-		r.Get("/{id}/segments", getUserSegments.New(ctx, log, storage))
-		r.Get("/{id}/download-segments-history", downloadUserSegmentsHistory.New(ctx, log, storage))
-		r.Patch("/{id}/segments", updateUserSegments.New(ctx, log, storage))
-
-	})
+	return dispatch("segmentify", commands, args)
+}
 
-	log.Info("starting server", slog.String("address", cfg.Address))
+var commands = []command{
+	{name: "serve", summary: "run the service", run: serve},
+	{name: "migrate", summary: "apply database migrations", run: migrate},
+	{name: "config", summary: "print the effective configuration", run: printConfig},
+	{name: "segments", summary: "list, create and delete segments", run: func(args []string) int {
+		return dispatch("segmentify segments", segmentsCommands, args)
+	}},
+	{name: "users", summary: "import users", run: func(args []string) int {
+		return dispatch("segmentify users", usersCommands, args)
+	}},
+	{name: "history", summary: "export user segments history", run: func(args []string) int {
+		return dispatch("segmentify history", historyCommands, args)
+	}},
+	{name: "snapshot", summary: "export and import all data", run: func(args []string) int {
+		return dispatch("segmentify snapshot", snapshotCommands, args)
+	}},
+	{name: "jobs", summary: "list and run background jobs", run: func(args []string) int {
+		return dispatch("segmentify jobs", jobsCommands, args)
+	}},
+}
 
-	doneServer := make(chan os.Signal, 1)
-	server := &http.Server{
-		Addr:         cfg.HTTPServer.Address,
-		Handler:      router,
-		ReadTimeout:  cfg.HTTPServer.Timeout,
-		WriteTimeout: cfg.HTTPServer.Timeout,
-		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+func printConfig(args []string) int {
+	fs, load := newFlagSet("segmentify config", "")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
 	}
 
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			log.Error("failed to start server")
-		}
-	}()
-
-	log.Info("server started")
-
-	go startScheduler(ctx, log, storage)
-
-	<-doneServer
-	log.Info("stopping server")
-
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	if err := server.Shutdown(ctx); err != nil {
-		log.Error("failed to stop server", sl.Err(err))
-		return
+	cfg, err := load()
+	if err != nil {
+		return failed(fmt.Errorf("load config: %w", err))
 	}
 
-	log.Info("server stopped")
-}
-
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
-
-	switch env {
-	case envTest, envDev:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-		)
-	case envProd:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
-	default: // If env config is invalid, set prod settings by default due to security
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
+	if err := cfg.Dump(os.Stdout); err != nil {
+		return failed(fmt.Errorf("print config: %w", err))
 	}
 
-	return log
-}
-
-func startScheduler(ctx context.Context, log *slog.Logger, storage *postgres.Storage) {
-	for {
-		rowsAffected, err := storage.DeleteExpiredUsersSegments(ctx)
-		if err != nil {
-			log.Error("failed to run a job", sl.Err(err))
-		} else {
-			log.Info("job completed", slog.Int64("rowsAffected", rowsAffected))
-		}
-		time.Sleep(time.Hour)
-	}
+	return exitOK
 }
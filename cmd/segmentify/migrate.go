@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"segmentify/internal/config"
+	"segmentify/internal/storage/postgres"
+)
+
+// migrate applies the schema and checks that every table exists.
+func migrate(args []string) int {
+	fs, load := newFlagSet("segmentify migrate", "")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		if err := storage.Init(ctx); err != nil {
+			return failed(err)
+		}
+
+		if err := storage.CheckSchema(ctx); err != nil {
+			return failed(err)
+		}
+
+		fmt.Println("schema is up to date")
+
+		return exitOK
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"segmentify/internal/config"
+	"segmentify/internal/models"
+	"segmentify/internal/storage/postgres"
+)
+
+var segmentsCommands = []command{
+	{name: "list", summary: "list segments of a project", run: listSegments},
+	{name: "create", summary: "create a segment", run: createSegment},
+	{name: "delete", summary: "delete a segment", run: deleteSegment},
+}
+
+var segmentsHeader = []string{"SLUG", "PERCENT"}
+
+func segmentRows(segments []models.Segment) [][]string {
+	rows := make([][]string, 0, len(segments))
+	for _, segment := range segments {
+		rows = append(rows, []string{segment.Slug, strconv.FormatInt(segment.Percent, 10)})
+	}
+
+	return rows
+}
+
+func listSegments(args []string) int {
+	fs, load := newFlagSet("segmentify segments list", "")
+	project := fs.String("project", models.DefaultProject, "project slug")
+	output := outputFlag(fs, "table", "json")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		segments, err := storage.ListSegments(ctx, *project)
+		if err != nil {
+			return failed(err)
+		}
+
+		if err := write(os.Stdout, *output, segments, segmentsHeader, segmentRows(segments)); err != nil {
+			return failed(err)
+		}
+
+		return exitOK
+	})
+}
+
+func createSegment(args []string) int {
+	fs, load := newFlagSet("segmentify segments create", "SLUG")
+	project := fs.String("project", models.DefaultProject, "project slug")
+	percent := fs.Int64("percent", 0, "percentage of users to add to the segment")
+	output := outputFlag(fs, "table", "json")
+	if code, ok := parseArgs(fs, args, 1); !ok {
+		return code
+	}
+
+	if *percent < 0 || *percent > 100 {
+		fmt.Fprintln(fs.Output(), "-percent must be between 0 and 100")
+		return exitUsage
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		segment, err := storage.CreateSegment(ctx, *project, models.Segment{Slug: fs.Arg(0), Percent: *percent})
+		if err != nil {
+			return failed(err)
+		}
+
+		segments := []models.Segment{segment}
+		if err := write(os.Stdout, *output, segment, segmentsHeader, segmentRows(segments)); err != nil {
+			return failed(err)
+		}
+
+		return exitOK
+	})
+}
+
+func deleteSegment(args []string) int {
+	fs, load := newFlagSet("segmentify segments delete", "SLUG")
+	project := fs.String("project", models.DefaultProject, "project slug")
+	if code, ok := parseArgs(fs, args, 1); !ok {
+		return code
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		if err := storage.DeleteSegment(ctx, *project, fs.Arg(0)); err != nil {
+			return failed(err)
+		}
+
+		return exitOK
+	})
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/cache"
+	"segmentify/internal/config"
+	"segmentify/internal/consistency"
+	"segmentify/internal/events"
+	"segmentify/internal/grpcserver"
+	"segmentify/internal/health"
+	"segmentify/internal/httpserver"
+	httprouter "segmentify/internal/httpserver/router"
+	"segmentify/internal/lib/logger/handlers/slogtrace"
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/metrics"
+	"segmentify/internal/models"
+	"segmentify/internal/outbox"
+	"segmentify/internal/ratelimit"
+	"segmentify/internal/reload"
+	"segmentify/internal/scheduler"
+	"segmentify/internal/storage/postgres"
+	"segmentify/internal/tracing"
+	"segmentify/internal/webhooks"
+)
+
+// serve runs the service until it is interrupted.
+func serve(args []string) int {
+	fs, load := newFlagSet("segmentify serve", "")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return failed(fmt.Errorf("load config: %w", err))
+	}
+
+	// The level is reloadable, so it is kept apart from the logger
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.LogLevel())
+
+	log := setupLogger(logLevel)
+
+	log.Info("starting segmentify", slog.String("env", cfg.Env))
+	log.Debug("debug messages are enabled")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Error("failed to set up tracing", sl.Err(err))
+		return exitFailure
+	}
+	defer func() {
+		// ctx is already cancelled here, flush with a fresh deadline
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to flush traces", sl.Err(err))
+		}
+	}()
+
+	storage, err := postgres.New(ctx, cfg.Postgres)
+	if err != nil {
+		log.Error("failed to start storage", sl.Err(err))
+		return exitFailure
+	}
+	defer storage.Close()
+
+	if err := storage.Init(ctx); err != nil {
+		log.Error("failed to init storage", sl.Err(err))
+		return exitFailure
+	}
+
+	if err := storage.ConnectReplicas(ctx, cfg.Replicas.URLs, cfg.Replicas.MaxLag); err != nil {
+		log.Error("failed to connect read replicas", sl.Err(err))
+		return exitFailure
+	}
+
+	if cfg.Auth.AdminKey != "" {
+		if err := storage.EnsureAPIKey(ctx, models.APIKey{
+			Name:   "bootstrap",
+			Prefix: auth.Prefix(cfg.Auth.AdminKey),
+			Role:   auth.RoleAdmin,
+		}, auth.Hash(cfg.Auth.AdminKey)); err != nil {
+			log.Error("failed to seed admin api key", sl.Err(err))
+			return exitFailure
+		}
+	}
+
+	jobs, err := newScheduler(log, storage, cfg)
+	if err != nil {
+		log.Error("failed to register jobs", sl.Err(err))
+		return exitFailure
+	}
+
+	publisher, closePublisher, err := outbox.NewPublisher(cfg.Outbox)
+	if err != nil {
+		log.Error("failed to set up outbox publisher", sl.Err(err))
+		return exitFailure
+	}
+	defer closePublisher()
+
+	stats := metrics.New()
+	stats.Register(
+		metrics.NewPoolCollector(storage),
+		metrics.NewSegmentsCollector(storage),
+	)
+
+	if cfg.Cache.Enabled {
+		userSegments := cache.New(cache.NewLRU(cfg.Cache.Size), cfg.Cache.TTL)
+		storage.SetUserSegmentsCache(userSegments)
+		stats.Register(metrics.NewCacheCollector(userSegments))
+	}
+	jobs.OnRun(stats.ObserveJobRun)
+
+	probes := health.New()
+	probes.Add("postgres", storage.Ping)
+	probes.Add("migrations", storage.CheckSchema)
+	probes.Add("scheduler", jobs.CheckRunning)
+
+	limits, routeLimits, err := ratelimit.ParseConfig(cfg.RateLimit)
+	if err != nil {
+		log.Error("failed to parse rate limits", sl.Err(err))
+		return exitFailure
+	}
+	limiter := ratelimit.New(limits, routeLimits)
+
+	addressLimit, err := ratelimit.ParseAddressLimit(cfg.RateLimit)
+	if err != nil {
+		log.Error("failed to parse rate limits", sl.Err(err))
+		return exitFailure
+	}
+	addressLimiter := ratelimit.New(addressLimit, nil)
+
+	hub := events.New(log, storage, cfg.Events)
+
+	writers := consistency.FromConfig(cfg.Replicas)
+
+	reloader := reload.New(log, cfg, load, storage)
+	reloader.Add(func(cfg *config.Config) (func(), error) {
+		return func() { logLevel.Set(cfg.LogLevel()) }, nil
+	})
+	reloader.Add(func(cfg *config.Config) (func(), error) {
+		limits, routeLimits, err := ratelimit.ParseConfig(cfg.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		addressLimit, err := ratelimit.ParseAddressLimit(cfg.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			limiter.SetLimits(limits, routeLimits)
+			addressLimiter.SetLimits(addressLimit, nil)
+		}, nil
+	})
+	reloader.Add(func(cfg *config.Config) (func(), error) {
+		schedules := jobSchedules(cfg)
+		for name, spec := range schedules {
+			if _, err := scheduler.ParseSchedule(spec); err != nil {
+				return nil, fmt.Errorf("job %s: %w", name, err)
+			}
+		}
+		return func() {
+			for name, spec := range schedules {
+				if err := jobs.Reschedule(name, spec); err != nil {
+					log.Error("failed to reschedule job", sl.Err(err))
+				}
+			}
+		}, nil
+	})
+
+	router := httprouter.New(log, cfg, httprouter.Dependencies{
+		Storage:        storage,
+		Jobs:           jobs,
+		Probes:         probes,
+		Metrics:        stats,
+		Limiter:        limiter,
+		AddressLimiter: addressLimiter,
+		Events:         hub,
+		Reloader:       reloader,
+		Writers:        writers,
+	})
+
+	log.Info("starting server", slog.String("address", cfg.HTTPServer.Address))
+
+	server := httpserver.New(log, cfg.HTTPServer, router)
+	server.OnShutdown(probes.Shutdown)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		jobs.Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		webhooks.New(log, storage, cfg.Webhooks).Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outbox.New(log, storage, publisher, cfg.Outbox).Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hub.Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		storage.RunReplicaChecks(ctx, log, cfg.Replicas.CheckInterval)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reloader.Run(ctx, cfg.Reload.WatchInterval)
+	}()
+
+	if cfg.GRPCServer.Address != "" {
+		var limiters grpcserver.Limiters
+		if cfg.RateLimit.Enabled {
+			limiters = grpcserver.Limiters{Address: addressLimiter, Client: limiter}
+		}
+		grpcServer := grpcserver.New(log, cfg.GRPCServer.Address, cfg.HTTPServer.ShutdownTimeout, storage, cfg.Auth.Enabled, limiters, writers)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := grpcServer.Run(ctx); err != nil {
+				log.Error("failed to run grpc server", sl.Err(err))
+				stop()
+			}
+		}()
+	}
+
+	code := exitOK
+	if err := server.Run(ctx); err != nil {
+		log.Error("failed to run server", sl.Err(err))
+		code = exitFailure
+	}
+
+	// Stop the scheduler even if the server failed on its own
+	stop()
+	wg.Wait()
+
+	return code
+}
+
+func setupLogger(level slog.Leveler) *slog.Logger {
+	return slog.New(
+		slogtrace.NewTraceHandler(
+			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}),
+		),
+	)
+}
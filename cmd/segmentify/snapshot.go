@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"segmentify/internal/config"
+	"segmentify/internal/snapshot"
+	"segmentify/internal/storage/postgres"
+)
+
+var snapshotCommands = []command{
+	{name: "export", summary: "export all data to a snapshot archive", run: exportSnapshot},
+	{name: "import", summary: "import a snapshot archive", run: importSnapshot},
+}
+
+func exportSnapshot(args []string) int {
+	fs, load := newFlagSet("segmentify snapshot export", "")
+	file := fs.String("file", "-", "archive to write, - for stdout")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		var out io.Writer = os.Stdout
+		var f *os.File
+		if *file != "-" {
+			var err error
+			if f, err = os.Create(*file); err != nil {
+				return failed(err)
+			}
+			out = f
+		}
+
+		manifest, err := storage.ExportSnapshot(ctx, out)
+		if f != nil {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			// A partial archive is of no use
+			if err != nil {
+				os.Remove(*file)
+			}
+		}
+		if err != nil {
+			return failed(err)
+		}
+
+		for _, table := range snapshot.Tables {
+			fmt.Fprintf(os.Stderr, "%s: %d rows\n", table, manifest.Counts[table])
+		}
+
+		return exitOK
+	})
+}
+
+func importSnapshot(args []string) int {
+	fs, load := newFlagSet("segmentify snapshot import", "")
+	file := fs.String("file", "-", "archive to read, - for stdin")
+	modeFlag := fs.String("mode", string(snapshot.ModeUpsert), "upsert or replace")
+	output := outputFlag(fs, "table", "json")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	mode, ok := snapshot.ParseMode(*modeFlag)
+	if !ok {
+		fmt.Fprintf(fs.Output(), "invalid -mode %q, expected upsert or replace\n", *modeFlag)
+		return exitUsage
+	}
+
+	archive, closeArchive, err := openSnapshot(*file)
+	if err != nil {
+		return failed(err)
+	}
+	defer closeArchive()
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		res, err := storage.ImportSnapshot(ctx, archive, mode)
+		if err != nil {
+			return failed(err)
+		}
+
+		rows := [][]string{}
+		for _, table := range snapshot.Tables {
+			t := res.Tables[table]
+			rows = append(rows, []string{
+				table,
+				strconv.FormatInt(t.Inserted, 10),
+				strconv.FormatInt(t.Updated, 10),
+				strconv.FormatInt(t.Deleted, 10),
+			})
+		}
+		if err := write(os.Stdout, *output, res, []string{"TABLE", "INSERTED", "UPDATED", "DELETED"}, rows); err != nil {
+			return failed(err)
+		}
+
+		// Conflicts follow the counts in a table of their own
+		if *output == "table" && len(res.Conflicts) > 0 {
+			rows := [][]string{}
+			for _, c := range res.Conflicts {
+				rows = append(rows, []string{c.Table, c.Key, c.Resolution, c.Detail})
+			}
+			fmt.Fprintln(os.Stdout)
+			if err := write(os.Stdout, *output, nil, []string{"CONFLICT", "KEY", "RESOLUTION", "DETAIL"}, rows); err != nil {
+				return failed(err)
+			}
+		}
+
+		if res.Skipped() {
+			return exitFailure
+		}
+
+		return exitOK
+	})
+}
+
+// openSnapshot opens the archive in the file, or in stdin if it is "-". A
+// zip archive is read from its end, so stdin is buffered in a temporary file
+// first. The rows are read from the file during the import, so it is closed
+// by the returned func.
+func openSnapshot(file string) (*snapshot.Archive, func(), error) {
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+
+		archive, err := snapshot.Open(f, info.Size())
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+
+		return archive, func() { f.Close() }, nil
+	}
+
+	f, err := os.CreateTemp("", "segmentify-snapshot-*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFile := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	size, err := io.Copy(f, os.Stdin)
+	if err != nil {
+		closeFile()
+		return nil, nil, err
+	}
+
+	archive, err := snapshot.Open(f, size)
+	if err != nil {
+		closeFile()
+		return nil, nil, err
+	}
+
+	return archive, closeFile, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"segmentify/internal/config"
+	"segmentify/internal/models"
+	"segmentify/internal/storage/postgres"
+)
+
+var usersCommands = []command{
+	{name: "import", summary: "import users and their segments from CSV", run: importUsers},
+}
+
+// importRow is a user to import along with the segments to add the user to.
+type importRow struct {
+	Line     int
+	ID       int64
+	Segments []string
+}
+
+type importResult struct {
+	Users         int64         `json:"users"`
+	Created       int64         `json:"created"`
+	SegmentsAdded int64         `json:"segments_added"`
+	Errors        []importError `json:"errors"`
+}
+
+type importError struct {
+	Line  int    `json:"line"`
+	ID    int64  `json:"user_id"`
+	Error string `json:"error"`
+}
+
+// readImport reads CSV rows of a user ID followed by segment slugs. A first
+// row not starting with an ID is taken for a header and skipped.
+func readImport(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	rows := []importRow{}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		id, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil || id <= 0 {
+			if line == 1 && err != nil {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid user id %q", line, record[0])
+		}
+
+		row := importRow{Line: line, ID: id, Segments: []string{}}
+		for _, slug := range record[1:] {
+			if slug = strings.TrimSpace(slug); slug != "" && !slices.Contains(row.Segments, slug) {
+				row.Segments = append(row.Segments, slug)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func importUsers(args []string) int {
+	fs, load := newFlagSet("segmentify users import", "")
+	project := fs.String("project", models.DefaultProject, "project slug")
+	file := fs.String("file", "-", "CSV file of user IDs followed by segment slugs, - for stdin")
+	output := outputFlag(fs, "table", "json")
+	if code, ok := parseArgs(fs, args, 0); !ok {
+		return code
+	}
+
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return failed(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	rows, err := readImport(in)
+	if err != nil {
+		return failed(fmt.Errorf("read %s: %w", *file, err))
+	}
+
+	return withStorage(load, func(ctx context.Context, _ *config.Config, storage *postgres.Storage) int {
+		ids := make([]int64, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+
+		created, err := storage.ImportUsers(ctx, *project, ids)
+		if err != nil {
+			return failed(err)
+		}
+
+		result := importResult{Users: int64(len(rows)), Created: created, Errors: []importError{}}
+
+		// Only the segments a user is missing are added, so the import can be rerun
+		for _, row := range rows {
+			if len(row.Segments) == 0 {
+				continue
+			}
+
+			current, err := storage.GetUserSegments(ctx, *project, row.ID)
+			if err != nil {
+				result.Errors = append(result.Errors, importError{Line: row.Line, ID: row.ID, Error: err.Error()})
+				continue
+			}
+
+			toAdd := []models.SegmentToAdd{}
+			for _, slug := range row.Segments {
+				if !slices.Contains(current, slug) {
+					toAdd = append(toAdd, models.SegmentToAdd{Slug: slug})
+				}
+			}
+			if len(toAdd) == 0 {
+				continue
+			}
+
+			if err := storage.UpdateUserSegments(ctx, *project, row.ID, toAdd, nil); err != nil {
+				result.Errors = append(result.Errors, importError{Line: row.Line, ID: row.ID, Error: err.Error()})
+				continue
+			}
+			result.SegmentsAdded += int64(len(toAdd))
+		}
+
+		if *output == "table" {
+			for _, e := range result.Errors {
+				fmt.Fprintf(os.Stderr, "line %d: user %d: %s\n", e.Line, e.ID, e.Error)
+			}
+		}
+
+		if err := write(os.Stdout, *output, result, []string{"USERS", "CREATED", "SEGMENTS ADDED", "ERRORS"}, [][]string{{
+			strconv.FormatInt(result.Users, 10),
+			strconv.FormatInt(result.Created, 10),
+			strconv.FormatInt(result.SegmentsAdded, 10),
+			strconv.Itoa(len(result.Errors)),
+		}}); err != nil {
+			return failed(err)
+		}
+
+		if len(result.Errors) > 0 {
+			return exitFailure
+		}
+
+		return exitOK
+	})
+}
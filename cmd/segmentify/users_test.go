@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadImport(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		rows  []importRow
+		err   string
+	}{
+		{
+			name:  "Header and segments",
+			input: "user_id,segments\n1,A,B\n2\n3, C ,C,\n",
+			rows: []importRow{
+				{Line: 2, ID: 1, Segments: []string{"A", "B"}},
+				{Line: 3, ID: 2, Segments: []string{}},
+				{Line: 4, ID: 3, Segments: []string{"C"}},
+			},
+		},
+		{
+			name:  "Without header",
+			input: "7,A\n",
+			rows:  []importRow{{Line: 1, ID: 7, Segments: []string{"A"}}},
+		},
+		{
+			name:  "Empty",
+			input: "",
+			rows:  []importRow{},
+		},
+		{
+			name:  "Invalid id",
+			input: "1\nx,A\n",
+			err:   `line 2: invalid user id "x"`,
+		},
+		{
+			name:  "Non-positive id",
+			input: "0\n",
+			err:   `line 1: invalid user id "0"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := readImport(strings.NewReader(tc.input))
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.rows, rows)
+		})
+	}
+}
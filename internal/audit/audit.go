@@ -0,0 +1,47 @@
+package audit
+
+import "context"
+
+const (
+	ActionCreate = "create"
+	ActionDelete = "delete"
+	ActionRevoke = "revoke"
+	ActionImport = "import"
+	ActionReload = "reload"
+)
+
+const (
+	EntityProject  = "project"
+	EntitySegment  = "segment"
+	EntityAPIKey   = "api_key"
+	EntityWebhook  = "webhook"
+	EntitySnapshot = "snapshot"
+	EntityConfig   = "config"
+)
+
+// SystemActor is recorded for changes made outside of an HTTP request, e.g.
+// by the bootstrap code on startup.
+const SystemActor = "system"
+
+// Actor identifies who made a change. APIKeyID is zero when the change was
+// not authenticated with a stored key.
+type Actor struct {
+	Name      string
+	APIKeyID  int64
+	RequestID string
+}
+
+type ctxKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, ctxKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, or SystemActor if there
+// is none.
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(ctxKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: SystemActor}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Cache keeps the segments of users by project and user ID. It is the
+// extension point for external caches; LRU is the in-process one.
+// Implementations must be safe for concurrent use. Lookups that fail are
+// served from storage, failed deletes leave entries until their TTL.
+type Cache interface {
+	Get(ctx context.Context, project string, userID int64) ([]string, bool, error)
+	Set(ctx context.Context, project string, userID int64, segments []string, ttl time.Duration) error
+	Delete(ctx context.Context, project string, userID int64) error
+	// DeleteProject deletes the entries of all users of the project.
+	DeleteProject(ctx context.Context, project string) error
+}
+
+// Loader reads the segments of a user from storage. expireAt is when the
+// earliest of the memberships expires, zero if none of them does.
+type Loader func(ctx context.Context) (segments []string, expireAt time.Time, err error)
+
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	Errors uint64
+}
+
+// UserSegments reads user segments through a cache. A nil *UserSegments is
+// valid and reads from storage every time, so storage can call it whether
+// the cache is enabled or not.
+type UserSegments struct {
+	cache Cache
+	ttl   time.Duration
+
+	// epoch orders fills against invalidations: a fill is dropped if
+	// anything was invalidated while its segments were read or stored, as
+	// they may be older than the invalidating change. Cache I/O is never
+	// done under a lock, so a slow backend doesn't serialize requests.
+	epoch atomic.Uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+func New(cache Cache, ttl time.Duration) *UserSegments {
+	return &UserSegments{cache: cache, ttl: ttl}
+}
+
+// Get returns the cached segments of the user or loads and caches them.
+// Entries never outlive the earliest expiry of the memberships they hold.
+func (c *UserSegments) Get(ctx context.Context, project string, userID int64, load Loader) ([]string, error) {
+	if c == nil {
+		segments, _, err := load(ctx)
+		return segments, err
+	}
+
+	segments, ok, err := c.cache.Get(ctx, project, userID)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	if ok {
+		c.hits.Add(1)
+		return slices.Clone(segments), nil
+	}
+	c.misses.Add(1)
+
+	epoch := c.epoch.Load()
+
+	segments, expireAt, err := load(ctx)
+	if err != nil {
+		return segments, err
+	}
+
+	ttl := c.ttl
+	if !expireAt.IsZero() {
+		ttl = min(ttl, time.Until(expireAt))
+	}
+	if ttl <= 0 {
+		return segments, nil
+	}
+
+	if c.epoch.Load() != epoch {
+		return segments, nil
+	}
+	if err := c.cache.Set(ctx, project, userID, slices.Clone(segments), ttl); err != nil {
+		c.errors.Add(1)
+		return segments, nil
+	}
+
+	// An invalidation that raced with the Set may have deleted before the
+	// entry landed; delete it again, as the invalidation won't
+	if c.epoch.Load() != epoch {
+		if err := c.cache.Delete(ctx, project, userID); err != nil {
+			c.errors.Add(1)
+		}
+	}
+
+	return segments, nil
+}
+
+// InvalidateUser drops the cached segments of the user. It is called after
+// the segments of the user changed.
+func (c *UserSegments) InvalidateUser(ctx context.Context, project string, userID int64) {
+	c.invalidate(func() error {
+		return c.cache.Delete(ctx, project, userID)
+	})
+}
+
+// InvalidateProject drops the cached segments of all users of the project.
+func (c *UserSegments) InvalidateProject(ctx context.Context, project string) {
+	c.invalidate(func() error {
+		return c.cache.DeleteProject(ctx, project)
+	})
+}
+
+func (c *UserSegments) invalidate(drop func() error) {
+	if c == nil {
+		return
+	}
+
+	// The epoch is bumped before the drop, so fills that store after the
+	// drop see it and delete their entry
+	c.epoch.Add(1)
+	if err := drop(); err != nil {
+		c.errors.Add(1)
+	}
+}
+
+func (c *UserSegments) Stats() Stats {
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Errors: c.errors.Load(),
+	}
+}
@@ -0,0 +1,186 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/cache"
+)
+
+// loader counts loads and returns the given segments and expiry.
+type loader struct {
+	loads    int
+	segments []string
+	expireAt time.Time
+	// during is called while loading, to change things in between.
+	during func()
+}
+
+func (l *loader) load(context.Context) ([]string, time.Time, error) {
+	l.loads++
+	if l.during != nil {
+		l.during()
+	}
+	return l.segments, l.expireAt, nil
+}
+
+// hooked calls beforeSet before storing an entry, to change things while
+// a fill is stored.
+type hooked struct {
+	cache.Cache
+	beforeSet func()
+}
+
+func (h *hooked) Set(ctx context.Context, project string, userID int64, segments []string, ttl time.Duration) error {
+	if h.beforeSet != nil {
+		h.beforeSet()
+	}
+	return h.Cache.Set(ctx, project, userID, segments, ttl)
+}
+
+func TestUserSegments(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		name       string
+		expireAt   time.Duration
+		invalidate func(c *cache.UserSegments)
+		// during invalidates while the first load runs.
+		during func(c *cache.UserSegments)
+		// storing invalidates while the first load is stored.
+		storing   func(c *cache.UserSegments)
+		wantLoads int
+	}{
+		{
+			name:      "Second Lookup Hits",
+			wantLoads: 1,
+		},
+		{
+			name: "Invalidated User",
+			invalidate: func(c *cache.UserSegments) {
+				c.InvalidateUser(ctx, "default", 1)
+			},
+			wantLoads: 2,
+		},
+		{
+			name: "Invalidated Project",
+			invalidate: func(c *cache.UserSegments) {
+				c.InvalidateProject(ctx, "default")
+			},
+			wantLoads: 2,
+		},
+		{
+			name: "Other Project Invalidated",
+			invalidate: func(c *cache.UserSegments) {
+				c.InvalidateProject(ctx, "mobile")
+			},
+			wantLoads: 1,
+		},
+		{
+			name:      "Membership Expires",
+			expireAt:  -time.Second,
+			wantLoads: 2,
+		},
+		{
+			name:      "Membership Expires After TTL",
+			expireAt:  time.Hour,
+			wantLoads: 1,
+		},
+		{
+			name: "Invalidated While Loading",
+			during: func(c *cache.UserSegments) {
+				c.InvalidateUser(ctx, "default", 1)
+			},
+			wantLoads: 2,
+		},
+		{
+			name: "Invalidated While Storing",
+			storing: func(c *cache.UserSegments) {
+				c.InvalidateUser(ctx, "default", 1)
+			},
+			wantLoads: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			backend := &hooked{Cache: cache.NewLRU(10)}
+			c := cache.New(backend, time.Minute)
+
+			l := &loader{segments: []string{"A", "B"}}
+			if tc.expireAt != 0 {
+				l.expireAt = time.Now().Add(tc.expireAt)
+			}
+			if tc.during != nil {
+				l.during = func() {
+					tc.during(c)
+					l.during = nil
+				}
+			}
+			if tc.storing != nil {
+				backend.beforeSet = func() {
+					tc.storing(c)
+					backend.beforeSet = nil
+				}
+			}
+
+			segments, err := c.Get(ctx, "default", 1, l.load)
+			require.NoError(t, err)
+			require.Equal(t, []string{"A", "B"}, segments)
+
+			if tc.invalidate != nil {
+				tc.invalidate(c)
+			}
+
+			segments, err = c.Get(ctx, "default", 1, l.load)
+			require.NoError(t, err)
+			require.Equal(t, []string{"A", "B"}, segments)
+
+			require.Equal(t, tc.wantLoads, l.loads)
+			require.Equal(t, cache.Stats{
+				Hits:   uint64(2 - tc.wantLoads),
+				Misses: uint64(tc.wantLoads),
+			}, c.Stats())
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	var c *cache.UserSegments
+
+	l := &loader{segments: []string{"A"}}
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(context.Background(), "default", 1, l.load)
+		require.NoError(t, err)
+	}
+	c.InvalidateUser(context.Background(), "default", 1)
+
+	require.Equal(t, 2, l.loads)
+}
+
+func TestLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(2)
+
+	require.NoError(t, lru.Set(ctx, "default", 1, []string{"A"}, time.Minute))
+	require.NoError(t, lru.Set(ctx, "default", 2, []string{"B"}, time.Minute))
+
+	// Using user 1 makes user 2 the least recently used
+	_, ok, _ := lru.Get(ctx, "default", 1)
+	require.True(t, ok)
+
+	require.NoError(t, lru.Set(ctx, "default", 3, []string{"C"}, time.Minute))
+	require.Equal(t, 2, lru.Len())
+
+	_, ok, _ = lru.Get(ctx, "default", 2)
+	require.False(t, ok)
+	_, ok, _ = lru.Get(ctx, "default", 1)
+	require.True(t, ok)
+}
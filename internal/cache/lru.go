@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type key struct {
+	project string
+	userID  int64
+}
+
+type lruEntry struct {
+	key       key
+	segments  []string
+	expiresAt time.Time
+}
+
+// LRU is an in-process Cache of a fixed number of users. The least recently
+// used entries are evicted first.
+type LRU struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[key]*list.Element
+	order   *list.List
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		entries: map[key]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, project string, userID int64) ([]string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key{project, userID}]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.remove(el)
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(el)
+
+	return entry.segments, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, project string, userID int64, segments []string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key{project, userID}
+	entry := &lruEntry{key: k, segments: segments, expiresAt: time.Now().Add(ttl)}
+
+	if el, ok := c.entries[k]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[k] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+
+	return nil
+}
+
+func (c *LRU) Delete(_ context.Context, project string, userID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key{project, userID}]; ok {
+		c.remove(el)
+	}
+
+	return nil
+}
+
+func (c *LRU) DeleteProject(_ context.Context, project string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, el := range c.entries {
+		if k.project == project {
+			c.remove(el)
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
@@ -1,52 +1,273 @@
 package config
 
 import (
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
 	"time"
-
-	"github.com/ilyakaznacheev/cleanenv"
-	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Env         string `env:"ENV" env-required:"true"`
-	PostgresURL string `env:"POSTGRES_URL" env-required:"true"`
-	HTTPServer
+	Env        string `env:"ENV" env-required:"true" yaml:"env" toml:"env"`
+	Log        `yaml:"log" toml:"log"`
+	Reload     `yaml:"reload" toml:"reload"`
+	Postgres   `yaml:"postgres" toml:"postgres"`
+	HTTPServer `yaml:"http_server" toml:"http_server"`
+	GRPCServer `yaml:"grpc_server" toml:"grpc_server"`
+	Scheduler  `yaml:"scheduler" toml:"scheduler"`
+	Tracing    `yaml:"tracing" toml:"tracing"`
+	Auth       `yaml:"auth" toml:"auth"`
+	RateLimit  `yaml:"rate_limit" toml:"rate_limit"`
+	Webhooks   `yaml:"webhooks" toml:"webhooks"`
+	Outbox     `yaml:"outbox" toml:"outbox"`
+	Events     `yaml:"events" toml:"events"`
+	Cache      `yaml:"cache" toml:"cache"`
+	Replicas   `yaml:"replicas" toml:"replicas"`
+
+	// File is the config file the configuration was loaded from, empty if
+	// none was read.
+	File string `yaml:"-" toml:"-"`
+}
+
+// Log configures logging. Level is debug, info, warn or error; if empty, it
+// is debug in the dev and test profiles and info in prod.
+type Log struct {
+	Level string `env:"LOG_LEVEL" yaml:"level" toml:"level" reload:"true"`
+}
+
+// Reload configures reloading of the settings that can change at runtime.
+// They are reloaded on SIGHUP and when the config file changes, which is
+// checked every WatchInterval; zero disables the check.
+type Reload struct {
+	WatchInterval time.Duration `env:"CONFIG_WATCH_INTERVAL" env-default:"5s" yaml:"watch_interval" toml:"watch_interval"`
+}
+
+// Postgres configures the pool of the primary database; replicas use the same
+// settings. Connecting on startup is tried ConnectAttempts times, waiting
+// ConnectRetryBase after the first failure and doubling the wait up to
+// ConnectRetryMax. StatementTimeout is enforced by the server for every
+// statement, QueryTimeout bounds every storage operation on the client side;
+// zero disables either.
+type Postgres struct {
+	URL               string        `env:"POSTGRES_URL" env-required:"true" yaml:"url" toml:"url" secret:"true"`
+	MaxConns          int32         `env:"POSTGRES_MAX_CONNS" env-default:"10" yaml:"max_conns" toml:"max_conns"`
+	MinConns          int32         `env:"POSTGRES_MIN_CONNS" env-default:"0" yaml:"min_conns" toml:"min_conns"`
+	MaxConnLifetime   time.Duration `env:"POSTGRES_MAX_CONN_LIFETIME" env-default:"1h" yaml:"max_conn_lifetime" toml:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `env:"POSTGRES_MAX_CONN_IDLE_TIME" env-default:"30m" yaml:"max_conn_idle_time" toml:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `env:"POSTGRES_HEALTH_CHECK_PERIOD" env-default:"1m" yaml:"health_check_period" toml:"health_check_period"`
+	ConnectTimeout    time.Duration `env:"POSTGRES_CONNECT_TIMEOUT" env-default:"5s" yaml:"connect_timeout" toml:"connect_timeout"`
+	StatementTimeout  time.Duration `env:"POSTGRES_STATEMENT_TIMEOUT" env-default:"30s" yaml:"statement_timeout" toml:"statement_timeout"`
+	QueryTimeout      time.Duration `env:"POSTGRES_QUERY_TIMEOUT" env-default:"10s" yaml:"query_timeout" toml:"query_timeout"`
+	ConnectAttempts   int           `env:"POSTGRES_CONNECT_ATTEMPTS" env-default:"10" yaml:"connect_attempts" toml:"connect_attempts"`
+	ConnectRetryBase  time.Duration `env:"POSTGRES_CONNECT_RETRY_BASE" env-default:"500ms" yaml:"connect_retry_base" toml:"connect_retry_base"`
+	ConnectRetryMax   time.Duration `env:"POSTGRES_CONNECT_RETRY_MAX" env-default:"10s" yaml:"connect_retry_max" toml:"connect_retry_max"`
 }
 
 type HTTPServer struct {
-	Address     string        `env:"HTTP_SERVER_ADDRESS" env-required:"true"`
-	Timeout     time.Duration `env:"HTTP_SERVER_TIMEOUT" env-required:"true"`
-	IdleTimeout time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" env-required:"true"`
+	Address     string        `env:"HTTP_SERVER_ADDRESS" env-required:"true" yaml:"address" toml:"address"`
+	Timeout     time.Duration `env:"HTTP_SERVER_TIMEOUT" env-required:"true" yaml:"timeout" toml:"timeout"`
+	IdleTimeout time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" env-required:"true" yaml:"idle_timeout" toml:"idle_timeout"`
+	// ShutdownDelay keeps serving with failing readiness before draining starts.
+	ShutdownDelay time.Duration `env:"HTTP_SERVER_SHUTDOWN_DELAY" env-default:"0s" yaml:"shutdown_delay" toml:"shutdown_delay"`
+	// ShutdownTimeout bounds how long in-flight requests are drained on stop.
+	ShutdownTimeout time.Duration `env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" env-default:"10s" yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// GRPCServer serves the gRPC API on a port of its own. It is disabled when
+// Address is empty. Shutdown uses the HTTP server shutdown timeout.
+type GRPCServer struct {
+	Address string `env:"GRPC_SERVER_ADDRESS" yaml:"address" toml:"address"`
+}
+
+// Scheduler holds job schedules, either "@every <duration>" or a cron
+// expression. They are reloadable.
+type Scheduler struct {
+	ExpireUsersSegments string `env:"SCHEDULER_EXPIRE_USERS_SEGMENTS" env-default:"@every 1h" yaml:"expire_users_segments" toml:"expire_users_segments" reload:"true"`
+	PruneOutbox         string `env:"SCHEDULER_PRUNE_OUTBOX" env-default:"@every 1h" yaml:"prune_outbox" toml:"prune_outbox" reload:"true"`
+}
+
+// Tracing is disabled when OTLPEndpoint is empty; W3C trace context is still
+// propagated so upstream traces are not broken.
+type Tracing struct {
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT" yaml:"otlp_endpoint" toml:"otlp_endpoint"`
+	ServiceName  string  `env:"TRACING_SERVICE_NAME" env-default:"segmentify" yaml:"service_name" toml:"service_name"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1" yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+type Auth struct {
+	Enabled bool `env:"AUTH_ENABLED" env-default:"true" yaml:"enabled" toml:"enabled"`
+	// AdminKey, if set, is seeded as an admin API key on startup so the first
+	// real keys can be created through the admin API.
+	AdminKey string `env:"AUTH_ADMIN_KEY" yaml:"admin_key" toml:"admin_key" secret:"true"`
+}
+
+// RateLimit limits are "<requests>/<period>", e.g. "100/s" or "1000/1h". Every
+// client gets a bucket of that many requests, refilled over the period. The
+// limits are reloadable, enabling or disabling them needs a restart.
+type RateLimit struct {
+	Enabled bool   `env:"RATE_LIMIT_ENABLED" env-default:"true" yaml:"enabled" toml:"enabled"`
+	Default string `env:"RATE_LIMIT_DEFAULT" env-default:"100/s" yaml:"default" toml:"default" reload:"true"`
+	// Address limits all requests of a remote address before they are
+	// authenticated, so requests with missing or invalid keys are limited
+	// too.
+	Address string `env:"RATE_LIMIT_ADDRESS" env-default:"1000/s" yaml:"address" toml:"address" reload:"true"`
+	// Routes give single routes limits of their own as "METHOD pattern=limit",
+	// e.g. "PATCH /users/{id}/segments=10/s".
+	Routes []string `env:"RATE_LIMIT_ROUTES" yaml:"routes" toml:"routes" reload:"true"`
+}
+
+// Webhooks configures delivery of webhook events. Failed deliveries are
+// retried with exponential backoff from RetryBase up to RetryMax and become
+// dead after MaxAttempts.
+type Webhooks struct {
+	PollInterval time.Duration `env:"WEBHOOKS_POLL_INTERVAL" env-default:"1s" yaml:"poll_interval" toml:"poll_interval"`
+	BatchSize    int64         `env:"WEBHOOKS_BATCH_SIZE" env-default:"100" yaml:"batch_size" toml:"batch_size"`
+	Timeout      time.Duration `env:"WEBHOOKS_TIMEOUT" env-default:"5s" yaml:"timeout" toml:"timeout"`
+	MaxAttempts  int           `env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"8" yaml:"max_attempts" toml:"max_attempts"`
+	RetryBase    time.Duration `env:"WEBHOOKS_RETRY_BASE" env-default:"10s" yaml:"retry_base" toml:"retry_base"`
+	RetryMax     time.Duration `env:"WEBHOOKS_RETRY_MAX" env-default:"1h" yaml:"retry_max" toml:"retry_max"`
 }
 
-func MustLoad() *Config {
-	env := os.Getenv("ENV")
-	if env == "" {
-		log.Fatal("ENV is not set")
+// Outbox configures the relay publishing change events. Publisher is "none",
+// "stdout", "file" (appending to File) or "http" (posting to URL). Published
+// events are kept for Retention.
+type Outbox struct {
+	Publisher    string        `env:"OUTBOX_PUBLISHER" env-default:"none" yaml:"publisher" toml:"publisher"`
+	File         string        `env:"OUTBOX_FILE" env-default:"outbox.ndjson" yaml:"file" toml:"file"`
+	URL          string        `env:"OUTBOX_URL" yaml:"url" toml:"url" secret:"true"`
+	Timeout      time.Duration `env:"OUTBOX_TIMEOUT" env-default:"5s" yaml:"timeout" toml:"timeout"`
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" env-default:"1s" yaml:"poll_interval" toml:"poll_interval"`
+	BatchSize    int64         `env:"OUTBOX_BATCH_SIZE" env-default:"100" yaml:"batch_size" toml:"batch_size"`
+	RetryMax     time.Duration `env:"OUTBOX_RETRY_MAX" env-default:"1m" yaml:"retry_max" toml:"retry_max"`
+	Retention    time.Duration `env:"OUTBOX_RETENTION" env-default:"168h" yaml:"retention" toml:"retention"`
+}
+
+// Events configures event streams. Buffer bounds the live events waiting to
+// be sent to a client; a client falling further behind is disconnected and
+// resumes from its last event. Streams also poll for events every
+// PollInterval in case a notification is lost.
+type Events struct {
+	Heartbeat    time.Duration `env:"EVENTS_HEARTBEAT" env-default:"15s" yaml:"heartbeat" toml:"heartbeat"`
+	Buffer       int           `env:"EVENTS_BUFFER" env-default:"256" yaml:"buffer" toml:"buffer"`
+	PollInterval time.Duration `env:"EVENTS_POLL_INTERVAL" env-default:"5s" yaml:"poll_interval" toml:"poll_interval"`
+}
+
+// Cache is the in-process cache of user segments. Entries live for TTL at
+// most and are dropped on changes made through this replica, so changes made
+// through other replicas are seen after TTL at the latest.
+type Cache struct {
+	Enabled bool          `env:"CACHE_ENABLED" env-default:"false" yaml:"enabled" toml:"enabled"`
+	Size    int           `env:"CACHE_SIZE" env-default:"10000" yaml:"size" toml:"size"`
+	TTL     time.Duration `env:"CACHE_TTL" env-default:"10s" yaml:"ttl" toml:"ttl"`
+}
+
+// Replicas are Postgres read replicas serving reads that tolerate slight
+// staleness. A replica lagging more than MaxLag or failing its check is
+// skipped until it recovers; with no healthy replica reads go to the primary.
+// For ReadYourWrites after a client changed data, its reads go to the primary
+// too.
+type Replicas struct {
+	URLs           []string      `env:"POSTGRES_REPLICA_URLS" yaml:"urls" toml:"urls" secret:"true"`
+	CheckInterval  time.Duration `env:"POSTGRES_REPLICA_CHECK_INTERVAL" env-default:"5s" yaml:"check_interval" toml:"check_interval"`
+	MaxLag         time.Duration `env:"POSTGRES_REPLICA_MAX_LAG" env-default:"5s" yaml:"max_lag" toml:"max_lag"`
+	ReadYourWrites time.Duration `env:"POSTGRES_READ_YOUR_WRITES" env-default:"5s" yaml:"read_your_writes" toml:"read_your_writes"`
+}
+
+// LogLevel returns the configured log level or the default of the profile.
+func (c *Config) LogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err == nil {
+		return level
 	}
 
-	var envFiles = map[string]string{
-		"test": "configs/test.env",
-		"dev":  "configs/dev.env",
+	switch c.Env {
+	case "dev", "test":
+		return slog.LevelDebug
+	default: // If env config is invalid, set prod settings by default due to security
+		return slog.LevelInfo
 	}
+}
+
+// Validate reports every setting that is out of range, naming it by its
+// environment variable. The error is an *ErrInvalid.
+func (c *Config) Validate() error {
+	var errs []error
 
-	fileName, exists := envFiles[env]
-	if !exists {
-		log.Fatalf("unknown ENV mode: %s", env)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
 	}
+	positive := func(name string, d time.Duration) {
+		check(d > 0, "%s must be positive, got %s", name, d)
+	}
+	nonNegative := func(name string, d time.Duration) {
+		check(d >= 0, "%s must not be negative, got %s", name, d)
+	}
+
+	pg := c.Postgres
+	check(pg.MaxConns > 0, "POSTGRES_MAX_CONNS must be positive, got %d", pg.MaxConns)
+	check(pg.MinConns >= 0, "POSTGRES_MIN_CONNS must not be negative, got %d", pg.MinConns)
+	check(pg.MinConns <= pg.MaxConns,
+		"POSTGRES_MIN_CONNS must not exceed POSTGRES_MAX_CONNS, got %d > %d", pg.MinConns, pg.MaxConns)
+	positive("POSTGRES_MAX_CONN_LIFETIME", pg.MaxConnLifetime)
+	positive("POSTGRES_MAX_CONN_IDLE_TIME", pg.MaxConnIdleTime)
+	positive("POSTGRES_HEALTH_CHECK_PERIOD", pg.HealthCheckPeriod)
+	nonNegative("POSTGRES_CONNECT_TIMEOUT", pg.ConnectTimeout)
+	nonNegative("POSTGRES_STATEMENT_TIMEOUT", pg.StatementTimeout)
+	check(pg.StatementTimeout%time.Millisecond == 0,
+		"POSTGRES_STATEMENT_TIMEOUT must be a whole number of milliseconds, got %s", pg.StatementTimeout)
+	nonNegative("POSTGRES_QUERY_TIMEOUT", pg.QueryTimeout)
+	check(pg.ConnectAttempts > 0, "POSTGRES_CONNECT_ATTEMPTS must be positive, got %d", pg.ConnectAttempts)
+	positive("POSTGRES_CONNECT_RETRY_BASE", pg.ConnectRetryBase)
+	check(pg.ConnectRetryMax >= pg.ConnectRetryBase,
+		"POSTGRES_CONNECT_RETRY_MAX must not be less than POSTGRES_CONNECT_RETRY_BASE, got %s < %s",
+		pg.ConnectRetryMax, pg.ConnectRetryBase)
 
-	err := godotenv.Load(fileName)
-	if err != nil {
-		log.Fatalf("failed to load .env file: %s", err)
+	if c.Log.Level != "" {
+		var level slog.Level
+		check(level.UnmarshalText([]byte(c.Log.Level)) == nil,
+			"LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
 	}
+	nonNegative("CONFIG_WATCH_INTERVAL", c.Reload.WatchInterval)
+
+	positive("POSTGRES_REPLICA_CHECK_INTERVAL", c.Replicas.CheckInterval)
+	nonNegative("POSTGRES_REPLICA_MAX_LAG", c.Replicas.MaxLag)
+	nonNegative("POSTGRES_READ_YOUR_WRITES", c.Replicas.ReadYourWrites)
+
+	positive("HTTP_SERVER_TIMEOUT", c.HTTPServer.Timeout)
+	positive("HTTP_SERVER_IDLE_TIMEOUT", c.HTTPServer.IdleTimeout)
+	nonNegative("HTTP_SERVER_SHUTDOWN_DELAY", c.HTTPServer.ShutdownDelay)
+	nonNegative("HTTP_SERVER_SHUTDOWN_TIMEOUT", c.HTTPServer.ShutdownTimeout)
 
-	var cfg Config
+	wh := c.Webhooks
+	positive("WEBHOOKS_POLL_INTERVAL", wh.PollInterval)
+	check(wh.BatchSize > 0, "WEBHOOKS_BATCH_SIZE must be positive, got %d", wh.BatchSize)
+	positive("WEBHOOKS_TIMEOUT", wh.Timeout)
+	check(wh.MaxAttempts > 0, "WEBHOOKS_MAX_ATTEMPTS must be positive, got %d", wh.MaxAttempts)
+	positive("WEBHOOKS_RETRY_BASE", wh.RetryBase)
+	check(wh.RetryMax >= wh.RetryBase,
+		"WEBHOOKS_RETRY_MAX must not be less than WEBHOOKS_RETRY_BASE, got %s < %s", wh.RetryMax, wh.RetryBase)
+
+	ob := c.Outbox
+	positive("OUTBOX_TIMEOUT", ob.Timeout)
+	positive("OUTBOX_POLL_INTERVAL", ob.PollInterval)
+	check(ob.BatchSize > 0, "OUTBOX_BATCH_SIZE must be positive, got %d", ob.BatchSize)
+	nonNegative("OUTBOX_RETRY_MAX", ob.RetryMax)
+	positive("OUTBOX_RETENTION", ob.Retention)
+
+	positive("EVENTS_HEARTBEAT", c.Events.Heartbeat)
+	check(c.Events.Buffer >= 0, "EVENTS_BUFFER must not be negative, got %d", c.Events.Buffer)
+	positive("EVENTS_POLL_INTERVAL", c.Events.PollInterval)
+
+	// A disabled cache is never built
+	if c.Cache.Enabled {
+		check(c.Cache.Size > 0, "CACHE_SIZE must be positive, got %d", c.Cache.Size)
+		positive("CACHE_TTL", c.Cache.TTL)
+	}
 
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		log.Fatalf("failed to read .env file: %s", err)
+	if len(errs) > 0 {
+		return &ErrInvalid{Errors: errs}
 	}
 
-	return &cfg
+	return nil
 }
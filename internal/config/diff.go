@@ -0,0 +1,35 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Diff lists the variables of the settings that differ between a and b,
+// split into the ones that can be reloaded at runtime and the ones that need
+// a restart.
+func Diff(a, b *Config) (reloadable, restart []string) {
+	as := settingsOf(reflect.ValueOf(a).Elem())
+	bs := settingsOf(reflect.ValueOf(b).Elem())
+
+	for i, s := range as {
+		if equal(s.value, bs[i].value) {
+			continue
+		}
+
+		if s.reload {
+			reloadable = append(reloadable, s.key)
+		} else {
+			restart = append(restart, s.key)
+		}
+	}
+
+	return reloadable, restart
+}
+
+func equal(a, b reflect.Value) bool {
+	// Lists read from different sources may be nil or empty
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
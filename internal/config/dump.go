@@ -0,0 +1,69 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in dumps.
+const redacted = "xxxxx"
+
+// Dump writes the configuration as YAML in the layout of config files, with
+// secrets redacted.
+func (c *Config) Dump(w io.Writer) error {
+	cfg := *c
+
+	for _, s := range settingsOf(reflect.ValueOf(&cfg).Elem()) {
+		if !s.secret {
+			continue
+		}
+
+		switch v := s.value.Interface().(type) {
+		case string:
+			s.value.SetString(redact(v))
+		case []string:
+			// The slice is shared with c, so redact a copy
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, redact(item))
+			}
+			s.value.Set(reflect.ValueOf(items))
+		}
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// redact hides a secret. Of URLs only the password and query values are
+// hidden, so it is still seen where they point.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	u, err := url.Parse(secret)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	query := u.Query()
+	for key := range query {
+		query.Set(key, redacted)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
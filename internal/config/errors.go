@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownEnv is returned when ENV is not set or names no profile.
+type ErrUnknownEnv struct {
+	Env string
+}
+
+func (e ErrUnknownEnv) Error() string {
+	if e.Env == "" {
+		return "ENV is not set, expected dev, test or prod"
+	}
+	return fmt.Sprintf("unknown ENV=%s, expected dev, test or prod", e.Env)
+}
+
+// ErrSource is returned when a source of settings can not be read or sets a
+// value that can not be parsed. Source is the path of the config file,
+// "environment" or "flags".
+type ErrSource struct {
+	Source string
+	Err    error
+}
+
+func (e ErrSource) Error() string {
+	return fmt.Sprintf("read %s: %s", e.Source, e.Err)
+}
+
+func (e ErrSource) Unwrap() error {
+	return e.Err
+}
+
+// ErrMissing lists the required settings no source has set.
+type ErrMissing struct {
+	Keys []string
+}
+
+func (e ErrMissing) Error() string {
+	return "required settings are not set: " + strings.Join(e.Keys, ", ")
+}
+
+// ErrInvalid lists the settings that are out of range.
+type ErrInvalid struct {
+	Errors []error
+}
+
+func (e ErrInvalid) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid settings: " + strings.Join(msgs, "; ")
+}
+
+func (e ErrInvalid) Unwrap() []error {
+	return e.Errors
+}
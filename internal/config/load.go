@@ -0,0 +1,304 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// profiles map ENV to the file read when no config file is given. The prod
+// profile has no file, it is configured by environment variables and flags.
+var profiles = map[string]string{
+	"dev":  "configs/dev.env",
+	"test": "configs/test.env",
+	"prod": "",
+}
+
+// Load reads the configuration from, in increasing precedence, the defaults,
+// a config file, environment variables and the flags in args, and validates
+// it.
+//
+// The config file is given by the -config flag or CONFIG_FILE and defaults to
+// the file of the ENV profile. YAML (.yaml, .yml) and TOML (.toml) files hold
+// the sections printed by Dump, .env files hold environment variables. Every
+// setting has a flag named after its variable: POSTGRES_MAX_CONNS is set by
+// -postgres-max-conns.
+//
+// Errors are *ErrUnknownEnv, *ErrSource, *ErrMissing or *ErrInvalid. A help
+// request is an *ErrSource wrapping flag.ErrHelp.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("segmentify", flag.ContinueOnError)
+	load := Flags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, &ErrSource{Source: "flags", Err: err}
+	}
+	if fs.NArg() > 0 {
+		return nil, &ErrSource{Source: "flags", Err: fmt.Errorf("unexpected argument %q", fs.Arg(0))}
+	}
+
+	return load()
+}
+
+// Flags defines the -config flag and a flag for every setting on fs, so
+// commands can take them along with flags of their own. The returned function
+// loads the configuration like Load once fs is parsed; it may be called again
+// to reload it.
+func Flags(fs *flag.FlagSet) func() (*Config, error) {
+	file := fs.String("config", "", "path to a YAML, TOML or .env config file")
+
+	flags := map[string]string{}
+	for _, s := range settingsOf(reflect.ValueOf(&Config{}).Elem()) {
+		key := s.key
+
+		usage := "sets " + key
+		if s.def != nil && *s.def != "" {
+			usage += fmt.Sprintf(" (default %q)", *s.def)
+		}
+
+		fs.Func(flagName(key), usage, func(value string) error {
+			flags[key] = value
+			return nil
+		})
+	}
+
+	return func() (*Config, error) {
+		return load(*file, flags)
+	}
+}
+
+// load reads the configuration from the sources, flags holding the values
+// of the flags that were set.
+func load(file string, flags map[string]string) (*Config, error) {
+	var cfg Config
+	settings := settingsOf(reflect.ValueOf(&cfg).Elem())
+
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file == "" {
+		env, ok := flags["ENV"]
+		if !ok {
+			env = os.Getenv("ENV")
+		}
+
+		profileFile, ok := profiles[env]
+		if !ok {
+			return nil, &ErrUnknownEnv{Env: env}
+		}
+		file = profileFile
+	}
+
+	for _, s := range settings {
+		if s.def == nil {
+			continue
+		}
+		if err := set(s.value, *s.def); err != nil {
+			return nil, &ErrSource{Source: "defaults", Err: fmt.Errorf("%s: %w", s.key, err)}
+		}
+	}
+
+	cfg.File = file
+	if file != "" {
+		if err := readFile(file, &cfg, settings); err != nil {
+			return nil, &ErrSource{Source: file, Err: err}
+		}
+	}
+
+	if err := apply(settings, os.LookupEnv); err != nil {
+		return nil, &ErrSource{Source: "environment", Err: err}
+	}
+
+	if err := apply(settings, lookupMap(flags)); err != nil {
+		return nil, &ErrSource{Source: "flags", Err: err}
+	}
+
+	var missing []string
+	for _, s := range settings {
+		if s.required && s.value.IsZero() {
+			missing = append(missing, s.key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ErrMissing{Keys: missing}
+	}
+
+	if _, ok := profiles[cfg.Env]; !ok {
+		return nil, &ErrUnknownEnv{Env: cfg.Env}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// setting is a single configuration value along with its tags.
+type setting struct {
+	// key is the environment variable of the setting.
+	key      string
+	value    reflect.Value
+	def      *string
+	required bool
+	secret   bool
+	// reload tells the setting can be changed at runtime.
+	reload bool
+}
+
+// settingsOf lists the settings of the config struct v, walking nested
+// sections.
+func settingsOf(v reflect.Value) []setting {
+	var settings []setting
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+
+		if value.Kind() == reflect.Struct {
+			settings = append(settings, settingsOf(value)...)
+			continue
+		}
+
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		s := setting{key: key, value: value}
+		if def, ok := field.Tag.Lookup("env-default"); ok {
+			s.def = &def
+		}
+		_, s.required = field.Tag.Lookup("env-required")
+		_, s.secret = field.Tag.Lookup("secret")
+		_, s.reload = field.Tag.Lookup("reload")
+
+		settings = append(settings, s)
+	}
+
+	return settings
+}
+
+// flagName turns an environment variable into a flag name, e.g.
+// POSTGRES_MAX_CONNS into postgres-max-conns.
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// readFile reads the config file into cfg. The format is told by the
+// extension.
+func readFile(path string, cfg *Config, settings []setting) error {
+	switch ext := filepath.Ext(path); ext {
+	case ".env":
+		vars, err := godotenv.Read(path)
+		if err != nil {
+			return err
+		}
+		// Unknown variables are allowed, env files are shared with the
+		// database container
+		return apply(settings, lookupMap(vars))
+	case ".yaml", ".yml":
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		meta, err := toml.DecodeFile(path, cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %s", undecoded[0])
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q, expected .yaml, .yml, .toml or .env", ext)
+	}
+}
+
+// apply sets the settings lookup has values for.
+func apply(settings []setting, lookup func(key string) (string, bool)) error {
+	for _, s := range settings {
+		raw, ok := lookup(s.key)
+		if !ok {
+			continue
+		}
+		if err := set(s.value, raw); err != nil {
+			return fmt.Errorf("%s: %w", s.key, err)
+		}
+	}
+
+	return nil
+}
+
+func lookupMap(values map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw into value. Lists are comma-separated.
+func set(value reflect.Value, raw string) error {
+	switch {
+	case value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+	case value.Kind() == reflect.String:
+		value.SetString(raw)
+	case value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case value.CanInt():
+		n, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case value.CanFloat():
+		f, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case value.Type() == reflect.TypeOf([]string(nil)):
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package consistency
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"segmentify/internal/config"
+)
+
+// pruneThreshold is the number of tracked clients above which clients that
+// have not written within the window are forgotten.
+const pruneThreshold = 1024
+
+// Writers gives read-your-writes on top of read replicas: for a window after
+// a client changed data, its reads must go to the primary database. The HTTP
+// and gRPC APIs share one Writers, so a change made through either is seen
+// by reads through both.
+type Writers struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func New(window time.Duration) *Writers {
+	return &Writers{window: window, last: map[string]time.Time{}}
+}
+
+// FromConfig returns the Writers for the configured window, or nil if reads
+// are not spread over replicas or the window is zero.
+func FromConfig(cfg config.Replicas) *Writers {
+	if cfg.ReadYourWrites <= 0 {
+		return nil
+	}
+	for _, url := range cfg.URLs {
+		if strings.TrimSpace(url) != "" {
+			return New(cfg.ReadYourWrites)
+		}
+	}
+	return nil
+}
+
+// WroteRecently reports whether the client changed data within the window.
+func (w *Writers) WroteRecently(client string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	last, ok := w.last[client]
+	return ok && time.Since(last) < w.window
+}
+
+// Wrote starts the window of the client. It is called when the change is
+// done, not when it is requested.
+func (w *Writers) Wrote(client string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.last[client] = now
+
+	if len(w.last) > pruneThreshold {
+		for c, last := range w.last {
+			if now.Sub(last) >= w.window {
+				delete(w.last, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/models"
+)
+
+// batchSize bounds the events read from the outbox at once.
+const batchSize = 500
+
+type Storage interface {
+	ListenEvents(ctx context.Context, notify func()) error
+	GetEventsAfter(ctx context.Context, project string, afterID, limit int64) ([]models.OutboxEvent, error)
+	GetLastEventID(ctx context.Context) (int64, error)
+}
+
+// Filter selects the events of a stream. Zero fields do not filter. UserID
+// keeps membership events of the user and segment lifecycle events, since a
+// deleted segment is gone for every user. Snapshot events pass both UserID
+// and Segment, an import may have changed any of them.
+type Filter struct {
+	Project string
+	UserID  int64
+	Segment string
+}
+
+// eventFields are the payload fields filters look at.
+type eventFields struct {
+	Segment string `json:"segment"`
+	UserID  int64  `json:"user_id"`
+}
+
+func (f Filter) match(event models.OutboxEvent, fields eventFields) bool {
+	if f.Project != "" && event.Project != f.Project {
+		return false
+	}
+	if f.Segment != "" && event.Type != models.EventSnapshotImported && fields.Segment != f.Segment {
+		return false
+	}
+	if f.UserID != 0 && fields.UserID != 0 && fields.UserID != f.UserID {
+		return false
+	}
+	return true
+}
+
+// Match reports whether the event passes the filter.
+func (f Filter) Match(event models.OutboxEvent) bool {
+	var fields eventFields
+	if err := json.Unmarshal(event.Payload, &fields); err != nil {
+		return false
+	}
+	return f.match(event, fields)
+}
+
+// Subscription receives live events matching its filter. The channel is
+// closed when the hub stops or the subscriber falls too far behind; the
+// subscriber should then resume from the last event it got.
+type Subscription struct {
+	filter Filter
+	events chan models.OutboxEvent
+	hub    *Hub
+}
+
+func (s *Subscription) Events() <-chan models.OutboxEvent {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// Hub reads new events from the outbox when Postgres notifies that some were
+// committed and fans them out to subscriptions. Every replica runs its own
+// hub, so any of them can serve any stream.
+type Hub struct {
+	log     *slog.Logger
+	storage Storage
+	cfg     config.Events
+
+	wake chan struct{}
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	stopped bool
+}
+
+func New(log *slog.Logger, storage Storage, cfg config.Events) *Hub {
+	return &Hub{
+		log:     log.With(slog.String("component", "events")),
+		storage: storage,
+		cfg:     cfg,
+		wake:    make(chan struct{}, 1),
+		subs:    map[*Subscription]struct{}{},
+	}
+}
+
+// Subscribe starts receiving live events matching filter.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	sub := &Subscription{
+		filter: filter,
+		events: make(chan models.OutboxEvent, h.cfg.Buffer),
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		close(sub.events)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub
+}
+
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Replay calls send for every stored event after afterID matching filter,
+// oldest first, and returns the ID of the last event read. Live events up to
+// that ID have already been replayed.
+func (h *Hub) Replay(
+	ctx context.Context,
+	filter Filter,
+	afterID int64,
+	send func(models.OutboxEvent) error,
+) (int64, error) {
+	const op = "events.Hub.Replay"
+
+	for {
+		events, err := h.storage.GetEventsAfter(ctx, filter.Project, afterID, batchSize)
+		if err != nil {
+			return afterID, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, event := range events {
+			if filter.Match(event) {
+				if err := send(event); err != nil {
+					return afterID, fmt.Errorf("%s: send event: %w", op, err)
+				}
+			}
+			afterID = event.ID
+		}
+
+		if len(events) < batchSize {
+			return afterID, nil
+		}
+	}
+}
+
+// Run listens for notifications and fans out new events until ctx is done,
+// then closes every subscription. Events are also polled every poll
+// interval, so nothing is missed while the listening connection is down.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.stop()
+
+	cursor, err := h.storage.GetLastEventID(ctx)
+	for err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		h.log.Error("failed to get last event id", sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.cfg.PollInterval):
+		}
+		cursor, err = h.storage.GetLastEventID(ctx)
+	}
+
+	go h.listen(ctx)
+
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.wake:
+		case <-ticker.C:
+		}
+
+		cursor = h.fanOut(ctx, cursor)
+	}
+}
+
+// listen keeps a listening connection, reconnecting after failures.
+func (h *Hub) listen(ctx context.Context) {
+	notify := func() {
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	for {
+		err := h.storage.ListenEvents(ctx, notify)
+		if ctx.Err() != nil {
+			return
+		}
+		h.log.Error("failed to listen for events", sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.cfg.PollInterval):
+		}
+		// Events committed while reconnecting are picked up by the poll
+		notify()
+	}
+}
+
+// fanOut sends events after cursor to matching subscriptions and returns the
+// new cursor.
+func (h *Hub) fanOut(ctx context.Context, cursor int64) int64 {
+	for {
+		events, err := h.storage.GetEventsAfter(ctx, "", cursor, batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				h.log.Error("failed to get events", sl.Err(err))
+			}
+			return cursor
+		}
+
+		for _, event := range events {
+			h.publish(event)
+			cursor = event.ID
+		}
+
+		if len(events) < batchSize {
+			return cursor
+		}
+	}
+}
+
+func (h *Hub) publish(event models.OutboxEvent) {
+	var fields eventFields
+	if err := json.Unmarshal(event.Payload, &fields); err != nil {
+		h.log.Error("failed to decode event", slog.Int64("id", event.ID), sl.Err(err))
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if !sub.filter.match(event, fields) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			// Dropping the subscriber keeps a slow client from holding up
+			// the others, it resumes with Last-Event-ID
+			delete(h.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopped = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
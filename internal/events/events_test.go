@@ -0,0 +1,164 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/config"
+	"segmentify/internal/events"
+	"segmentify/internal/models"
+)
+
+// memoryStorage keeps events in memory and notifies listeners on append.
+type memoryStorage struct {
+	mu        sync.Mutex
+	events    []models.OutboxEvent
+	listening chan func()
+}
+
+func (s *memoryStorage) ListenEvents(ctx context.Context, notify func()) error {
+	s.listening <- notify
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *memoryStorage) GetEventsAfter(_ context.Context, project string, afterID, limit int64) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []models.OutboxEvent{}
+	for _, e := range s.events {
+		if e.ID > afterID && (project == "" || e.Project == project) && int64(len(events)) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStorage) GetLastEventID(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.events) == 0 {
+		return 0, nil
+	}
+	return s.events[len(s.events)-1].ID, nil
+}
+
+func (s *memoryStorage) append(project, segment string, userID int64) models.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := int64(len(s.events) + 1)
+	payload, _ := json.Marshal(models.MembershipEvent{
+		ID:      fmt.Sprint("e", id),
+		Type:    models.EventUserAdded,
+		Project: project,
+		Segment: segment,
+		UserID:  userID,
+	})
+	event := models.OutboxEvent{ID: id, Type: models.EventUserAdded, Project: project, Payload: payload}
+	s.events = append(s.events, event)
+	return event
+}
+
+func TestFilterMatch(t *testing.T) {
+	membership := models.OutboxEvent{
+		Project: "default",
+		Payload: json.RawMessage(`{"segment":"A","user_id":1}`),
+	}
+	segment := models.OutboxEvent{
+		Project: "default",
+		Payload: json.RawMessage(`{"segment":"A"}`),
+	}
+	snapshot := models.OutboxEvent{
+		Type:    models.EventSnapshotImported,
+		Project: "default",
+		Payload: json.RawMessage(`{"mode":"upsert"}`),
+	}
+
+	tests := []struct {
+		name   string
+		filter events.Filter
+		event  models.OutboxEvent
+		match  bool
+	}{
+		{name: "No filter", event: membership, match: true},
+		{name: "Other project", filter: events.Filter{Project: "other"}, event: membership},
+		{name: "Same user", filter: events.Filter{UserID: 1}, event: membership, match: true},
+		{name: "Other user", filter: events.Filter{UserID: 2}, event: membership},
+		{name: "Segment event by user", filter: events.Filter{UserID: 2}, event: segment, match: true},
+		{name: "Same segment", filter: events.Filter{Segment: "A"}, event: segment, match: true},
+		{name: "Other segment", filter: events.Filter{Segment: "B"}, event: membership},
+		{name: "Snapshot event by segment", filter: events.Filter{UserID: 1, Segment: "B"}, event: snapshot, match: true},
+		{name: "Snapshot event of other project", filter: events.Filter{Project: "other"}, event: snapshot},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.match, tc.filter.Match(tc.event))
+		})
+	}
+}
+
+func TestHub(t *testing.T) {
+	storage := &memoryStorage{listening: make(chan func(), 1)}
+	storage.append("default", "A", 1)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hub := events.New(log, storage, config.Events{Buffer: 10, PollInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := hub.Subscribe(events.Filter{Project: "default", UserID: 1})
+	defer sub.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.Run(ctx)
+	}()
+
+	notify := <-storage.listening
+
+	// Stored events are replayed, live ones are sent to matching subscribers
+	var replayed []int64
+	last, err := hub.Replay(ctx, events.Filter{Project: "default", UserID: 1}, 0, func(e models.OutboxEvent) error {
+		replayed = append(replayed, e.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, replayed)
+	require.Equal(t, int64(1), last)
+
+	storage.append("default", "A", 2)
+	live := storage.append("default", "B", 1)
+	notify()
+
+	select {
+	case event := <-sub.Events():
+		require.Equal(t, live.ID, event.ID)
+	case <-time.After(time.Second):
+		t.Fatal("event was not sent to the subscriber")
+	}
+
+	// Stopping the hub closes subscriptions
+	cancel()
+	<-done
+
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+}
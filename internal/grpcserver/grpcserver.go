@@ -0,0 +1,210 @@
+package grpcserver
+
+//go:generate go run github.com/bufbuild/buf/cmd/buf@v1.28.1 generate --template ../../buf.gen.yaml -o ../.. ../../api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/consistency"
+	createSegment "segmentify/internal/httpserver/handlers/segments/create"
+	deleteSegment "segmentify/internal/httpserver/handlers/segments/delete"
+	getSegment "segmentify/internal/httpserver/handlers/segments/get"
+	createUser "segmentify/internal/httpserver/handlers/users/create"
+	getUserSegments "segmentify/internal/httpserver/handlers/users/get"
+	downloadUserSegmentsHistory "segmentify/internal/httpserver/handlers/users/gethistory"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	mwAuth "segmentify/internal/httpserver/middleware/auth"
+	mwProject "segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/models"
+	"segmentify/internal/ratelimit"
+	"segmentify/internal/storage"
+	segmentifyv1 "segmentify/pkg/api/segmentify/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Storage is everything the gRPC services need. It is made of the same
+// interfaces the HTTP handlers use, so both APIs share the storage code.
+type Storage interface {
+	mwAuth.APIKeyGetter
+	mwProject.ProjectGetter
+	createSegment.SegmentCreator
+	getSegment.SegmentGetter
+	deleteSegment.SegmentDeleter
+	createUser.UserCreator
+	getUserSegments.UserSegmentsGetter
+	updateUserSegments.UserSegmentsUpdater
+	downloadUserSegmentsHistory.UserSegmentsHistoryGetter
+}
+
+// Limiters rate limit calls as the HTTP API limits requests. A nil limiter
+// does not limit.
+type Limiters struct {
+	// Address limits calls per peer address before authentication.
+	Address *ratelimit.Limiter
+	// Client limits calls per API key, or per peer address without one.
+	Client *ratelimit.Limiter
+}
+
+type Server struct {
+	log             *slog.Logger
+	server          *grpc.Server
+	storage         Storage
+	address         string
+	shutdownTimeout time.Duration
+}
+
+// New creates a gRPC server with the segment and user services. If
+// authenticate is false, every call is made with the admin role, like with
+// authentication disabled for the HTTP API. Writers may be nil, then reads
+// are not sent to the primary database after changes.
+func New(
+	log *slog.Logger,
+	address string,
+	shutdownTimeout time.Duration,
+	storage Storage,
+	authenticate bool,
+	limiters Limiters,
+	writers *consistency.Writers,
+) *Server {
+	log = log.With(slog.String("component", "grpcserver"))
+
+	s := &Server{
+		log:             log,
+		storage:         storage,
+		address:         address,
+		shutdownTimeout: shutdownTimeout,
+	}
+
+	i := &interceptors{
+		log:          log,
+		apiKeyGetter: storage,
+		authenticate: authenticate,
+		limiters:     limiters,
+		writers:      writers,
+	}
+
+	s.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(i.unary),
+		grpc.ChainStreamInterceptor(i.stream),
+	)
+
+	segmentifyv1.RegisterSegmentServiceServer(s.server, &segmentService{Server: s})
+	segmentifyv1.RegisterUserServiceServer(s.server, &userService{Server: s})
+	reflection.Register(s.server)
+
+	return s
+}
+
+// Run serves gRPC on the configured address until ctx is done, then stops
+// gracefully. Calls still running after the shutdown timeout are cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	const op = "grpcserver.Server.Run"
+
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("%s: listen: %w", op, err)
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+// Serve is like Run but accepts connections on the given listener.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	const op = "grpcserver.Server.Serve"
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.server.Serve(listener)
+	}()
+
+	s.log.Info("grpc server started", slog.String("address", listener.Addr().String()))
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("%s: serve: %w", op, err)
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(s.shutdownTimeout):
+		s.log.Warn("grpc shutdown timed out, cancelling calls")
+		s.server.Stop()
+	}
+
+	s.log.Info("grpc server stopped")
+
+	return nil
+}
+
+// project resolves the project of a request: an empty slug is the default
+// project. The caller's key must be allowed to access it.
+func (s *Server) project(ctx context.Context, slug string) (string, error) {
+	if slug == "" {
+		slug = models.DefaultProject
+	}
+
+	key, _ := auth.APIKeyFromContext(ctx)
+	if !auth.AllowsProject(key, slug) {
+		return "", status.Error(codes.PermissionDenied, "api key is not allowed to access the project")
+	}
+
+	if _, err := s.storage.GetProject(ctx, slug); err != nil {
+		return "", s.toStatus(ctx, "failed to get project", err)
+	}
+
+	return slug, nil
+}
+
+// toStatus maps typed storage errors to gRPC status codes. Other errors are
+// logged and reported as internal with msg only.
+func (s *Server) toStatus(ctx context.Context, msg string, err error) error {
+	var (
+		errSegmentNotFound     *storage.ErrSegmentNotFound
+		errSegmentExists       *storage.ErrSegmentExists
+		errUserNotFound        *storage.ErrUserNotFound
+		errUserSegmentNotFound *storage.ErrUserSegmentNotFound
+		errUserSegmentExists   *storage.ErrUserSegmentExists
+		errProjectNotFound     *storage.ErrProjectNotFound
+	)
+
+	switch {
+	case errors.As(err, &errSegmentNotFound):
+		return status.Error(codes.NotFound, errSegmentNotFound.Error())
+	case errors.As(err, &errUserNotFound):
+		return status.Error(codes.NotFound, errUserNotFound.Error())
+	case errors.As(err, &errUserSegmentNotFound):
+		return status.Error(codes.NotFound, errUserSegmentNotFound.Error())
+	case errors.As(err, &errProjectNotFound):
+		return status.Error(codes.NotFound, errProjectNotFound.Error())
+	case errors.As(err, &errSegmentExists):
+		return status.Error(codes.AlreadyExists, errSegmentExists.Error())
+	case errors.As(err, &errUserSegmentExists):
+		return status.Error(codes.AlreadyExists, errUserSegmentExists.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	s.log.ErrorContext(ctx, msg, sl.Err(err))
+
+	return status.Error(codes.Internal, msg)
+}
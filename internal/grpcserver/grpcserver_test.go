@@ -0,0 +1,272 @@
+package grpcserver_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/consistency"
+	"segmentify/internal/grpcserver"
+	"segmentify/internal/models"
+	"segmentify/internal/ratelimit"
+	"segmentify/internal/storage"
+	segmentifyv1 "segmentify/pkg/api/segmentify/v1"
+)
+
+// fakeStorage knows the keys "admin" and "reader", segment A of the default
+// project and user 1 with a single history row. Segments read from the
+// primary database also include "primary".
+type fakeStorage struct{}
+
+func (fakeStorage) GetAPIKeyByHash(_ context.Context, keyHash string) (models.APIKey, error) {
+	for i, role := range []string{auth.RoleAdmin, auth.RoleReader} {
+		if auth.Hash(role) == keyHash {
+			return models.APIKey{ID: int64(i + 1), Name: role, Role: role}, nil
+		}
+	}
+	return models.APIKey{}, &storage.ErrAPIKeyNotFound{}
+}
+
+func (fakeStorage) GetProject(_ context.Context, slug string) (models.Project, error) {
+	if slug != models.DefaultProject {
+		return models.Project{}, &storage.ErrProjectNotFound{Slug: slug}
+	}
+	return models.Project{Slug: slug}, nil
+}
+
+func (fakeStorage) CreateSegment(_ context.Context, _ string, segment models.Segment) (models.Segment, error) {
+	if segment.Slug == "A" {
+		return models.Segment{}, &storage.ErrSegmentExists{Slug: segment.Slug}
+	}
+	return segment, nil
+}
+
+func (fakeStorage) PreviewCreateSegment(context.Context, string, models.Segment) (models.Preview, error) {
+	return models.Preview{}, nil
+}
+
+func (fakeStorage) GetSegment(_ context.Context, _, slug string) (models.Segment, error) {
+	if slug != "A" {
+		return models.Segment{}, &storage.ErrSegmentNotFound{Slug: slug}
+	}
+	return models.Segment{Slug: slug, Percent: 10}, nil
+}
+
+func (fakeStorage) DeleteSegment(context.Context, string, string) error {
+	return nil
+}
+
+func (fakeStorage) CreateUser(context.Context, string) (int64, error) {
+	return 1, nil
+}
+
+func (fakeStorage) GetUserSegments(ctx context.Context, _ string, id int64) ([]string, error) {
+	if id != 1 {
+		return nil, &storage.ErrUserNotFound{ID: id}
+	}
+	if storage.PrimaryRequired(ctx) {
+		return []string{"A", "primary"}, nil
+	}
+	return []string{"A"}, nil
+}
+
+func (fakeStorage) UpdateUserSegments(context.Context, string, int64, []models.SegmentToAdd, []models.SegmentToRemove) error {
+	return nil
+}
+
+func (fakeStorage) PreviewUserSegmentsUpdate(context.Context, string, int64, []models.SegmentToAdd, []models.SegmentToRemove) (models.Preview, error) {
+	return models.Preview{}, nil
+}
+
+func (fakeStorage) GetUserSegmentsHistory(_ context.Context, _ string, id int64, _ time.Time) ([][]string, error) {
+	return [][]string{{"1", "A", "add", "2023-09-12T15:49:26Z"}}, nil
+}
+
+func newClients(t *testing.T, limiters grpcserver.Limiters) (segmentifyv1.SegmentServiceClient, segmentifyv1.UserServiceClient) {
+	t.Helper()
+	return newClientsWithWriters(t, limiters, nil)
+}
+
+func newClientsWithWriters(
+	t *testing.T,
+	limiters grpcserver.Limiters,
+	writers *consistency.Writers,
+) (segmentifyv1.SegmentServiceClient, segmentifyv1.UserServiceClient) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := grpcserver.New(log, "", time.Second, fakeStorage{}, true, limiters, writers)
+
+	listener := bufconn.Listen(1 << 20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Serve(ctx, listener)
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+		<-done
+	})
+
+	return segmentifyv1.NewSegmentServiceClient(conn), segmentifyv1.NewUserServiceClient(conn)
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), grpcserver.MetadataAPIKey, key)
+}
+
+func TestSegmentService(t *testing.T) {
+	segments, _ := newClients(t, grpcserver.Limiters{})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  *segmentifyv1.CreateSegmentRequest
+		code codes.Code
+	}{
+		{
+			name: "Success",
+			ctx:  withKey(auth.RoleAdmin),
+			req:  &segmentifyv1.CreateSegmentRequest{Segment: &segmentifyv1.Segment{Slug: "B", Percent: 10}},
+			code: codes.OK,
+		},
+		{
+			name: "No api key",
+			ctx:  context.Background(),
+			req:  &segmentifyv1.CreateSegmentRequest{Segment: &segmentifyv1.Segment{Slug: "B"}},
+			code: codes.Unauthenticated,
+		},
+		{
+			name: "Role is not enough",
+			ctx:  withKey(auth.RoleReader),
+			req:  &segmentifyv1.CreateSegmentRequest{Segment: &segmentifyv1.Segment{Slug: "B"}},
+			code: codes.PermissionDenied,
+		},
+		{
+			name: "Invalid percent",
+			ctx:  withKey(auth.RoleAdmin),
+			req:  &segmentifyv1.CreateSegmentRequest{Segment: &segmentifyv1.Segment{Slug: "B", Percent: 101}},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "Segment exists",
+			ctx:  withKey(auth.RoleAdmin),
+			req:  &segmentifyv1.CreateSegmentRequest{Segment: &segmentifyv1.Segment{Slug: "A"}},
+			code: codes.AlreadyExists,
+		},
+		{
+			name: "Project not found",
+			ctx:  withKey(auth.RoleAdmin),
+			req:  &segmentifyv1.CreateSegmentRequest{Project: "other", Segment: &segmentifyv1.Segment{Slug: "B"}},
+			code: codes.NotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := segments.CreateSegment(tc.ctx, tc.req)
+			require.Equal(t, tc.code, status.Code(err))
+			if tc.code == codes.OK {
+				require.Equal(t, tc.req.Segment.Slug, resp.Segment.Slug)
+			}
+		})
+	}
+
+	_, err := segments.GetSegment(withKey(auth.RoleReader), &segmentifyv1.GetSegmentRequest{Slug: "C"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUserSegmentsHistory(t *testing.T) {
+	_, users := newClients(t, grpcserver.Limiters{})
+
+	stream, err := users.StreamUserSegmentsHistory(withKey(auth.RoleReader), &segmentifyv1.StreamUserSegmentsHistoryRequest{
+		UserId: 1,
+		Period: "2023-09",
+	})
+	require.NoError(t, err)
+
+	record, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "A", record.Segment)
+	require.Equal(t, "add", record.Operation)
+	require.Equal(t, time.Date(2023, 9, 12, 15, 49, 26, 0, time.UTC), record.CreatedAt.AsTime())
+
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRateLimit(t *testing.T) {
+	segments, _ := newClients(t, grpcserver.Limiters{
+		Address: ratelimit.New(ratelimit.Limit{Requests: 4, Period: time.Hour}, nil),
+		Client: ratelimit.New(
+			ratelimit.Limit{Requests: 100, Period: time.Hour},
+			map[string]ratelimit.Limit{"GET /segments/{slug}": {Requests: 1, Period: time.Hour}},
+		),
+	})
+
+	get := func(key string) codes.Code {
+		_, err := segments.GetSegment(withKey(key), &segmentifyv1.GetSegmentRequest{Slug: "A"})
+		return status.Code(err)
+	}
+
+	// Methods are limited by the rules of the matching HTTP routes, per key
+	require.Equal(t, codes.OK, get(auth.RoleReader))
+	require.Equal(t, codes.ResourceExhausted, get(auth.RoleReader))
+	require.Equal(t, codes.OK, get(auth.RoleAdmin))
+
+	// Invalid keys are limited per address before authentication
+	require.Equal(t, codes.Unauthenticated, get("invalid"))
+	require.Equal(t, codes.ResourceExhausted, get("invalid"))
+}
+
+func TestReadYourWrites(t *testing.T) {
+	_, users := newClientsWithWriters(t, grpcserver.Limiters{}, consistency.New(50*time.Millisecond))
+
+	primary := func(key string) bool {
+		res, err := users.GetUserSegments(withKey(key), &segmentifyv1.GetUserSegmentsRequest{UserId: 1})
+		require.NoError(t, err)
+		return len(res.Segments) == 2
+	}
+
+	require.False(t, primary(auth.RoleAdmin))
+
+	_, err := users.UpdateUserSegments(withKey(auth.RoleAdmin), &segmentifyv1.UpdateUserSegmentsRequest{
+		UserId:        1,
+		SegmentsToAdd: []*segmentifyv1.SegmentToAdd{{Slug: "A"}},
+	})
+	require.NoError(t, err)
+
+	// Only the client that changed data reads from the primary, until the
+	// window is over
+	require.True(t, primary(auth.RoleAdmin))
+	require.False(t, primary(auth.RoleReader))
+
+	time.Sleep(60 * time.Millisecond)
+	require.False(t, primary(auth.RoleAdmin))
+}
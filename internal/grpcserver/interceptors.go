@@ -0,0 +1,318 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/auth"
+	"segmentify/internal/consistency"
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/models"
+	"segmentify/internal/ratelimit"
+	"segmentify/internal/storage"
+	"segmentify/internal/tracing"
+	segmentifyv1 "segmentify/pkg/api/segmentify/v1"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys read from incoming calls.
+const (
+	MetadataAPIKey    = "x-api-key"
+	MetadataRequestID = "x-request-id"
+)
+
+// methodRoles are the roles required by every method, the same as for the
+// matching HTTP routes.
+var methodRoles = map[string]string{
+	segmentifyv1.SegmentService_CreateSegment_FullMethodName:          auth.RoleAdmin,
+	segmentifyv1.SegmentService_GetSegment_FullMethodName:             auth.RoleReader,
+	segmentifyv1.SegmentService_DeleteSegment_FullMethodName:          auth.RoleAdmin,
+	segmentifyv1.UserService_CreateUser_FullMethodName:                auth.RoleAssigner,
+	segmentifyv1.UserService_GetUserSegments_FullMethodName:           auth.RoleReader,
+	segmentifyv1.UserService_UpdateUserSegments_FullMethodName:        auth.RoleAssigner,
+	segmentifyv1.UserService_StreamUserSegmentsHistory_FullMethodName: auth.RoleReader,
+}
+
+// methodRoutes name the HTTP routes matching the methods, so calls are rate
+// limited by the same rules and from the same buckets as HTTP requests.
+var methodRoutes = map[string]string{
+	segmentifyv1.SegmentService_CreateSegment_FullMethodName:          "POST /segments",
+	segmentifyv1.SegmentService_GetSegment_FullMethodName:             "GET /segments/{slug}",
+	segmentifyv1.SegmentService_DeleteSegment_FullMethodName:          "DELETE /segments/{slug}",
+	segmentifyv1.UserService_CreateUser_FullMethodName:                "POST /users",
+	segmentifyv1.UserService_GetUserSegments_FullMethodName:           "GET /users/{id}/segments",
+	segmentifyv1.UserService_UpdateUserSegments_FullMethodName:        "PATCH /users/{id}/segments",
+	segmentifyv1.UserService_StreamUserSegmentsHistory_FullMethodName: "GET /users/{id}/download-segments-history",
+}
+
+// interceptors do for gRPC calls what the HTTP middlewares do for requests:
+// request IDs, tracing, logging, panic recovery, rate limiting,
+// authentication, the audit actor and read-your-writes.
+type interceptors struct {
+	log          *slog.Logger
+	apiKeyGetter interface {
+		GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error)
+	}
+	authenticate bool
+	limiters     Limiters
+	writers      *consistency.Writers
+}
+
+func (i *interceptors) unary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp any, err error) {
+	ctx, finish := i.begin(ctx, info.FullMethod)
+	defer func() { finish(recover(), &err) }()
+
+	if ctx, err = i.admit(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	ctx, wrote := i.route(ctx, info.FullMethod)
+	defer wrote()
+
+	return handler(ctx, req)
+}
+
+func (i *interceptors) stream(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	ctx, finish := i.begin(ss.Context(), info.FullMethod)
+	defer func() { finish(recover(), &err) }()
+
+	if ctx, err = i.admit(ctx, info.FullMethod); err != nil {
+		return err
+	}
+
+	ctx, wrote := i.route(ctx, info.FullMethod)
+	defer wrote()
+
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// begin sets up the request ID and the server span of a call. The returned
+// function must be deferred with the recovered value and the call error: it
+// turns panics into internal errors, then logs the call and ends the span.
+func (i *interceptors) begin(ctx context.Context, method string) (context.Context, func(any, *error)) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	reqID := first(md, MetadataRequestID)
+	if reqID == "" {
+		reqID = fmt.Sprintf("grpc-%06d", middleware.NextRequestID())
+	}
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, reqID)
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, span := tracing.Tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+			attribute.String("request_id", reqID),
+		),
+	)
+
+	log := i.log.With(
+		slog.String("method", method),
+		slog.String("request_id", reqID),
+	)
+	start := time.Now()
+
+	return ctx, func(recovered any, err *error) {
+		if recovered != nil {
+			log.ErrorContext(ctx, "panic in grpc call",
+				slog.Any("panic", recovered),
+				slog.String("stack", string(debug.Stack())),
+			)
+			*err = status.Error(codes.Internal, "internal error")
+		}
+
+		code := status.Code(*err)
+
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", code.String()))
+		if code == codes.Internal || code == codes.Unknown {
+			span.SetStatus(otelCodes.Error, code.String())
+		}
+		span.End()
+
+		log.InfoContext(ctx, "grpc call completed",
+			slog.String("code", code.String()),
+			slog.String("duration", time.Since(start).String()),
+		)
+	}
+}
+
+// admit rate limits the call per peer address, authorizes it, then rate
+// limits it per client, in the order of the HTTP middlewares.
+func (i *interceptors) admit(ctx context.Context, method string) (context.Context, error) {
+	addr := peerAddr(ctx)
+
+	if err := allow(i.limiters.Address, auth.AddressID(addr), ratelimit.DefaultRule); err != nil {
+		return ctx, err
+	}
+
+	ctx, err := i.authorize(ctx, method)
+	if err != nil {
+		return ctx, err
+	}
+
+	route, ok := methodRoutes[method]
+	if !ok {
+		route = ratelimit.DefaultRule
+	}
+	if err := allow(i.limiters.Client, auth.ClientID(ctx, addr), route); err != nil {
+		return ctx, err
+	}
+
+	return ctx, nil
+}
+
+// route sends the reads of a client that changed data within the window of
+// the writers to the primary database, like the consistency middleware. The
+// returned function must be deferred: after a call that changes data, it
+// starts the window.
+func (i *interceptors) route(ctx context.Context, method string) (context.Context, func()) {
+	route, ok := methodRoutes[method]
+	if i.writers == nil || !ok {
+		return ctx, func() {}
+	}
+
+	client := auth.ClientID(ctx, peerAddr(ctx))
+
+	if strings.HasPrefix(route, http.MethodGet+" ") {
+		if i.writers.WroteRecently(client) {
+			ctx = storage.WithPrimary(ctx)
+		}
+		return ctx, func() {}
+	}
+
+	return ctx, func() { i.writers.Wrote(client) }
+}
+
+// allow takes a token for a call of client to route. A nil limiter allows
+// every call.
+func allow(limiter *ratelimit.Limiter, client, route string) error {
+	if limiter == nil {
+		return nil
+	}
+
+	if res := limiter.Allow(client, route); !res.Allowed {
+		retryAfter := strconv.FormatInt(int64(math.Ceil(res.RetryAfter.Seconds())), 10)
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %ss", retryAfter)
+	}
+
+	return nil
+}
+
+// authorize authenticates the call with the API key from its metadata,
+// checks the role of the method and stores the key and the audit actor in
+// the context.
+func (i *interceptors) authorize(ctx context.Context, method string) (context.Context, error) {
+	key := models.APIKey{Name: "anonymous", Role: auth.RoleAdmin}
+
+	if i.authenticate {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		plain := first(md, MetadataAPIKey)
+		if plain == "" {
+			return ctx, status.Errorf(codes.Unauthenticated, "%s metadata is required", MetadataAPIKey)
+		}
+
+		var err error
+		if key, err = i.apiKeyGetter.GetAPIKeyByHash(ctx, auth.Hash(plain)); err != nil {
+			var errAPIKeyNotFound *storage.ErrAPIKeyNotFound
+
+			if errors.As(err, &errAPIKeyNotFound) {
+				return ctx, status.Error(codes.Unauthenticated, "api key is invalid or revoked")
+			}
+			i.log.ErrorContext(ctx, "failed to get api key", sl.Err(err))
+			return ctx, status.Error(codes.Internal, "failed to authenticate")
+		}
+	}
+
+	role, ok := methodRoles[method]
+	if !ok {
+		role = auth.RoleAdmin
+	}
+	if !auth.Allows(key.Role, role) {
+		return ctx, status.Errorf(codes.PermissionDenied, "role %s is required", role)
+	}
+
+	ctx = auth.WithAPIKey(ctx, key)
+	ctx = audit.WithActor(ctx, audit.Actor{
+		Name:      key.Name,
+		APIKeyID:  key.ID,
+		RequestID: middleware.GetReqID(ctx),
+	})
+
+	return ctx, nil
+}
+
+// serverStream replaces the context of a stream with the one set up by the
+// interceptors.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// metadataCarrier lets the trace context propagator read incoming metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	return first(metadata.MD(c), key)
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
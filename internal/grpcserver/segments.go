@@ -0,0 +1,91 @@
+package grpcserver
+
+import (
+	"context"
+
+	"segmentify/internal/models"
+	segmentifyv1 "segmentify/pkg/api/segmentify/v1"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type segmentService struct {
+	segmentifyv1.UnimplementedSegmentServiceServer
+	*Server
+}
+
+func (s *segmentService) CreateSegment(
+	ctx context.Context,
+	req *segmentifyv1.CreateSegmentRequest,
+) (*segmentifyv1.CreateSegmentResponse, error) {
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return nil, err
+	}
+
+	segment := models.Segment{
+		Slug:    req.GetSegment().GetSlug(),
+		Percent: req.GetSegment().GetPercent(),
+	}
+
+	if err := validator.New().Struct(segment); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	segment, err = s.storage.CreateSegment(ctx, project, segment)
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed to create segment", err)
+	}
+
+	return &segmentifyv1.CreateSegmentResponse{Segment: toSegment(segment)}, nil
+}
+
+func (s *segmentService) GetSegment(
+	ctx context.Context,
+	req *segmentifyv1.GetSegmentRequest,
+) (*segmentifyv1.GetSegmentResponse, error) {
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+
+	segment, err := s.storage.GetSegment(ctx, project, req.GetSlug())
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed to get segment", err)
+	}
+
+	return &segmentifyv1.GetSegmentResponse{Segment: toSegment(segment)}, nil
+}
+
+func (s *segmentService) DeleteSegment(
+	ctx context.Context,
+	req *segmentifyv1.DeleteSegmentRequest,
+) (*segmentifyv1.DeleteSegmentResponse, error) {
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+
+	if err := s.storage.DeleteSegment(ctx, project, req.GetSlug()); err != nil {
+		return nil, s.toStatus(ctx, "failed to delete segment", err)
+	}
+
+	return &segmentifyv1.DeleteSegmentResponse{}, nil
+}
+
+func toSegment(segment models.Segment) *segmentifyv1.Segment {
+	return &segmentifyv1.Segment{
+		Slug:    segment.Slug,
+		Percent: segment.Percent,
+	}
+}
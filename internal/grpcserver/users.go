@@ -0,0 +1,141 @@
+package grpcserver
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"segmentify/internal/models"
+	segmentifyv1 "segmentify/pkg/api/segmentify/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type userService struct {
+	segmentifyv1.UnimplementedUserServiceServer
+	*Server
+}
+
+func (s *userService) CreateUser(
+	ctx context.Context,
+	req *segmentifyv1.CreateUserRequest,
+) (*segmentifyv1.CreateUserResponse, error) {
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.storage.CreateUser(ctx, project)
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed to create user", err)
+	}
+
+	return &segmentifyv1.CreateUserResponse{Id: id}, nil
+}
+
+func (s *userService) GetUserSegments(
+	ctx context.Context,
+	req *segmentifyv1.GetUserSegmentsRequest,
+) (*segmentifyv1.GetUserSegmentsResponse, error) {
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := s.storage.GetUserSegments(ctx, project, req.GetUserId())
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed to get user segments", err)
+	}
+
+	return &segmentifyv1.GetUserSegmentsResponse{UserId: req.GetUserId(), Segments: segments}, nil
+}
+
+func (s *userService) UpdateUserSegments(
+	ctx context.Context,
+	req *segmentifyv1.UpdateUserSegmentsRequest,
+) (*segmentifyv1.UpdateUserSegmentsResponse, error) {
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return nil, err
+	}
+
+	toRemove := make(map[string]bool, len(req.GetSegmentsToRemove()))
+	segmentsToRemove := make([]models.SegmentToRemove, 0, len(req.GetSegmentsToRemove()))
+	for _, slug := range req.GetSegmentsToRemove() {
+		if slug == "" {
+			return nil, status.Error(codes.InvalidArgument, "segments_to_remove must not contain empty slugs")
+		}
+		toRemove[slug] = true
+		segmentsToRemove = append(segmentsToRemove, models.SegmentToRemove{Slug: slug})
+	}
+
+	segmentsToAdd := make([]models.SegmentToAdd, 0, len(req.GetSegmentsToAdd()))
+	for _, segment := range req.GetSegmentsToAdd() {
+		if segment.GetSlug() == "" {
+			return nil, status.Error(codes.InvalidArgument, "segments_to_add must not contain empty slugs")
+		}
+		if toRemove[segment.GetSlug()] {
+			return nil, status.Error(codes.InvalidArgument, "segments_to_add and segments_to_remove overlap")
+		}
+
+		segmentToAdd := models.SegmentToAdd{Slug: segment.GetSlug()}
+		if segment.GetExpireAt() != nil {
+			segmentToAdd.ExpireAt = segment.GetExpireAt().AsTime()
+		}
+		segmentsToAdd = append(segmentsToAdd, segmentToAdd)
+	}
+
+	if err := s.storage.UpdateUserSegments(ctx, project, req.GetUserId(), segmentsToAdd, segmentsToRemove); err != nil {
+		return nil, s.toStatus(ctx, "failed to update user segments", err)
+	}
+
+	return &segmentifyv1.UpdateUserSegmentsResponse{}, nil
+}
+
+func (s *userService) StreamUserSegmentsHistory(
+	req *segmentifyv1.StreamUserSegmentsHistoryRequest,
+	stream segmentifyv1.UserService_StreamUserSegmentsHistoryServer,
+) error {
+	ctx := stream.Context()
+
+	project, err := s.project(ctx, req.GetProject())
+	if err != nil {
+		return err
+	}
+
+	period, err := time.Parse("2006-01", req.GetPeriod())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "period should be formatted like 'yyyy-mm'")
+	}
+
+	report, err := s.storage.GetUserSegmentsHistory(ctx, project, req.GetUserId(), period)
+	if err != nil {
+		return s.toStatus(ctx, "failed to get user segments history", err)
+	}
+
+	// Rows are user_id, segment, operation and created_at, as in the CSV
+	// report of the HTTP API
+	for _, row := range report {
+		userID, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return s.toStatus(ctx, "failed to parse user segments history", err)
+		}
+		createdAt, err := time.Parse(time.RFC3339, row[3])
+		if err != nil {
+			return s.toStatus(ctx, "failed to parse user segments history", err)
+		}
+
+		if err := stream.Send(&segmentifyv1.StreamUserSegmentsHistoryResponse{
+			UserId:    userID,
+			Segment:   row[1],
+			Operation: row[2],
+			CreatedAt: timestamppb.New(createdAt),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// checkTimeout bounds a single check so one hanging dependency cannot stall
+// the probe past the orchestrator's own timeout.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable; a nil error means healthy.
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type Health struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func New() *Health {
+	return &Health{checks: map[string]Check{}}
+}
+
+// Add registers a readiness check under name, replacing any existing one.
+func (h *Health) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks[name] = check
+}
+
+// Shutdown marks the service as going away, so readiness fails from now on
+// while in-flight requests are still being drained.
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live reports whether the process is up. It never touches dependencies.
+func (h *Health) Live() Report {
+	return Report{Status: StatusOK}
+}
+
+// Ready runs every registered check concurrently and reports the result.
+func (h *Health) Ready(ctx context.Context) Report {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		i, check := i, check
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: map[string]CheckResult{}}
+
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+
+	if h.shuttingDown.Load() {
+		report.Status = StatusUnavailable
+		report.Checks["shutdown"] = CheckResult{
+			Status:   StatusUnavailable,
+			Duration: time.Duration(0).String(),
+			Error:    "service is shutting down",
+		}
+	}
+
+	return report
+}
+
+func run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	t1 := time.Now()
+	err := check(ctx)
+	result := CheckResult{Status: StatusOK, Duration: time.Since(t1).String()}
+
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,75 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/health"
+)
+
+func TestReady(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("connection refused") }
+
+	cases := []struct {
+		name         string
+		checks       map[string]health.Check
+		shuttingDown bool
+		status       string
+		failed       []string
+	}{
+		{
+			name:   "All checks pass",
+			checks: map[string]health.Check{"postgres": ok, "scheduler": ok},
+			status: health.StatusOK,
+		},
+		{
+			name:   "One check fails",
+			checks: map[string]health.Check{"postgres": failing, "scheduler": ok},
+			status: health.StatusUnavailable,
+			failed: []string{"postgres"},
+		},
+		{
+			name:         "Shutting down",
+			checks:       map[string]health.Check{"postgres": ok},
+			shuttingDown: true,
+			status:       health.StatusUnavailable,
+			failed:       []string{"shutdown"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := health.New()
+			for name, check := range tc.checks {
+				h.Add(name, check)
+			}
+			if tc.shuttingDown {
+				h.Shutdown()
+			}
+
+			require.Equal(t, health.StatusOK, h.Live().Status)
+
+			report := h.Ready(context.Background())
+			require.Equal(t, tc.status, report.Status)
+
+			for name, result := range report.Checks {
+				require.NotEmpty(t, result.Duration)
+				if slices.Contains(tc.failed, name) {
+					require.Equal(t, health.StatusUnavailable, result.Status)
+					require.NotEmpty(t, result.Error)
+				} else {
+					require.Equal(t, health.StatusOK, result.Status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	Name string `json:"name" validate:"required"`
+	Role string `json:"role" validate:"required,oneof=reader assigner admin" example:"reader"`
+	// Project binds the key to a single project. Keys without a project
+	// have access to all projects.
+	Project string `json:"project,omitempty" example:"default"`
+}
+
+type Response struct {
+	models.APIKey
+	// Key is the plain API key. It is returned only once and never stored.
+	Key string `json:"key"`
+}
+
+type APIKeyCreator interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error)
+}
+
+// @Summary	Creating an API key
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Param		body	body		Request	true	"API key"
+// @Success	201		{object}	Response
+// @Failure	400		{object}	resp.ErrResponse
+// @Failure	401		{object}	resp.ErrResponse
+// @Failure	403		{object}	resp.ErrResponse
+// @Failure	404		{object}	resp.ErrResponse
+// @Failure	422		{object}	resp.ErrResponse
+// @Failure	429		{object}	resp.ErrResponse
+// @Failure	500		{object}	resp.ErrResponse
+// @Router		/admin/api-keys [post]
+func New(log *slog.Logger, apiKeyCreator APIKeyCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				render.Render(w, r, resp.ErrInvalidRequest("request body is empty"))
+				return
+			}
+			render.Render(w, r, resp.ErrInvalidRequest("failed to decode request body"))
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			render.Render(w, r, resp.ValidationError(validateErr))
+			return
+		}
+
+		plain, prefix, hash, err := auth.Generate()
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate api key", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to create api key"))
+			return
+		}
+
+		dbKey, err := apiKeyCreator.CreateAPIKey(r.Context(), models.APIKey{
+			Name:    req.Name,
+			Prefix:  prefix,
+			Role:    req.Role,
+			Project: req.Project,
+		}, hash)
+		if err != nil {
+			var errProjectNotFound *storage.ErrProjectNotFound
+
+			if errors.As(err, &errProjectNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errProjectNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to create api key", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to create api key"))
+			return
+		}
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{APIKey: dbKey, Key: plain})
+	}
+}
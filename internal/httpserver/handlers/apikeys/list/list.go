@@ -0,0 +1,47 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type APIKeysLister interface {
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+}
+
+// @Summary	Listing API keys
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Success	200	{array}		models.APIKey
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/admin/api-keys [get]
+func New(log *slog.Logger, apiKeysLister APIKeysLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		keys, err := apiKeysLister.ListAPIKeys(r.Context())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list api keys", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to list api keys"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, keys)
+	}
+}
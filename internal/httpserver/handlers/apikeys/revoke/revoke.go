@@ -0,0 +1,63 @@
+package revoke
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type APIKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+// @Summary	Revoking an API key
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Param		id	path	string	true	"API key ID"
+// @Success	204
+// @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/admin/api-keys/{id} [delete]
+func New(log *slog.Logger, apiKeyRevoker APIKeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			render.Render(w, r, resp.ErrInvalidRequest("api key id is invalid"))
+			return
+		}
+
+		if err := apiKeyRevoker.RevokeAPIKey(r.Context(), id); err != nil {
+			var errAPIKeyNotFound *storage.ErrAPIKeyNotFound
+
+			if errors.As(err, &errAPIKeyNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errAPIKeyNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to revoke api key", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to revoke api key"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,116 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Response struct {
+	Entries []models.AuditEntry `json:"entries"`
+	// NextCursor is passed as the cursor query parameter to get the next
+	// page. It is omitted on the last page.
+	NextCursor int64 `json:"next_cursor,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=AuditLogGetter
+type AuditLogGetter interface {
+	GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+// @Summary	Listing the audit log of administrative changes, newest first
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Param		project		query		string	false	"Project slug"
+// @Param		actor		query		string	false	"API key name"
+// @Param		action		query		string	false	"Action"	Enums(create, delete, revoke)
+// @Param		entity		query		string	false	"Entity"	Enums(project, segment, api_key)
+// @Param		entity_id	query		string	false	"Entity ID or slug"
+// @Param		from		query		string	false	"Inclusive lower bound, RFC 3339"	example(2023-09-01T00:00:00Z)
+// @Param		to			query		string	false	"Exclusive upper bound, RFC 3339"	example(2023-10-01T00:00:00Z)
+// @Param		cursor		query		int		false	"Cursor from the previous page"
+// @Param		limit		query		int		false	"Page size, 50 by default, 500 at most"
+// @Success	200			{object}	Response
+// @Failure	400			{object}	resp.ErrResponse
+// @Failure	401			{object}	resp.ErrResponse
+// @Failure	403			{object}	resp.ErrResponse
+// @Failure	429			{object}	resp.ErrResponse
+// @Failure	500			{object}	resp.ErrResponse
+// @Router		/audit [get]
+func New(log *slog.Logger, auditLogGetter AuditLogGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.audit.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		query := r.URL.Query()
+
+		filter := models.AuditFilter{
+			Project:  query.Get("project"),
+			Actor:    query.Get("actor"),
+			Action:   query.Get("action"),
+			Entity:   query.Get("entity"),
+			EntityID: query.Get("entity_id"),
+			Limit:    defaultLimit,
+		}
+
+		var err error
+
+		if v := query.Get("from"); v != "" {
+			if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'from'. Should be formatted as RFC 3339"))
+				return
+			}
+		}
+		if v := query.Get("to"); v != "" {
+			if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'to'. Should be formatted as RFC 3339"))
+				return
+			}
+		}
+		if v := query.Get("cursor"); v != "" {
+			if filter.Cursor, err = strconv.ParseInt(v, 10, 64); err != nil || filter.Cursor <= 0 {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'cursor'"))
+				return
+			}
+		}
+		if v := query.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.ParseInt(v, 10, 64); err != nil || filter.Limit <= 0 || filter.Limit > maxLimit {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'limit'. Should be between 1 and 500"))
+				return
+			}
+		}
+
+		entries, err := auditLogGetter.GetAuditLog(r.Context(), filter)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get audit log", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to get audit log"))
+			return
+		}
+
+		res := Response{Entries: entries}
+		if int64(len(entries)) == filter.Limit {
+			res.NextCursor = entries[len(entries)-1].ID
+		}
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, res)
+	}
+}
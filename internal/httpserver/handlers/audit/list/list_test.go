@@ -0,0 +1,97 @@
+package list_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/httpserver/handlers/audit/list"
+	"segmentify/internal/httpserver/handlers/audit/list/mocks"
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+	"segmentify/internal/models"
+)
+
+func TestListHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		query      string
+		filter     models.AuditFilter
+		entries    []models.AuditEntry
+		respCode   int
+		nextCursor int64
+	}{
+		{
+			name:     "Defaults",
+			query:    "",
+			filter:   models.AuditFilter{Limit: 50},
+			entries:  []models.AuditEntry{{ID: 3}, {ID: 2}},
+			respCode: http.StatusOK,
+		},
+		{
+			name:  "Filters And Full Page",
+			query: "?project=mobile&entity=segment&action=delete&from=2023-09-01T00:00:00Z&cursor=10&limit=2",
+			filter: models.AuditFilter{
+				Project: "mobile",
+				Entity:  "segment",
+				Action:  "delete",
+				From:    time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+				Cursor:  10,
+				Limit:   2,
+			},
+			entries:    []models.AuditEntry{{ID: 9}, {ID: 7}},
+			respCode:   http.StatusOK,
+			nextCursor: 7,
+		},
+		{
+			name:     "Invalid From",
+			query:    "?from=yesterday",
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Limit Too Large",
+			query:    "?limit=501",
+			respCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			auditLogGetterMock := mocks.NewAuditLogGetter(t)
+
+			if tc.respCode == http.StatusOK {
+				auditLogGetterMock.On("GetAuditLog", mock.Anything, tc.filter).
+					Return(tc.entries, nil).
+					Once()
+			}
+
+			handler := list.New(slogdiscard.NewDiscardLogger(), auditLogGetterMock)
+
+			req, err := http.NewRequest(http.MethodGet, "/audit"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode != http.StatusOK {
+				return
+			}
+
+			var resp list.Response
+
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Len(t, resp.Entries, len(tc.entries))
+			require.Equal(t, tc.nextCursor, resp.NextCursor)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package changes
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+type ChangesGetter interface {
+	GetEventsAfter(ctx context.Context, project string, afterID, limit int64) ([]models.OutboxEvent, error)
+	GetLastEventID(ctx context.Context) (int64, error)
+}
+
+type Response struct {
+	Events []models.OutboxEvent `json:"events"`
+	// LastEventID is passed as the after query parameter to get the next
+	// changes.
+	LastEventID int64 `json:"last_event_id"`
+}
+
+// @Summary		Polling segment and membership events
+// @Description	Returns the events after the given event ID, oldest first. It is the polling counterpart of GET /events.
+// @Description	Without the after query param no events are returned and last_event_id is the latest event, to start polling from now on.
+// @Tags			events
+// @Security		ApiKeyAuth
+// @Param			after	query		int	false	"Return events after this event"
+// @Param			limit	query		int	false	"Page size, 100 by default, 1000 at most"
+// @Success		200		{object}	Response
+// @Failure		400		{object}	resp.ErrResponse
+// @Failure		401		{object}	resp.ErrResponse
+// @Failure		403		{object}	resp.ErrResponse
+// @Failure		429		{object}	resp.ErrResponse
+// @Failure		500		{object}	resp.ErrResponse
+// @Router			/events/changes [get]
+func New(log *slog.Logger, changesGetter ChangesGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.events.changes.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		query := r.URL.Query()
+
+		var err error
+
+		limit := int64(defaultLimit)
+		if v := query.Get("limit"); v != "" {
+			if limit, err = strconv.ParseInt(v, 10, 64); err != nil || limit <= 0 || limit > maxLimit {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'limit'. Should be between 1 and 1000"))
+				return
+			}
+		}
+
+		res := Response{Events: []models.OutboxEvent{}}
+
+		v := query.Get("after")
+		if v == "" {
+			if res.LastEventID, err = changesGetter.GetLastEventID(r.Context()); err != nil {
+				log.ErrorContext(r.Context(), "failed to get last event id", sl.Err(err))
+				render.Render(w, r, resp.ErrInternal("failed to get last event id"))
+				return
+			}
+			render.Status(r, http.StatusOK)
+			render.JSON(w, r, res)
+			return
+		}
+
+		if res.LastEventID, err = strconv.ParseInt(v, 10, 64); err != nil || res.LastEventID < 0 {
+			render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'after'"))
+			return
+		}
+
+		res.Events, err = changesGetter.GetEventsAfter(r.Context(), project.SlugFromContext(r.Context()), res.LastEventID, limit)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get events", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to get events"))
+			return
+		}
+		if len(res.Events) > 0 {
+			res.LastEventID = res.Events[len(res.Events)-1].ID
+		}
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, res)
+	}
+}
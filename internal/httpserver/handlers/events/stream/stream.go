@@ -0,0 +1,152 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"segmentify/internal/events"
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type EventStreamer interface {
+	Subscribe(filter events.Filter) *events.Subscription
+	Replay(ctx context.Context, filter events.Filter, afterID int64, send func(models.OutboxEvent) error) (int64, error)
+}
+
+// @Summary		Streaming segment and membership events
+// @Description	Server-Sent Events stream of segment.created, segment.deleted, segment.user_added, segment.user_removed and snapshot.imported events.
+// @Description	Every event has the outbox sequence number as its id; send it back in the Last-Event-ID header (or the last_event_id query param) to resume after it.
+// @Description	Filtering by user_id keeps membership events of the user and segment lifecycle events. snapshot.imported passes every filter.
+// @Tags			events
+// @Security		ApiKeyAuth
+// @Produce		text/event-stream
+// @Param			user_id			query		int		false	"User ID"
+// @Param			segment			query		string	false	"Segment slug"
+// @Param			last_event_id	query		int		false	"Resume after this event"
+// @Param			Last-Event-ID	header		int		false	"Resume after this event"
+// @Success		200				{string}	string	"Event stream"
+// @Failure		400				{object}	resp.ErrResponse
+// @Failure		401				{object}	resp.ErrResponse
+// @Failure		403				{object}	resp.ErrResponse
+// @Failure		429				{object}	resp.ErrResponse
+// @Failure		500				{object}	resp.ErrResponse
+// @Router			/events [get]
+func New(log *slog.Logger, eventStreamer EventStreamer, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.events.stream.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		query := r.URL.Query()
+
+		filter := events.Filter{
+			Project: project.SlugFromContext(r.Context()),
+			Segment: query.Get("segment"),
+		}
+
+		var err error
+
+		if v := query.Get("user_id"); v != "" {
+			if filter.UserID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.UserID <= 0 {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'user_id'"))
+				return
+			}
+		}
+
+		var lastID int64
+		resume := false
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = query.Get("last_event_id")
+		}
+		if lastEventID != "" {
+			if lastID, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || lastID < 0 {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid Last-Event-ID"))
+				return
+			}
+			resume = true
+		}
+
+		rc := http.NewResponseController(w)
+
+		// Streams outlive the server write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.ErrorContext(r.Context(), "failed to reset write deadline", sl.Err(err))
+		}
+
+		// Subscribe before replaying, so no event falls between the two
+		sub := eventStreamer.Subscribe(filter)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(event models.OutboxEvent) error {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Payload); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		if err := rc.Flush(); err != nil {
+			log.ErrorContext(r.Context(), "failed to flush event stream", sl.Err(err))
+			return
+		}
+
+		if resume {
+			if lastID, err = eventStreamer.Replay(r.Context(), filter, lastID, send); err != nil {
+				if r.Context().Err() == nil {
+					log.ErrorContext(r.Context(), "failed to replay events", sl.Err(err))
+				}
+				return
+			}
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				// Comments keep proxies from closing an idle stream
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if event.ID <= lastID {
+					continue
+				}
+				if err := send(event); err != nil {
+					return
+				}
+				lastID = event.ID
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package live
+
+import (
+	"net/http"
+
+	"segmentify/internal/health"
+
+	"github.com/go-chi/render"
+)
+
+type LivenessChecker interface {
+	Live() health.Report
+}
+
+// @Summary	Checking that the process is alive
+// @Tags		health
+// @Success	200	{object}	health.Report
+// @Router		/healthz [get]
+func New(livenessChecker LivenessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, livenessChecker.Live())
+	}
+}
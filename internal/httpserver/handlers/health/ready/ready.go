@@ -0,0 +1,41 @@
+package ready
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/health"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type ReadinessChecker interface {
+	Ready(ctx context.Context) health.Report
+}
+
+// @Summary	Checking that the service can serve requests
+// @Tags		health
+// @Success	200	{object}	health.Report
+// @Failure	503	{object}	health.Report
+// @Router		/readyz [get]
+func New(log *slog.Logger, readinessChecker ReadinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.ready.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		report := readinessChecker.Ready(r.Context())
+		if report.Status != health.StatusOK {
+			log.WarnContext(r.Context(), "service is not ready", slog.Any("checks", report.Checks))
+			render.Status(r, http.StatusServiceUnavailable)
+		} else {
+			render.Status(r, http.StatusOK)
+		}
+		render.JSON(w, r, report)
+	}
+}
@@ -0,0 +1,47 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type JobsLister interface {
+	Jobs(ctx context.Context) ([]models.Job, error)
+}
+
+// @Summary	Listing scheduled jobs
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Success	200	{array}		models.Job
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/admin/jobs [get]
+func New(log *slog.Logger, jobsLister JobsLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.jobs.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		jobs, err := jobsLister.Jobs(r.Context())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list jobs", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to list jobs"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, jobs)
+	}
+}
@@ -0,0 +1,66 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type JobTrigger interface {
+	Trigger(ctx context.Context, name string) (models.JobRun, error)
+}
+
+// @Summary	Running a job manually
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Param		name	path		string	true	"Job name"
+// @Success	200		{object}	models.JobRun
+// @Failure	401		{object}	resp.ErrResponse
+// @Failure	403		{object}	resp.ErrResponse
+// @Failure	404		{object}	resp.ErrResponse
+// @Failure	409		{object}	resp.ErrResponse
+// @Failure	429		{object}	resp.ErrResponse
+// @Failure	500		{object}	resp.ErrResponse
+// @Router		/admin/jobs/{name}/run [post]
+func New(log *slog.Logger, jobTrigger JobTrigger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.jobs.run.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		name := chi.URLParam(r, "name")
+
+		run, err := jobTrigger.Trigger(r.Context(), name)
+		if err != nil {
+			var errJobNotFound *scheduler.ErrJobNotFound
+			var errJobLocked *scheduler.ErrJobLocked
+
+			if errors.As(err, &errJobNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errJobNotFound.Error()))
+				return
+			}
+			if errors.As(err, &errJobLocked) {
+				render.Render(w, r, resp.ErrConflict(errJobLocked.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to run job", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to run job"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, run)
+	}
+}
@@ -0,0 +1,78 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type ProjectCreator interface {
+	CreateProject(ctx context.Context, project models.Project) (models.Project, error)
+}
+
+// @Summary	Creating a project
+// @Tags		projects
+// @Security	ApiKeyAuth
+// @Param		body	body		models.Project	true	"Project"
+// @Success	201		{object}	models.Project
+// @Failure	400		{object}	resp.ErrResponse
+// @Failure	401		{object}	resp.ErrResponse
+// @Failure	403		{object}	resp.ErrResponse
+// @Failure	409		{object}	resp.ErrResponse
+// @Failure	422		{object}	resp.ErrResponse
+// @Failure	429		{object}	resp.ErrResponse
+// @Failure	500		{object}	resp.ErrResponse
+// @Router		/projects [post]
+func New(log *slog.Logger, projectCreator ProjectCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.projects.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req models.Project
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				render.Render(w, r, resp.ErrInvalidRequest("request body is empty"))
+				return
+			}
+			render.Render(w, r, resp.ErrInvalidRequest("failed to decode request body"))
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			render.Render(w, r, resp.ValidationError(validateErr))
+			return
+		}
+
+		dbProject, err := projectCreator.CreateProject(r.Context(), req)
+		if err != nil {
+			var errProjectExists *storage.ErrProjectExists
+
+			if errors.As(err, &errProjectExists) {
+				render.Render(w, r, resp.ErrConflict(errProjectExists.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to create project", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to create project"))
+			return
+		}
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, dbProject)
+	}
+}
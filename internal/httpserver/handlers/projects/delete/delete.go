@@ -0,0 +1,68 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type ProjectDeleter interface {
+	DeleteProject(ctx context.Context, slug string) error
+}
+
+// @Summary	Deleting a project with all of its segments, users and API keys
+// @Tags		projects
+// @Security	ApiKeyAuth
+// @Param		project	path	string	true	"Project slug"
+// @Success	204
+// @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/projects/{project} [delete]
+func New(log *slog.Logger, projectDeleter ProjectDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.projects.delete.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		slug := chi.URLParam(r, "project")
+		if slug == "" {
+			render.Render(w, r, resp.ErrInvalidRequest("project slug is invalid"))
+			return
+		}
+
+		if slug == models.DefaultProject {
+			render.Render(w, r, resp.ErrInvalidRequest("default project can not be deleted"))
+			return
+		}
+
+		if err := projectDeleter.DeleteProject(r.Context(), slug); err != nil {
+			var errProjectNotFound *storage.ErrProjectNotFound
+
+			if errors.As(err, &errProjectNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errProjectNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to delete project", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to delete project"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
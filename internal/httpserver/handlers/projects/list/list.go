@@ -0,0 +1,47 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type ProjectsLister interface {
+	ListProjects(ctx context.Context) ([]models.Project, error)
+}
+
+// @Summary	Listing projects
+// @Tags		projects
+// @Security	ApiKeyAuth
+// @Success	200	{array}		models.Project
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/projects [get]
+func New(log *slog.Logger, projectsLister ProjectsLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.projects.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		projects, err := projectsLister.ListProjects(r.Context())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list projects", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to list projects"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, projects)
+	}
+}
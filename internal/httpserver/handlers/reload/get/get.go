@@ -0,0 +1,45 @@
+package get
+
+import (
+	"log/slog"
+	"net/http"
+
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/reload"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type LastReloadGetter interface {
+	LastReload() (reload.Result, bool)
+}
+
+// @Summary	Getting the result of the last config reload
+// @Tags		admin
+// @Security	ApiKeyAuth
+// @Success	200	{object}	reload.Result
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Router		/admin/config/reload [get]
+func New(log *slog.Logger, lastReloadGetter LastReloadGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reload.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		res, ok := lastReloadGetter.LastReload()
+		if !ok {
+			log.DebugContext(r.Context(), "config has not been reloaded")
+			render.Render(w, r, resp.ErrNotFound("config has not been reloaded yet"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, res)
+	}
+}
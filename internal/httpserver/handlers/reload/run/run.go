@@ -0,0 +1,47 @@
+package run
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/reload"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Reloader interface {
+	Reload(ctx context.Context, trigger string) reload.Result
+}
+
+// @Summary		Reloading the config
+// @Description	Reloads the config and applies the settings that can change at runtime. If the config is invalid, nothing is applied.
+// @Tags			admin
+// @Security		ApiKeyAuth
+// @Success		200	{object}	reload.Result
+// @Failure		401	{object}	resp.ErrResponse
+// @Failure		403	{object}	resp.ErrResponse
+// @Failure		422	{object}	resp.ErrResponse
+// @Failure		429	{object}	resp.ErrResponse
+// @Router			/admin/config/reload [post]
+func New(log *slog.Logger, reloader Reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reload.run.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		res := reloader.Reload(r.Context(), reload.TriggerAPI)
+		if res.Status != reload.StatusOK {
+			log.WarnContext(r.Context(), "failed to reload config", slog.String("error", res.Error))
+			render.Render(w, r, resp.ErrRender("failed to reload config: "+res.Error))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, res)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 	"segmentify/internal/models"
@@ -19,26 +21,43 @@ import (
 
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=SegmentCreator
 type SegmentCreator interface {
-	CreateSegment(ctx context.Context, segment models.Segment) (models.Segment, error)
+	CreateSegment(ctx context.Context, project string, segment models.Segment) (models.Segment, error)
+	PreviewCreateSegment(ctx context.Context, project string, segment models.Segment) (models.Preview, error)
 }
 
-// @Summary	Creating a segment
-// @Tags		segments
-// @Param		body	body		models.Segment	true	"Segment"
-// @Success	201		{object}	models.Segment
-// @Failure	400		{object}	resp.ErrResponse
-// @Failure	422		{object}	resp.ErrResponse
-// @Failure	500		{object}	resp.ErrResponse
-// @Router		/segments [post]
-func New(ctx context.Context, log *slog.Logger, segmentCreator SegmentCreator) http.HandlerFunc {
+// @Summary		Creating a segment
+// @Description	With dry_run=true the segment is created in a transaction that is rolled back, and the users the roll-out would add it to are returned.
+// @Tags			segments
+// @Security		ApiKeyAuth
+// @Param			body	body		models.Segment	true	"Segment"
+// @Param			dry_run	query		bool			false	"Preview the creation without applying it"
+// @Success		200		{object}	models.Preview
+// @Success		201		{object}	models.Segment
+// @Failure		400		{object}	resp.ErrResponse
+// @Failure		401		{object}	resp.ErrResponse
+// @Failure		403		{object}	resp.ErrResponse
+// @Failure		422		{object}	resp.ErrResponse
+// @Failure		429		{object}	resp.ErrResponse
+// @Failure		500		{object}	resp.ErrResponse
+// @Router			/segments [post]
+func New(log *slog.Logger, segmentCreator SegmentCreator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.segments.create.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		dryRun := false
+		if s := r.URL.Query().Get("dry_run"); s != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(s); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'dry_run'. Should be 'true' or 'false'"))
+				return
+			}
+		}
+
 		var req models.Segment
 
 		if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -56,7 +75,19 @@ func New(ctx context.Context, log *slog.Logger, segmentCreator SegmentCreator) h
 			return
 		}
 
-		dbSegment, err := segmentCreator.CreateSegment(ctx, req)
+		if dryRun {
+			preview, err := segmentCreator.PreviewCreateSegment(r.Context(), project.SlugFromContext(r.Context()), req)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to preview segment creation", sl.Err(err))
+				render.Render(w, r, resp.ErrInternal("failed to preview segment creation"))
+				return
+			}
+			render.Status(r, http.StatusOK)
+			render.JSON(w, r, preview)
+			return
+		}
+
+		dbSegment, err := segmentCreator.CreateSegment(r.Context(), project.SlugFromContext(r.Context()), req)
 		if err != nil {
 			var errSegmentExists *storage.ErrSegmentExists
 
@@ -64,7 +95,7 @@ func New(ctx context.Context, log *slog.Logger, segmentCreator SegmentCreator) h
 				render.Render(w, r, resp.ErrInvalidRequest(errSegmentExists.Error()))
 				return
 			}
-			log.Error("failed to create segment", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to create segment", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to create segment"))
 			return
 		}
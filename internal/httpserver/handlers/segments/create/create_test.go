@@ -2,13 +2,13 @@ package create_test
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"segmentify/internal/httpserver/handlers/segments/create"
@@ -47,12 +47,12 @@ func TestCreateHandler(t *testing.T) {
 			segmentCreatorMock := mocks.NewSegmentCreator(t)
 
 			if tc.respError == "" || tc.mockError != nil {
-				segmentCreatorMock.On("CreateSegment", context.Background(), models.Segment{Slug: tc.slug}).
+				segmentCreatorMock.On("CreateSegment", mock.Anything, models.DefaultProject, models.Segment{Slug: tc.slug}).
 					Return(models.Segment{Slug: tc.slug}, tc.mockError).
 					Once()
 			}
 
-			handler := create.New(context.Background(), slogdiscard.NewDiscardLogger(), segmentCreatorMock)
+			handler := create.New(slogdiscard.NewDiscardLogger(), segmentCreatorMock)
 
 			input := fmt.Sprintf(`{"slug": "%s"}`, tc.slug)
 
@@ -72,3 +72,56 @@ func TestCreateHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateHandlerDryRun(t *testing.T) {
+	cases := []struct {
+		name     string
+		dryRun   string
+		respCode int
+	}{
+		{
+			name:     "Preview",
+			dryRun:   "true",
+			respCode: http.StatusOK,
+		},
+		{
+			name:     "Invalid dry_run",
+			dryRun:   "maybe",
+			respCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			segmentCreatorMock := mocks.NewSegmentCreator(t)
+
+			preview := models.Preview{UsersAffected: []int64{1, 2}, UsersAffectedCount: 2, Added: 2}
+			if tc.respCode == http.StatusOK {
+				segmentCreatorMock.On("PreviewCreateSegment", mock.Anything, models.DefaultProject, models.Segment{Slug: "A", Percent: 50}).
+					Return(preview, nil).
+					Once()
+			}
+
+			handler := create.New(slogdiscard.NewDiscardLogger(), segmentCreatorMock)
+
+			req, err := http.NewRequest(http.MethodPost, "/segments?dry_run="+tc.dryRun, bytes.NewReader([]byte(`{"slug": "A", "percent": 50}`)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				var resp models.Preview
+
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, preview, resp)
+			}
+		})
+	}
+}
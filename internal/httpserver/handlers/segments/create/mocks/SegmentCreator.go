@@ -15,23 +15,47 @@ type SegmentCreator struct {
 	mock.Mock
 }
 
-// CreateSegment provides a mock function with given fields: ctx, segment
-func (_m *SegmentCreator) CreateSegment(ctx context.Context, segment models.Segment) (models.Segment, error) {
-	ret := _m.Called(ctx, segment)
+// CreateSegment provides a mock function with given fields: ctx, project, segment
+func (_m *SegmentCreator) CreateSegment(ctx context.Context, project string, segment models.Segment) (models.Segment, error) {
+	ret := _m.Called(ctx, project, segment)
 
 	var r0 models.Segment
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, models.Segment) (models.Segment, error)); ok {
-		return rf(ctx, segment)
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Segment) (models.Segment, error)); ok {
+		return rf(ctx, project, segment)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, models.Segment) models.Segment); ok {
-		r0 = rf(ctx, segment)
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Segment) models.Segment); ok {
+		r0 = rf(ctx, project, segment)
 	} else {
 		r0 = ret.Get(0).(models.Segment)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, models.Segment) error); ok {
-		r1 = rf(ctx, segment)
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.Segment) error); ok {
+		r1 = rf(ctx, project, segment)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PreviewCreateSegment provides a mock function with given fields: ctx, project, segment
+func (_m *SegmentCreator) PreviewCreateSegment(ctx context.Context, project string, segment models.Segment) (models.Preview, error) {
+	ret := _m.Called(ctx, project, segment)
+
+	var r0 models.Preview
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Segment) (models.Preview, error)); ok {
+		return rf(ctx, project, segment)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Segment) models.Preview); ok {
+		r0 = rf(ctx, project, segment)
+	} else {
+		r0 = ret.Get(0).(models.Preview)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.Segment) error); ok {
+		r1 = rf(ctx, project, segment)
 	} else {
 		r1 = ret.Error(1)
 	}
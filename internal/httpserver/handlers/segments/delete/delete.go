@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 	"segmentify/internal/storage"
@@ -16,22 +17,26 @@ import (
 )
 
 type SegmentDeleter interface {
-	DeleteSegment(ctx context.Context, slug string) error
+	DeleteSegment(ctx context.Context, project, slug string) error
 }
 
 // @Summary	Deleting a segment
 // @Tags		segments
+// @Security	ApiKeyAuth
 // @Param		slug	path	string	true	"Segment slug"
 // @Success	204
 // @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
 // @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
 // @Failure	500	{object}	resp.ErrResponse
 // @Router		/segments/{slug} [delete]
-func New(ctx context.Context, log *slog.Logger, segmentDeleter SegmentDeleter) http.HandlerFunc {
+func New(log *slog.Logger, segmentDeleter SegmentDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.segments.delete.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
@@ -42,7 +47,7 @@ func New(ctx context.Context, log *slog.Logger, segmentDeleter SegmentDeleter) h
 			return
 		}
 
-		err := segmentDeleter.DeleteSegment(ctx, slug)
+		err := segmentDeleter.DeleteSegment(r.Context(), project.SlugFromContext(r.Context()), slug)
 		if err != nil {
 			var errSegmentNotFound *storage.ErrSegmentNotFound
 
@@ -50,7 +55,7 @@ func New(ctx context.Context, log *slog.Logger, segmentDeleter SegmentDeleter) h
 				render.Render(w, r, resp.ErrNotFound(errSegmentNotFound.Error()))
 				return
 			}
-			log.Error("failed to delete segment", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to delete segment", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to delete segment"))
 			return
 		}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 	"segmentify/internal/models"
@@ -17,22 +18,26 @@ import (
 )
 
 type SegmentGetter interface {
-	GetSegment(ctx context.Context, slug string) (models.Segment, error)
+	GetSegment(ctx context.Context, project, slug string) (models.Segment, error)
 }
 
 // @Summary	Getting a segment
 // @Tags		segments
+// @Security	ApiKeyAuth
 // @Param		slug	path		string	true "Segment slug"
 // @Success	200		{object} models.Segment
 // @Failure	400		{object}	resp.ErrResponse
+// @Failure	401		{object}	resp.ErrResponse
+// @Failure	403		{object}	resp.ErrResponse
 // @Failure	404		{object}	resp.ErrResponse
+// @Failure	429		{object}	resp.ErrResponse
 // @Failure	500		{object}	resp.ErrResponse
 // @Router		/segments/{slug} [get]
-func New(ctx context.Context, log *slog.Logger, segmentGetter SegmentGetter) http.HandlerFunc {
+func New(log *slog.Logger, segmentGetter SegmentGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.segments.get.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
@@ -43,7 +48,7 @@ func New(ctx context.Context, log *slog.Logger, segmentGetter SegmentGetter) htt
 			return
 		}
 
-		dbSegment, err := segmentGetter.GetSegment(ctx, slug)
+		dbSegment, err := segmentGetter.GetSegment(r.Context(), project.SlugFromContext(r.Context()), slug)
 		if err != nil {
 			var errSegmentNotFound *storage.ErrSegmentNotFound
 
@@ -51,7 +56,7 @@ func New(ctx context.Context, log *slog.Logger, segmentGetter SegmentGetter) htt
 				render.Render(w, r, resp.ErrNotFound(errSegmentNotFound.Error()))
 				return
 			}
-			log.Error("failed to get segment", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to get segment", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to get segment"))
 			return
 		}
@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultDays = 30
+	maxDays     = 366
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=SegmentStatsGetter
+type SegmentStatsGetter interface {
+	GetSegmentStats(ctx context.Context, project, slug string, from, to time.Time) (models.SegmentStats, error)
+}
+
+// @Summary		Getting segment statistics
+// @Description	Returns the current members of the segment and the memberships added and removed on every day of the range with the segment size at its end. The range is the last 30 days by default and 366 days at most.
+// @Tags			segments
+// @Security		ApiKeyAuth
+// @Param			slug	path		string	true	"Segment slug"
+// @Param			from	query		string	false	"First day, inclusive"	example(2023-09-01)
+// @Param			to		query		string	false	"Last day, inclusive, today by default"	example(2023-09-30)
+// @Success		200		{object}	models.SegmentStats
+// @Failure		400		{object}	resp.ErrResponse
+// @Failure		401		{object}	resp.ErrResponse
+// @Failure		403		{object}	resp.ErrResponse
+// @Failure		404		{object}	resp.ErrResponse
+// @Failure		429		{object}	resp.ErrResponse
+// @Failure		500		{object}	resp.ErrResponse
+// @Router			/segments/{slug}/stats [get]
+func New(log *slog.Logger, segmentStatsGetter SegmentStatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.segments.stats.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		slug := chi.URLParam(r, "slug")
+		if slug == "" {
+			render.Render(w, r, resp.ErrInvalidRequest("slug is invalid"))
+			return
+		}
+
+		query := r.URL.Query()
+
+		var err error
+
+		to := time.Now().UTC().Truncate(24 * time.Hour)
+		if v := query.Get("to"); v != "" {
+			if to, err = time.Parse(time.DateOnly, v); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'to'. Should be formatted like 'yyyy-mm-dd'"))
+				return
+			}
+		}
+		from := to.AddDate(0, 0, 1-defaultDays)
+		if v := query.Get("from"); v != "" {
+			if from, err = time.Parse(time.DateOnly, v); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'from'. Should be formatted like 'yyyy-mm-dd'"))
+				return
+			}
+		}
+		if days := int(to.Sub(from)/(24*time.Hour)) + 1; days < 1 || days > maxDays {
+			render.Render(w, r, resp.ErrInvalidRequest("Invalid range. 'from' should not be after 'to', and the range should be 366 days at most"))
+			return
+		}
+
+		stats, err := segmentStatsGetter.GetSegmentStats(r.Context(), project.SlugFromContext(r.Context()), slug, from, to)
+		if err != nil {
+			var errSegmentNotFound *storage.ErrSegmentNotFound
+
+			if errors.As(err, &errSegmentNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errSegmentNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get segment stats", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to get segment stats"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, stats)
+	}
+}
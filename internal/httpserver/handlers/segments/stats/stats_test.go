@@ -0,0 +1,111 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/httpserver/handlers/segments/stats"
+	"segmentify/internal/httpserver/handlers/segments/stats/mocks"
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+)
+
+func TestStatsHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		from      any
+		to        any
+		respCode  int
+		mockError error
+	}{
+		{
+			name:     "Default Range",
+			query:    "",
+			from:     mock.Anything,
+			to:       mock.Anything,
+			respCode: http.StatusOK,
+		},
+		{
+			name:     "Range",
+			query:    "?from=2023-09-01&to=2023-09-30",
+			from:     time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2023, 9, 30, 0, 0, 0, 0, time.UTC),
+			respCode: http.StatusOK,
+		},
+		{
+			name:     "Single Day",
+			query:    "?from=2023-09-12&to=2023-09-12",
+			from:     time.Date(2023, 9, 12, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2023, 9, 12, 0, 0, 0, 0, time.UTC),
+			respCode: http.StatusOK,
+		},
+		{
+			name:      "Segment Not Found",
+			query:     "?from=2023-09-01&to=2023-09-30",
+			from:      time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+			to:        time.Date(2023, 9, 30, 0, 0, 0, 0, time.UTC),
+			respCode:  http.StatusNotFound,
+			mockError: &storage.ErrSegmentNotFound{Slug: "A"},
+		},
+		{
+			name:     "Invalid From",
+			query:    "?from=yesterday",
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:     "From After To",
+			query:    "?from=2023-09-30&to=2023-09-01",
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Range Too Long",
+			query:    "?from=2022-09-01&to=2023-09-30",
+			respCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			segmentStatsGetterMock := mocks.NewSegmentStatsGetter(t)
+
+			if tc.respCode != http.StatusBadRequest {
+				segmentStatsGetterMock.On("GetSegmentStats", mock.Anything, models.DefaultProject, "A", tc.from, tc.to).
+					Return(models.SegmentStats{Slug: "A", Percent: 50, ActualPercent: 48.5}, tc.mockError).
+					Once()
+			}
+
+			router := chi.NewRouter()
+			router.Get("/segments/{slug}/stats", stats.New(slogdiscard.NewDiscardLogger(), segmentStatsGetterMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/segments/A/stats"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode != http.StatusOK {
+				return
+			}
+
+			var resp models.SegmentStats
+
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, 48.5, resp.ActualPercent)
+		})
+	}
+}
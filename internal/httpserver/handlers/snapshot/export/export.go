@@ -0,0 +1,80 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/snapshot"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type SnapshotExporter interface {
+	ExportSnapshot(ctx context.Context, w io.Writer) (snapshot.Manifest, error)
+}
+
+// @Summary		Exporting a snapshot
+// @Description	Streams all projects, segments, users, memberships and history as a zip archive with an NDJSON file per table and a manifest.
+// @Tags			admin
+// @Security		ApiKeyAuth
+// @Produce		application/zip
+// @Success		200
+// @Failure		401	{object}	resp.ErrResponse
+// @Failure		403	{object}	resp.ErrResponse
+// @Failure		429	{object}	resp.ErrResponse
+// @Failure		500	{object}	resp.ErrResponse
+// @Router			/admin/snapshot [get]
+func New(log *slog.Logger, snapshotExporter SnapshotExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.snapshot.export.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		aw := &archiveWriter{
+			w:        w,
+			filename: fmt.Sprintf("segmentify-snapshot-%s.zip", time.Now().UTC().Format("20060102T150405Z")),
+		}
+
+		manifest, err := snapshotExporter.ExportSnapshot(r.Context(), aw)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to export snapshot", sl.Err(err))
+			// Once the archive is being sent the client is left with a
+			// truncated archive, which fails to open
+			if !aw.started {
+				render.Render(w, r, resp.ErrInternal("failed to export snapshot"))
+			}
+			return
+		}
+
+		log.InfoContext(r.Context(), "snapshot exported", slog.Any("counts", manifest.Counts))
+	}
+}
+
+// archiveWriter sends the archive headers with the first write, so errors
+// before it can still be rendered.
+type archiveWriter struct {
+	w        http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (aw *archiveWriter) Write(p []byte) (int, error) {
+	if !aw.started {
+		aw.started = true
+		aw.w.Header().Set("Content-Type", "application/zip")
+		aw.w.Header().Set("Content-Disposition", "attachment; filename="+aw.filename)
+		aw.w.WriteHeader(http.StatusOK)
+	}
+
+	return aw.w.Write(p)
+}
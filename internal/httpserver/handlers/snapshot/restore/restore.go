@@ -0,0 +1,99 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/snapshot"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type SnapshotImporter interface {
+	ImportSnapshot(ctx context.Context, archive *snapshot.Archive, mode snapshot.Mode) (snapshot.Result, error)
+}
+
+// @Summary		Importing a snapshot
+// @Description	Imports an archive made by the export in one transaction. upsert adds the rows of the archive and overwrites stored rows with the same keys, replace first deletes the segments and users of the projects in the archive. Stored rows that differ from the archive are reported as conflicts.
+// @Tags			admin
+// @Security		ApiKeyAuth
+// @Accept			application/zip
+// @Produce		json
+// @Param			mode	query		string	false	"Import mode"	Enums(upsert, replace)	default(upsert)
+// @Success		200		{object}	snapshot.Result
+// @Failure		400		{object}	resp.ErrResponse
+// @Failure		401		{object}	resp.ErrResponse
+// @Failure		403		{object}	resp.ErrResponse
+// @Failure		429		{object}	resp.ErrResponse
+// @Failure		500		{object}	resp.ErrResponse
+// @Router			/admin/snapshot [post]
+func New(log *slog.Logger, snapshotImporter SnapshotImporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.snapshot.restore.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		mode := snapshot.ModeUpsert
+		if s := r.URL.Query().Get("mode"); s != "" {
+			var ok bool
+			if mode, ok = snapshot.ParseMode(s); !ok {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'mode'. Should be 'upsert' or 'replace'"))
+				return
+			}
+		}
+
+		// A zip archive is read from its end, so the body is kept in a file
+		f, err := os.CreateTemp("", "segmentify-snapshot-*.zip")
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to create temporary file", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to import snapshot"))
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		size, err := io.Copy(f, r.Body)
+		if err != nil {
+			render.Render(w, r, resp.ErrInvalidRequest("failed to read request body"))
+			return
+		}
+
+		// Invalid archives fail on open, archives of another schema on import
+		fail := func(err error) {
+			var errInvalid *snapshot.ErrInvalid
+
+			if errors.As(err, &errInvalid) {
+				render.Render(w, r, resp.ErrInvalidRequest(errInvalid.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to import snapshot", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to import snapshot"))
+		}
+
+		archive, err := snapshot.Open(f, size)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		res, err := snapshotImporter.ImportSnapshot(r.Context(), archive, mode)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "snapshot imported", slog.String("mode", string(mode)), slog.Int("conflicts", len(res.Conflicts)))
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, res)
+	}
+}
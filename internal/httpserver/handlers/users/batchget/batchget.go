@@ -0,0 +1,93 @@
+package batchget
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=10000,dive,gt=0" example:"1,2,3"`
+}
+
+type Response struct {
+	// Users maps user IDs to the slugs of their active segments.
+	Users map[int64][]string `json:"users"`
+	// NotFound lists the requested users that do not exist in the project.
+	NotFound []int64 `json:"not_found"`
+}
+
+type UsersSegmentsGetter interface {
+	GetUsersSegments(ctx context.Context, project string, ids []int64) (map[int64][]string, error)
+}
+
+// @Summary		Getting segments of many users
+// @Description	Returns the active segments of up to 10000 users at once. Unknown users are listed in not_found instead of failing the request.
+// @Tags			users
+// @Security		ApiKeyAuth
+// @Param			body	body		Request	true	"User IDs"
+// @Success		200		{object}	Response
+// @Failure		400		{object}	resp.ErrResponse
+// @Failure		401		{object}	resp.ErrResponse
+// @Failure		403		{object}	resp.ErrResponse
+// @Failure		422		{object}	resp.ErrResponse
+// @Failure		429		{object}	resp.ErrResponse
+// @Failure		500		{object}	resp.ErrResponse
+// @Router			/users/segments:batchGet [post]
+func New(log *slog.Logger, usersSegmentsGetter UsersSegmentsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.users.batchget.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				render.Render(w, r, resp.ErrInvalidRequest("request body is empty"))
+				return
+			}
+			render.Render(w, r, resp.ErrInvalidRequest("failed to decode request body"))
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			render.Render(w, r, resp.ValidationError(validateErr))
+			return
+		}
+
+		users, err := usersSegmentsGetter.GetUsersSegments(r.Context(), project.SlugFromContext(r.Context()), req.IDs)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get users segments", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to get users segments"))
+			return
+		}
+
+		res := Response{Users: users, NotFound: []int64{}}
+
+		seen := make(map[int64]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			if _, ok := users[id]; !ok && !seen[id] {
+				res.NotFound = append(res.NotFound, id)
+			}
+			seen[id] = true
+		}
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, res)
+	}
+}
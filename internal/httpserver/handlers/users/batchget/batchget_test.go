@@ -0,0 +1,95 @@
+package batchget_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/httpserver/handlers/users/batchget"
+	"segmentify/internal/httpserver/handlers/users/batchget/mocks"
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestBatchGetHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		ids      []int64
+		users    map[int64][]string
+		respCode int
+		notFound []int64
+	}{
+		{
+			name:     "Success",
+			body:     `{"ids": [1, 2]}`,
+			ids:      []int64{1, 2},
+			users:    map[int64][]string{1: {"A", "B"}, 2: {}},
+			respCode: http.StatusOK,
+			notFound: []int64{},
+		},
+		{
+			name:     "Unknown Users",
+			body:     `{"ids": [3, 1, 4, 3]}`,
+			ids:      []int64{3, 1, 4, 3},
+			users:    map[int64][]string{1: {"A"}},
+			respCode: http.StatusOK,
+			notFound: []int64{3, 4},
+		},
+		{
+			name:     "Empty IDs",
+			body:     `{"ids": []}`,
+			respCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Invalid ID",
+			body:     `{"ids": [0]}`,
+			respCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Empty Body",
+			body:     "",
+			respCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			usersSegmentsGetterMock := mocks.NewUsersSegmentsGetter(t)
+
+			if tc.respCode == http.StatusOK {
+				usersSegmentsGetterMock.On("GetUsersSegments", mock.Anything, mock.AnythingOfType("string"), tc.ids).
+					Return(tc.users, nil).
+					Once()
+			}
+
+			handler := batchget.New(slogdiscard.NewDiscardLogger(), usersSegmentsGetterMock)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/segments:batchGet", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode != http.StatusOK {
+				return
+			}
+
+			var resp batchget.Response
+
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.users, resp.Users)
+			require.Equal(t, tc.notFound, resp.NotFound)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 
@@ -17,26 +18,30 @@ type Response struct {
 }
 
 type UserCreator interface {
-	CreateUser(ctx context.Context) (int64, error)
+	CreateUser(ctx context.Context, project string) (int64, error)
 }
 
 // @Summary	Creating a user
 // @Tags		users
+// @Security	ApiKeyAuth
 // @Success	201	{object}	Response
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
 // @Failure	500	{object}	resp.ErrResponse
 // @Router		/users [post]
-func New(ctx context.Context, log *slog.Logger, userCreator UserCreator) http.HandlerFunc {
+func New(log *slog.Logger, userCreator UserCreator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.users.create.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		dbID, err := userCreator.CreateUser(ctx)
+		dbID, err := userCreator.CreateUser(r.Context(), project.SlugFromContext(r.Context()))
 		if err != nil {
-			log.Error("failed to create user", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to create user", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to create user"))
 			return
 		}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 	"segmentify/internal/storage"
@@ -22,22 +23,26 @@ type Response struct {
 }
 
 type UserSegmentsGetter interface {
-	GetUserSegments(ctx context.Context, id int64) ([]string, error)
+	GetUserSegments(ctx context.Context, project string, id int64) ([]string, error)
 }
 
 // @Summary	Getting user segments
 // @Tags		users
+// @Security	ApiKeyAuth
 // @Param		id	path		string	true	"User ID"
 // @Success	200	{object}	Response
 // @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
 // @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
 // @Failure	500	{object}	resp.ErrResponse
 // @Router		/users/{id}/segments [get]
-func New(ctx context.Context, log *slog.Logger, userSegmentsGetter UserSegmentsGetter) http.HandlerFunc {
+func New(log *slog.Logger, userSegmentsGetter UserSegmentsGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.users.get.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
@@ -48,7 +53,7 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsGetter UserSegmentsG
 			return
 		}
 
-		segments, err := userSegmentsGetter.GetUserSegments(ctx, id)
+		segments, err := userSegmentsGetter.GetUserSegments(r.Context(), project.SlugFromContext(r.Context()), id)
 		if err != nil {
 			var errUserNotFound *storage.ErrUserNotFound
 			var errUserSegmentNotFound *storage.ErrUserSegmentNotFound
@@ -60,7 +65,7 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsGetter UserSegmentsG
 				render.Render(w, r, resp.ErrNotFound(errUserSegmentNotFound.Error()))
 				return
 			}
-			log.Error("failed to get user segment", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to get user segment", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to get user segment"))
 			return
 		}
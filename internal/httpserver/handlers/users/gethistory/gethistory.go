@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"time"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 	"segmentify/internal/storage"
@@ -20,20 +21,24 @@ import (
 )
 
 type UserSegmentsHistoryGetter interface {
-	GetUserSegmentsHistory(ctx context.Context, id int64, period time.Time) ([][]string, error)
+	GetUserSegmentsHistory(ctx context.Context, project string, id int64, period time.Time) ([][]string, error)
 }
 
 // @Summary	Downloading user segments history
 // @Tags		users
+// @Security	ApiKeyAuth
 // @Produce	text/csv,json
 // @Param		id		path	string	true	"User ID"
 // @Param		period	query	string	true	"Year and month"	example(2023-09)
 // @Success	200
 // @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
 // @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
 // @Failure	500	{object}	resp.ErrResponse
 // @Router		/users/{id}/download-segments-history [get]
-func New(ctx context.Context, log *slog.Logger, userSegmentsHistoryGetter UserSegmentsHistoryGetter) http.HandlerFunc {
+func New(log *slog.Logger, userSegmentsHistoryGetter UserSegmentsHistoryGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "httpserver.handlers.users.gethistory.New"
 
@@ -54,7 +59,7 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsHistoryGetter UserSe
 			return
 		}
 
-		report, err := userSegmentsHistoryGetter.GetUserSegmentsHistory(ctx, id, period)
+		report, err := userSegmentsHistoryGetter.GetUserSegmentsHistory(r.Context(), project.SlugFromContext(r.Context()), id, period)
 		if err != nil {
 			var errUserNotFound *storage.ErrUserNotFound
 
@@ -62,7 +67,7 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsHistoryGetter UserSe
 				render.Render(w, r, resp.ErrNotFound(errUserNotFound.Error()))
 				return
 			}
-			log.Error("failed to get user segments history", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to get user segments history", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to get user segments history"))
 			return
 		}
@@ -71,7 +76,7 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsHistoryGetter UserSe
 		wtr := csv.NewWriter(buf)
 		wtr.WriteAll(report)
 		if err := wtr.Error(); err != nil {
-			log.Error("failed to write csv", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to write csv", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to write csv"))
 			return
 		}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"segmentify/internal/httpserver/middleware/project"
 	"segmentify/internal/lib/logger/sl"
 	resp "segmentify/internal/lib/response"
 	"segmentify/internal/models"
@@ -27,27 +28,42 @@ type Request struct {
 type UserSegmentsUpdater interface {
 	UpdateUserSegments(
 		ctx context.Context,
+		project string,
 		id int64,
 		segmentsToAdd []models.SegmentToAdd,
 		segmentsToRemove []models.SegmentToRemove,
 	) error
+	PreviewUserSegmentsUpdate(
+		ctx context.Context,
+		project string,
+		id int64,
+		segmentsToAdd []models.SegmentToAdd,
+		segmentsToRemove []models.SegmentToRemove,
+	) (models.Preview, error)
 }
 
-// @Summary	Updating user segments
-// @Tags		users
-// @Param		id		path	string	true	"User ID"
-// @Param		body	body	Request	true	"Segments to add/remove"
-// @Success	204
-// @Failure	400	{object}	resp.ErrResponse
-// @Failure	404	{object}	resp.ErrResponse
-// @Failure	422	{object}	resp.ErrResponse
-// @Failure	500	{object}	resp.ErrResponse
-// @Router		/users/{id}/segments [patch]
-func New(ctx context.Context, log *slog.Logger, userSegmentsUpdater UserSegmentsUpdater) http.HandlerFunc {
+// @Summary		Updating user segments
+// @Description	With dry_run=true the update is applied in a transaction that is rolled back, and the changes it would make are returned. Missing segments and memberships are reported as conflicts instead of failing the request.
+// @Tags			users
+// @Security		ApiKeyAuth
+// @Param			id		path		string	true	"User ID"
+// @Param			body	body		Request	true	"Segments to add/remove"
+// @Param			dry_run	query		bool	false	"Preview the update without applying it"
+// @Success		200		{object}	models.Preview
+// @Success		204
+// @Failure		400		{object}	resp.ErrResponse
+// @Failure		401		{object}	resp.ErrResponse
+// @Failure		403		{object}	resp.ErrResponse
+// @Failure		404		{object}	resp.ErrResponse
+// @Failure		422		{object}	resp.ErrResponse
+// @Failure		429		{object}	resp.ErrResponse
+// @Failure		500		{object}	resp.ErrResponse
+// @Router			/users/{id}/segments [patch]
+func New(log *slog.Logger, userSegmentsUpdater UserSegmentsUpdater) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.users.update.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
@@ -58,6 +74,14 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsUpdater UserSegments
 			return
 		}
 
+		dryRun := false
+		if s := r.URL.Query().Get("dry_run"); s != "" {
+			if dryRun, err = strconv.ParseBool(s); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'dry_run'. Should be 'true' or 'false'"))
+				return
+			}
+		}
+
 		var req Request
 
 		if err = render.DecodeJSON(r.Body, &req); err != nil {
@@ -83,8 +107,33 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsUpdater UserSegments
 			return
 		}
 
+		if dryRun {
+			preview, err := userSegmentsUpdater.PreviewUserSegmentsUpdate(
+				r.Context(),
+				project.SlugFromContext(r.Context()),
+				id,
+				req.SegmentsToAdd,
+				req.SegmentsToRemove,
+			)
+			if err != nil {
+				var errUserNotFound *storage.ErrUserNotFound
+
+				if errors.As(err, &errUserNotFound) {
+					render.Render(w, r, resp.ErrNotFound(errUserNotFound.Error()))
+					return
+				}
+				log.ErrorContext(r.Context(), "failed to preview user segments update", sl.Err(err))
+				render.Render(w, r, resp.ErrInternal("failed to preview user segments update"))
+				return
+			}
+			render.Status(r, http.StatusOK)
+			render.JSON(w, r, preview)
+			return
+		}
+
 		if err = userSegmentsUpdater.UpdateUserSegments(
-			ctx,
+			r.Context(),
+			project.SlugFromContext(r.Context()),
 			id,
 			req.SegmentsToAdd,
 			req.SegmentsToRemove,
@@ -110,7 +159,7 @@ func New(ctx context.Context, log *slog.Logger, userSegmentsUpdater UserSegments
 				render.Render(w, r, resp.ErrNotFound(errUserSegmentNotFound.Error()))
 				return
 			}
-			log.Error("failed to update user segments", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to update user segments", sl.Err(err))
 			render.Render(w, r, resp.ErrInternal("failed to update user segments"))
 			return
 		}
@@ -0,0 +1,97 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/webhooks"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	URL string `json:"url" validate:"required,http_url" example:"https://example.com/hooks/segmentify"`
+	// Secret signs deliveries. A random one is generated if it is empty.
+	Secret string `json:"secret,omitempty"`
+	// Segments filters events by segment slug. Empty means all segments.
+	Segments []string `json:"segments"`
+	// Events filters events by type. Empty means all types.
+	Events []string `json:"events" validate:"dive,oneof=segment.user_added segment.user_removed" example:"segment.user_added"`
+}
+
+type WebhookCreator interface {
+	CreateWebhook(ctx context.Context, project string, webhook models.Webhook) (models.Webhook, error)
+}
+
+// @Summary	Creating a webhook subscription
+// @Tags		webhooks
+// @Security	ApiKeyAuth
+// @Param		body	body		Request	true	"Webhook"
+// @Success	201		{object}	models.Webhook
+// @Failure	400		{object}	resp.ErrResponse
+// @Failure	401		{object}	resp.ErrResponse
+// @Failure	403		{object}	resp.ErrResponse
+// @Failure	422		{object}	resp.ErrResponse
+// @Failure	429		{object}	resp.ErrResponse
+// @Failure	500		{object}	resp.ErrResponse
+// @Router		/webhooks [post]
+func New(log *slog.Logger, webhookCreator WebhookCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				render.Render(w, r, resp.ErrInvalidRequest("request body is empty"))
+				return
+			}
+			render.Render(w, r, resp.ErrInvalidRequest("failed to decode request body"))
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			render.Render(w, r, resp.ValidationError(validateErr))
+			return
+		}
+
+		secret := req.Secret
+		if secret == "" {
+			var err error
+			if secret, err = webhooks.GenerateSecret(); err != nil {
+				log.ErrorContext(r.Context(), "failed to generate webhook secret", sl.Err(err))
+				render.Render(w, r, resp.ErrInternal("failed to create webhook"))
+				return
+			}
+		}
+
+		dbWebhook, err := webhookCreator.CreateWebhook(r.Context(), project.SlugFromContext(r.Context()), models.Webhook{
+			URL:      req.URL,
+			Secret:   secret,
+			Segments: req.Segments,
+			Events:   req.Events,
+		})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to create webhook", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to create webhook"))
+			return
+		}
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, dbWebhook)
+	}
+}
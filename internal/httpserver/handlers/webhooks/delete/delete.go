@@ -0,0 +1,64 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type WebhookDeleter interface {
+	DeleteWebhook(ctx context.Context, project string, id int64) error
+}
+
+// @Summary	Deleting a webhook subscription with its deliveries
+// @Tags		webhooks
+// @Security	ApiKeyAuth
+// @Param		id	path	string	true	"Webhook ID"
+// @Success	204
+// @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/webhooks/{id} [delete]
+func New(log *slog.Logger, webhookDeleter WebhookDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.delete.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			render.Render(w, r, resp.ErrInvalidRequest("webhook id is invalid"))
+			return
+		}
+
+		if err := webhookDeleter.DeleteWebhook(r.Context(), project.SlugFromContext(r.Context()), id); err != nil {
+			var errWebhookNotFound *storage.ErrWebhookNotFound
+
+			if errors.As(err, &errWebhookNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errWebhookNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to delete webhook", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to delete webhook"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,67 @@
+package get
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type WebhookDeliveryGetter interface {
+	GetWebhookDelivery(ctx context.Context, project string, id int64) (models.WebhookDelivery, error)
+}
+
+// @Summary	Getting a webhook delivery with the log of its attempts
+// @Tags		webhooks
+// @Security	ApiKeyAuth
+// @Param		id	path		string	true	"Delivery ID"
+// @Success	200	{object}	models.WebhookDelivery
+// @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/webhooks/deliveries/{id} [get]
+func New(log *slog.Logger, webhookDeliveryGetter WebhookDeliveryGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.deliveries.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			render.Render(w, r, resp.ErrInvalidRequest("delivery id is invalid"))
+			return
+		}
+
+		delivery, err := webhookDeliveryGetter.GetWebhookDelivery(r.Context(), project.SlugFromContext(r.Context()), id)
+		if err != nil {
+			var errWebhookDeliveryNotFound *storage.ErrWebhookDeliveryNotFound
+
+			if errors.As(err, &errWebhookDeliveryNotFound) {
+				render.Render(w, r, resp.ErrNotFound(errWebhookDeliveryNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get webhook delivery", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to get webhook delivery"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, delivery)
+	}
+}
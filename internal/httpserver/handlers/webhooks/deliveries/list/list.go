@@ -0,0 +1,88 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type WebhookDeliveriesLister interface {
+	ListWebhookDeliveries(ctx context.Context, project string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+}
+
+// @Summary		Listing webhook deliveries, newest first
+// @Description	Filtering by status=dead gives the dead-letter view: deliveries that ran out of attempts.
+// @Tags			webhooks
+// @Security		ApiKeyAuth
+// @Param			webhook_id	query		int		false	"Webhook ID"
+// @Param			status		query		string	false	"Status"	Enums(pending, delivered, dead)
+// @Param			limit		query		int		false	"Page size, 50 by default, 500 at most"
+// @Success		200			{array}		models.WebhookDelivery
+// @Failure		400			{object}	resp.ErrResponse
+// @Failure		401			{object}	resp.ErrResponse
+// @Failure		403			{object}	resp.ErrResponse
+// @Failure		429			{object}	resp.ErrResponse
+// @Failure		500			{object}	resp.ErrResponse
+// @Router			/webhooks/deliveries [get]
+func New(log *slog.Logger, webhookDeliveriesLister WebhookDeliveriesLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.deliveries.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		query := r.URL.Query()
+
+		filter := models.WebhookDeliveryFilter{
+			Status: query.Get("status"),
+			Limit:  defaultLimit,
+		}
+
+		switch filter.Status {
+		case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+		default:
+			render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'status'. Should be one of pending, delivered, dead"))
+			return
+		}
+
+		var err error
+
+		if v := query.Get("webhook_id"); v != "" {
+			if filter.WebhookID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'webhook_id'"))
+				return
+			}
+		}
+		if v := query.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.ParseInt(v, 10, 64); err != nil || filter.Limit <= 0 || filter.Limit > maxLimit {
+				render.Render(w, r, resp.ErrInvalidRequest("Invalid query param 'limit'. Should be between 1 and 500"))
+				return
+			}
+		}
+
+		deliveries, err := webhookDeliveriesLister.ListWebhookDeliveries(r.Context(), project.SlugFromContext(r.Context()), filter)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list webhook deliveries", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to list webhook deliveries"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, deliveries)
+	}
+}
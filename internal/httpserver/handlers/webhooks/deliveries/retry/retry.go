@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type WebhookDeliveryRetrier interface {
+	RetryWebhookDelivery(ctx context.Context, project string, id int64) error
+}
+
+// @Summary	Retrying a dead webhook delivery
+// @Tags		webhooks
+// @Security	ApiKeyAuth
+// @Param		id	path	string	true	"Delivery ID"
+// @Success	202
+// @Failure	400	{object}	resp.ErrResponse
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	404	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/webhooks/deliveries/{id}/retry [post]
+func New(log *slog.Logger, webhookDeliveryRetrier WebhookDeliveryRetrier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.deliveries.retry.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			render.Render(w, r, resp.ErrInvalidRequest("delivery id is invalid"))
+			return
+		}
+
+		if err := webhookDeliveryRetrier.RetryWebhookDelivery(r.Context(), project.SlugFromContext(r.Context()), id); err != nil {
+			var errWebhookDeliveryNotFound *storage.ErrWebhookDeliveryNotFound
+
+			if errors.As(err, &errWebhookDeliveryNotFound) {
+				render.Render(w, r, resp.ErrNotFound("dead "+errWebhookDeliveryNotFound.Error()))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to retry webhook delivery", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to retry webhook delivery"))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
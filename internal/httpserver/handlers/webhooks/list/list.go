@@ -0,0 +1,48 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/httpserver/middleware/project"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type WebhooksLister interface {
+	ListWebhooks(ctx context.Context, project string) ([]models.Webhook, error)
+}
+
+// @Summary	Listing webhook subscriptions
+// @Tags		webhooks
+// @Security	ApiKeyAuth
+// @Success	200	{array}		models.Webhook
+// @Failure	401	{object}	resp.ErrResponse
+// @Failure	403	{object}	resp.ErrResponse
+// @Failure	429	{object}	resp.ErrResponse
+// @Failure	500	{object}	resp.ErrResponse
+// @Router		/webhooks [get]
+func New(log *slog.Logger, webhooksLister WebhooksLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		webhooks, err := webhooksLister.ListWebhooks(r.Context(), project.SlugFromContext(r.Context()))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list webhooks", sl.Err(err))
+			render.Render(w, r, resp.ErrInternal("failed to list webhooks"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, webhooks)
+	}
+}
@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"net/http"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/auth"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New stores the audit actor of the request in its context: the API key that
+// authenticated it and the request ID. It must be mounted after the auth
+// middleware.
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			actor := audit.Actor{RequestID: middleware.GetReqID(r.Context())}
+
+			if key, ok := auth.APIKeyFromContext(r.Context()); ok {
+				actor.Name = key.Name
+				actor.APIKeyID = key.ID
+			}
+
+			next.ServeHTTP(w, r.WithContext(audit.WithActor(r.Context(), actor)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const Header = "X-API-Key"
+
+type APIKeyGetter interface {
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error)
+}
+
+// Authenticate rejects requests without a valid, non-revoked API key in the
+// X-API-Key header with 401 and stores the key in the request context.
+func Authenticate(log *slog.Logger, apiKeyGetter APIKeyGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			plain := r.Header.Get(Header)
+			if plain == "" {
+				render.Render(w, r, resp.ErrUnauthorized(fmt.Sprintf("%s header is required", Header)))
+				return
+			}
+
+			key, err := apiKeyGetter.GetAPIKeyByHash(r.Context(), auth.Hash(plain))
+			if err != nil {
+				var errAPIKeyNotFound *storage.ErrAPIKeyNotFound
+
+				if errors.As(err, &errAPIKeyNotFound) {
+					render.Render(w, r, resp.ErrUnauthorized("api key is invalid or revoked"))
+					return
+				}
+				log.ErrorContext(r.Context(), "failed to get api key", sl.Err(err),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				render.Render(w, r, resp.ErrInternal("failed to authenticate"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireRole rejects requests whose API key role does not include role
+// with 403. It must be mounted after Authenticate.
+func RequireRole(role string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key, ok := auth.APIKeyFromContext(r.Context())
+			if !ok {
+				render.Render(w, r, resp.ErrUnauthorized(fmt.Sprintf("%s header is required", Header)))
+				return
+			}
+
+			if !auth.Allows(key.Role, role) {
+				render.Render(w, r, resp.ErrForbidden(fmt.Sprintf("role %s is required", role)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireGlobal rejects requests authenticated with a key bound to a project
+// with 403. It guards routes that span all projects. It must be mounted after
+// Authenticate.
+func RequireGlobal() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key, ok := auth.APIKeyFromContext(r.Context())
+			if !ok {
+				render.Render(w, r, resp.ErrUnauthorized(fmt.Sprintf("%s header is required", Header)))
+				return
+			}
+
+			if key.Project != "" {
+				render.Render(w, r, resp.ErrForbidden("api key is bound to a project"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Anonymous grants every request the admin role. It replaces Authenticate
+// when authentication is disabled in configuration.
+func Anonymous() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := models.APIKey{Name: "anonymous", Role: auth.RoleAdmin}
+			next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ClientID identifies the client of the request by its API key, or by remote
+// address when the request is not authenticated with a stored key.
+func ClientID(r *http.Request) string {
+	return auth.ClientID(r.Context(), r.RemoteAddr)
+}
+
+// RemoteAddr identifies the client of the request by its remote address.
+func RemoteAddr(r *http.Request) string {
+	return auth.AddressID(r.RemoteAddr)
+}
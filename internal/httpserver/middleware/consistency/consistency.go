@@ -0,0 +1,72 @@
+package consistency
+
+import (
+	"context"
+	"net/http"
+
+	"segmentify/internal/consistency"
+	mwAuth "segmentify/internal/httpserver/middleware/auth"
+	"segmentify/internal/storage"
+)
+
+// New gives read-your-writes on top of read replicas: for the window of
+// writers after a client changed data, its reads go to the primary database.
+// Requests other than GET, HEAD and OPTIONS count as changes, unless their
+// route is marked with ReadOnly. Clients are identified as by the rate
+// limiter, so it must be mounted after the auth middleware.
+func New(writers *consistency.Writers) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			client := mwAuth.ClientID(r)
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				if writers.WroteRecently(client) {
+					r = r.WithContext(storage.WithPrimary(r.Context()))
+				}
+				next.ServeHTTP(w, r)
+			default:
+				// The route is only known after routing, so ReadOnly
+				// reports back through the context
+				req := &request{writers: writers, client: client}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, req)))
+
+				// The window starts when the change is done, not when it
+				// is requested
+				if !req.readOnly {
+					writers.Wrote(client)
+				}
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ReadOnly marks a route that reads with a method other than GET, such as a
+// batch lookup with the IDs in the body: its requests are routed like GET
+// requests and don't start the window. It does nothing without New.
+func ReadOnly() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if req, ok := r.Context().Value(requestKey{}).(*request); ok {
+				req.readOnly = true
+				if req.writers.WroteRecently(req.client) {
+					r = r.WithContext(storage.WithPrimary(r.Context()))
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type requestKey struct{}
+
+// request is the state of a non-GET request shared with ReadOnly.
+type request struct {
+	writers  *consistency.Writers
+	client   string
+	readOnly bool
+}
@@ -0,0 +1,64 @@
+package consistency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/consistency"
+	mwConsistency "segmentify/internal/httpserver/middleware/consistency"
+	"segmentify/internal/storage"
+)
+
+func TestConsistencyMiddleware(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(mwConsistency.New(consistency.New(50 * time.Millisecond)))
+	router.Get("/users/{id}/segments", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Primary", strconv.FormatBool(storage.PrimaryRequired(r.Context())))
+	})
+	router.Patch("/users/{id}/segments", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.With(mwConsistency.ReadOnly()).Post("/users/segments:batchGet", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Primary", strconv.FormatBool(storage.PrimaryRequired(r.Context())))
+	})
+
+	serve := func(method, addr string) string {
+		path := "/users/1/segments"
+		if method == http.MethodPost {
+			path = "/users/segments:batchGet"
+		}
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Header().Get("X-Primary")
+	}
+
+	const (
+		writer = "192.0.2.1:1234"
+		other  = "192.0.2.2:1234"
+	)
+
+	require.Equal(t, "false", serve(http.MethodGet, writer))
+
+	// Read-only routes don't count as changes
+	require.Equal(t, "false", serve(http.MethodPost, writer))
+	require.Equal(t, "false", serve(http.MethodGet, writer))
+
+	serve(http.MethodPatch, writer)
+
+	// Only the client that wrote reads from the primary, until the window passes
+	require.Equal(t, "true", serve(http.MethodGet, writer))
+	require.Equal(t, "true", serve(http.MethodPost, writer))
+	require.Equal(t, "false", serve(http.MethodGet, other))
+	require.Equal(t, "false", serve(http.MethodPost, other))
+
+	time.Sleep(60 * time.Millisecond)
+	require.Equal(t, "false", serve(http.MethodGet, writer))
+}
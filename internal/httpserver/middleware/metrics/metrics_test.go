@@ -0,0 +1,37 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	mwMetrics "segmentify/internal/httpserver/middleware/metrics"
+	"segmentify/internal/metrics"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	stats := metrics.New()
+
+	router := chi.NewRouter()
+	router.Use(mwMetrics.New(stats))
+	router.Get("/users/{id}/segments", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.Method(http.MethodGet, "/metrics", stats.Handler())
+
+	for _, path := range []string{"/users/1/segments", "/users/2/segments", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	require.Contains(t, body, `segmentify_http_requests_total{method="GET",route="/users/{id}/segments",status="404"} 2`)
+	require.Contains(t, body, `segmentify_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	require.Contains(t, body, `segmentify_http_request_duration_seconds_bucket{method="GET",route="/users/{id}/segments",status="404"`)
+}
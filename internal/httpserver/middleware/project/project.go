@@ -0,0 +1,83 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/lib/logger/sl"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// URLParam is the route parameter that holds the project slug.
+const URLParam = "project"
+
+type ProjectGetter interface {
+	GetProject(ctx context.Context, slug string) (models.Project, error)
+}
+
+// New resolves the project of the request from the {project} route parameter,
+// falling back to the default project for routes without one. Unknown
+// projects are rejected with 404, projects the API key is not bound to with
+// 403. It must be mounted after the auth middleware.
+func New(log *slog.Logger, projectGetter ProjectGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/project"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			slug := chi.URLParam(r, URLParam)
+			if slug == "" {
+				slug = models.DefaultProject
+			}
+
+			if key, ok := auth.APIKeyFromContext(r.Context()); ok && !auth.AllowsProject(key, slug) {
+				render.Render(w, r, resp.ErrForbidden(fmt.Sprintf("api key is not allowed to access project %s", slug)))
+				return
+			}
+
+			if _, err := projectGetter.GetProject(r.Context(), slug); err != nil {
+				var errProjectNotFound *storage.ErrProjectNotFound
+
+				if errors.As(err, &errProjectNotFound) {
+					render.Render(w, r, resp.ErrNotFound(errProjectNotFound.Error()))
+					return
+				}
+				log.ErrorContext(r.Context(), "failed to get project", sl.Err(err),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				render.Render(w, r, resp.ErrInternal("failed to get project"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithSlug(r.Context(), slug)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type ctxKey struct{}
+
+func WithSlug(ctx context.Context, slug string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, slug)
+}
+
+// SlugFromContext returns the project resolved by New, or the default
+// project when the request did not pass through it.
+func SlugFromContext(ctx context.Context) string {
+	if slug, ok := ctx.Value(ctxKey{}).(string); ok {
+		return slug
+	}
+	return models.DefaultProject
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mwAuth "segmentify/internal/httpserver/middleware/auth"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// projectPrefix is stripped from route patterns, so routes of every project
+// share the limits configured for the unscoped routes.
+const projectPrefix = "/projects/{project}"
+
+// New limits requests per client with limiter. Clients are identified by
+// their API key, or by remote address when the request is not authenticated
+// with a stored key. Routes are named "METHOD pattern" after the chi route
+// pattern, e.g. "PATCH /users/{id}/segments". Every response carries
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, rejected
+// requests get 429 with Retry-After. It must be mounted after the auth
+// middleware.
+func New(limiter *ratelimit.Limiter) func(next http.Handler) http.Handler {
+	return limit(limiter, func(r *http.Request) (string, string) {
+		return mwAuth.ClientID(r), route(r)
+	})
+}
+
+// NewByAddress limits requests per remote address with the default limit of
+// limiter, whatever the route. It is mounted before the auth middleware, so
+// requests with missing or invalid keys are limited before the key is looked
+// up.
+func NewByAddress(limiter *ratelimit.Limiter) func(next http.Handler) http.Handler {
+	return limit(limiter, func(r *http.Request) (string, string) {
+		return mwAuth.RemoteAddr(r), ratelimit.DefaultRule
+	})
+}
+
+// limit charges the bucket of the client and rule that key returns.
+func limit(limiter *ratelimit.Limiter, key func(r *http.Request) (client, rule string)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Allow(key(r))
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+			h.Set("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+			h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				render.Render(w, r, resp.ErrTooManyRequests("rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// route resolves the pattern of the route the request will be served by.
+// Middleware runs before routing, so the pattern is looked up on a copy of
+// the routing context.
+func route(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return r.Method + " " + r.URL.Path
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, r.URL.Path) {
+		return ratelimit.DefaultRule
+	}
+
+	pattern := tctx.RoutePattern()
+	if scoped := strings.TrimPrefix(pattern, projectPrefix); len(scoped) > 1 {
+		pattern = scoped
+	}
+	return r.Method + " " + strings.TrimSuffix(pattern, "/")
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
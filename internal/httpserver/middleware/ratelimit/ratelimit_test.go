@@ -0,0 +1,93 @@
+package ratelimit_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	mwRateLimit "segmentify/internal/httpserver/middleware/ratelimit"
+	resp "segmentify/internal/lib/response"
+	"segmentify/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(
+		ratelimit.Limit{Requests: 100, Period: time.Second},
+		map[string]ratelimit.Limit{"PATCH /users/{id}/segments": {Requests: 2, Period: time.Minute}},
+	)
+
+	router := chi.NewRouter()
+	router.Use(mwRateLimit.New(limiter))
+	router.Route("/users", func(r chi.Router) {
+		r.Patch("/{id}/segments", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	router.Route("/projects/{project}/users", func(r chi.Router) {
+		r.Patch("/{id}/segments", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	patch := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := patch("/users/1/segments")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", rr.Header().Get("RateLimit-Reset"))
+
+	// Project routes share the limit of the unscoped route
+	rr = patch("/projects/mobile/users/2/segments")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+	rr = patch("/users/3/segments")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "30", rr.Header().Get("Retry-After"))
+
+	var body resp.ErrResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	require.Equal(t, "rate limit exceeded", body.ErrorText)
+}
+
+func TestRateLimitByAddress(t *testing.T) {
+	limiter := ratelimit.New(
+		ratelimit.Limit{Requests: 2, Period: time.Minute},
+		map[string]ratelimit.Limit{"GET /segments/{slug}": {Requests: 100, Period: time.Second}},
+	)
+
+	// Authentication fails after the limiter, as for a bad key
+	router := chi.NewRouter()
+	router.Use(mwRateLimit.NewByAddress(limiter))
+	router.Get("/segments/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	get := func(addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/segments/A", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Route limits do not apply, all routes share the default limit
+	require.Equal(t, http.StatusUnauthorized, get("192.0.2.1:1234"))
+	require.Equal(t, http.StatusUnauthorized, get("192.0.2.1:5678"))
+	require.Equal(t, http.StatusTooManyRequests, get("192.0.2.1:1234"))
+
+	// Other addresses have buckets of their own
+	require.Equal(t, http.StatusUnauthorized, get("192.0.2.2:1234"))
+}
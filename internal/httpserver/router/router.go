@@ -0,0 +1,241 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/config"
+	"segmentify/internal/consistency"
+	createAPIKey "segmentify/internal/httpserver/handlers/apikeys/create"
+	listAPIKeys "segmentify/internal/httpserver/handlers/apikeys/list"
+	revokeAPIKey "segmentify/internal/httpserver/handlers/apikeys/revoke"
+	listAudit "segmentify/internal/httpserver/handlers/audit/list"
+	eventChanges "segmentify/internal/httpserver/handlers/events/changes"
+	streamEvents "segmentify/internal/httpserver/handlers/events/stream"
+	liveness "segmentify/internal/httpserver/handlers/health/live"
+	readiness "segmentify/internal/httpserver/handlers/health/ready"
+	listJobs "segmentify/internal/httpserver/handlers/jobs/list"
+	runJob "segmentify/internal/httpserver/handlers/jobs/run"
+	createProject "segmentify/internal/httpserver/handlers/projects/create"
+	deleteProject "segmentify/internal/httpserver/handlers/projects/delete"
+	listProjects "segmentify/internal/httpserver/handlers/projects/list"
+	getReload "segmentify/internal/httpserver/handlers/reload/get"
+	runReload "segmentify/internal/httpserver/handlers/reload/run"
+	createSegment "segmentify/internal/httpserver/handlers/segments/create"
+	deleteSegment "segmentify/internal/httpserver/handlers/segments/delete"
+	getSegment "segmentify/internal/httpserver/handlers/segments/get"
+	getSegmentStats "segmentify/internal/httpserver/handlers/segments/stats"
+	exportSnapshot "segmentify/internal/httpserver/handlers/snapshot/export"
+	restoreSnapshot "segmentify/internal/httpserver/handlers/snapshot/restore"
+	batchGetUsersSegments "segmentify/internal/httpserver/handlers/users/batchget"
+	createUser "segmentify/internal/httpserver/handlers/users/create"
+	getUserSegments "segmentify/internal/httpserver/handlers/users/get"
+	downloadUserSegmentsHistory "segmentify/internal/httpserver/handlers/users/gethistory"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	createWebhook "segmentify/internal/httpserver/handlers/webhooks/create"
+	deleteWebhook "segmentify/internal/httpserver/handlers/webhooks/delete"
+	getWebhookDelivery "segmentify/internal/httpserver/handlers/webhooks/deliveries/get"
+	listWebhookDeliveries "segmentify/internal/httpserver/handlers/webhooks/deliveries/list"
+	retryWebhookDelivery "segmentify/internal/httpserver/handlers/webhooks/deliveries/retry"
+	listWebhooks "segmentify/internal/httpserver/handlers/webhooks/list"
+	mwAudit "segmentify/internal/httpserver/middleware/audit"
+	mwAuth "segmentify/internal/httpserver/middleware/auth"
+	mwConsistency "segmentify/internal/httpserver/middleware/consistency"
+	mwLogger "segmentify/internal/httpserver/middleware/logger"
+	mwMetrics "segmentify/internal/httpserver/middleware/metrics"
+	mwProject "segmentify/internal/httpserver/middleware/project"
+	mwRateLimit "segmentify/internal/httpserver/middleware/ratelimit"
+	mwTracing "segmentify/internal/httpserver/middleware/tracing"
+	"segmentify/internal/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	httpSwagger "github.com/swaggo/http-swagger/v2"
+)
+
+// Storage is everything the HTTP handlers need from storage.
+type Storage interface {
+	mwAuth.APIKeyGetter
+	mwProject.ProjectGetter
+	createSegment.SegmentCreator
+	getSegment.SegmentGetter
+	getSegmentStats.SegmentStatsGetter
+	deleteSegment.SegmentDeleter
+	createUser.UserCreator
+	getUserSegments.UserSegmentsGetter
+	batchGetUsersSegments.UsersSegmentsGetter
+	updateUserSegments.UserSegmentsUpdater
+	downloadUserSegmentsHistory.UserSegmentsHistoryGetter
+	createWebhook.WebhookCreator
+	listWebhooks.WebhooksLister
+	deleteWebhook.WebhookDeleter
+	listWebhookDeliveries.WebhookDeliveriesLister
+	getWebhookDelivery.WebhookDeliveryGetter
+	retryWebhookDelivery.WebhookDeliveryRetrier
+	createProject.ProjectCreator
+	listProjects.ProjectsLister
+	deleteProject.ProjectDeleter
+	listAudit.AuditLogGetter
+	eventChanges.ChangesGetter
+	createAPIKey.APIKeyCreator
+	listAPIKeys.APIKeysLister
+	revokeAPIKey.APIKeyRevoker
+	exportSnapshot.SnapshotExporter
+	restoreSnapshot.SnapshotImporter
+}
+
+type Scheduler interface {
+	listJobs.JobsLister
+	runJob.JobTrigger
+}
+
+type Reloader interface {
+	getReload.LastReloadGetter
+	runReload.Reloader
+}
+
+type Probes interface {
+	liveness.LivenessChecker
+	readiness.ReadinessChecker
+}
+
+type Metrics interface {
+	mwMetrics.HTTPObserver
+	Handler() http.Handler
+}
+
+// Dependencies are the services behind the routes. Limiter may be nil, then
+// requests are not rate limited per client. AddressLimiter may be nil, then
+// requests are not rate limited per remote address before authentication.
+// Reloader may be nil, then the config can not be reloaded through the API.
+// Writers may be nil, then reads are not sent to the primary database after
+// changes.
+type Dependencies struct {
+	Storage        Storage
+	Jobs           Scheduler
+	Probes         Probes
+	Metrics        Metrics
+	Limiter        *ratelimit.Limiter
+	AddressLimiter *ratelimit.Limiter
+	Events         streamEvents.EventStreamer
+	Reloader       Reloader
+	Writers        *consistency.Writers
+}
+
+// New returns the router of the HTTP API with all its middlewares.
+func New(log *slog.Logger, cfg *config.Config, deps Dependencies) chi.Router {
+	router := chi.NewRouter()
+
+	router.Use(
+		middleware.RequestID,
+		mwTracing.New(),
+		middleware.Logger,
+		mwLogger.New(log),
+		mwMetrics.New(deps.Metrics),
+		middleware.Recoverer,
+	)
+
+	router.Get("/healthz", liveness.New(deps.Probes))
+	router.Get("/readyz", readiness.New(log, deps.Probes))
+
+	router.Get("/swagger/*", httpSwagger.Handler(
+		httpSwagger.URL("/swagger/doc.json"),
+	))
+
+	authenticate := mwAuth.Anonymous()
+	if cfg.Auth.Enabled {
+		authenticate = mwAuth.Authenticate(log, deps.Storage)
+	}
+
+	reader := mwAuth.RequireRole(auth.RoleReader)
+	assigner := mwAuth.RequireRole(auth.RoleAssigner)
+	admin := mwAuth.RequireRole(auth.RoleAdmin)
+
+	global := mwAuth.RequireGlobal()
+
+	// Segments and users live in a project. Routes without a project prefix
+	// are kept for compatibility and address the default project.
+	projectRoutes := func(router chi.Router) {
+		router.Use(mwProject.New(log, deps.Storage))
+
+		router.Route("/segments", func(r chi.Router) {
+			r.With(admin).Post("/", createSegment.New(log, deps.Storage))
+			r.With(admin).Delete("/{slug}", deleteSegment.New(log, deps.Storage))
+			r.With(reader).Get("/{slug}", getSegment.New(log, deps.Storage))
+			r.With(reader).Get("/{slug}/stats", getSegmentStats.New(log, deps.Storage))
+		})
+
+		router.Route("/users", func(r chi.Router) {
+			r.With(assigner).Post("/", createUser.New(log, deps.Storage))
+			r.With(reader).Get("/{id}/segments", getUserSegments.New(log, deps.Storage))
+			r.With(reader, mwConsistency.ReadOnly()).Post("/segments:batchGet", batchGetUsersSegments.New(log, deps.Storage))
+			r.With(reader).Get("/{id}/download-segments-history", downloadUserSegmentsHistory.New(log, deps.Storage))
+			r.With(assigner).Patch("/{id}/segments", updateUserSegments.New(log, deps.Storage))
+		})
+
+		router.Route("/webhooks", func(r chi.Router) {
+			r.Use(admin)
+
+			r.Post("/", createWebhook.New(log, deps.Storage))
+			r.Get("/", listWebhooks.New(log, deps.Storage))
+			r.Delete("/{id}", deleteWebhook.New(log, deps.Storage))
+			r.Get("/deliveries", listWebhookDeliveries.New(log, deps.Storage))
+			r.Get("/deliveries/{id}", getWebhookDelivery.New(log, deps.Storage))
+			r.Post("/deliveries/{id}/retry", retryWebhookDelivery.New(log, deps.Storage))
+		})
+
+		router.With(reader).Get("/events", streamEvents.New(log, deps.Events, cfg.Events.Heartbeat))
+		router.With(reader).Get("/events/changes", eventChanges.New(log, deps.Storage))
+	}
+
+	router.Group(func(router chi.Router) {
+		// Requests with bad keys are limited before the key lookup
+		if cfg.RateLimit.Enabled && deps.AddressLimiter != nil {
+			router.Use(mwRateLimit.NewByAddress(deps.AddressLimiter))
+		}
+		router.Use(authenticate, mwAudit.New())
+		if cfg.RateLimit.Enabled && deps.Limiter != nil {
+			router.Use(mwRateLimit.New(deps.Limiter))
+		}
+		if deps.Writers != nil {
+			router.Use(mwConsistency.New(deps.Writers))
+		}
+
+		router.Group(projectRoutes)
+
+		router.Route("/projects", func(r chi.Router) {
+			r.With(admin, global).Post("/", createProject.New(log, deps.Storage))
+			r.With(admin, global).Get("/", listProjects.New(log, deps.Storage))
+			r.Route("/{project}", func(r chi.Router) {
+				r.With(admin, global).Delete("/", deleteProject.New(log, deps.Storage))
+				r.Group(projectRoutes)
+			})
+		})
+
+		router.With(admin, global).Get("/audit", listAudit.New(log, deps.Storage))
+		// Route and client labels are not for everyone to see
+		router.With(admin, global).Method(http.MethodGet, "/metrics", deps.Metrics.Handler())
+
+		router.Route("/admin", func(r chi.Router) {
+			r.Use(admin, global)
+
+			r.Get("/jobs", listJobs.New(log, deps.Jobs))
+			r.Post("/jobs/{name}/run", runJob.New(log, deps.Jobs))
+
+			if deps.Reloader != nil {
+				r.Get("/config/reload", getReload.New(log, deps.Reloader))
+				r.Post("/config/reload", runReload.New(log, deps.Reloader))
+			}
+
+			r.Post("/api-keys", createAPIKey.New(log, deps.Storage))
+			r.Get("/api-keys", listAPIKeys.New(log, deps.Storage))
+			r.Delete("/api-keys/{id}", revokeAPIKey.New(log, deps.Storage))
+
+			r.Get("/snapshot", exportSnapshot.New(log, deps.Storage))
+			r.Post("/snapshot", restoreSnapshot.New(log, deps.Storage))
+		})
+	})
+
+	return router
+}
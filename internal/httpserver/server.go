@@ -0,0 +1,115 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/sl"
+)
+
+type Server struct {
+	log             *slog.Logger
+	server          *http.Server
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+	onShutdown      []func()
+}
+
+func New(log *slog.Logger, cfg config.HTTPServer, handler http.Handler) *Server {
+	return &Server{
+		log: log,
+		server: &http.Server{
+			Addr:         cfg.Address,
+			Handler:      handler,
+			ReadTimeout:  cfg.Timeout,
+			WriteTimeout: cfg.Timeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+		shutdownDelay:   cfg.ShutdownDelay,
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// OnShutdown registers f to be called as soon as shutdown begins, before the
+// listener is closed. Use it to fail readiness probes while still serving.
+func (s *Server) OnShutdown(f func()) {
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+// Run serves HTTP on the configured address until ctx is done, then drains
+// in-flight requests. Requests still running after the shutdown timeout have
+// their contexts cancelled, so storage calls made with r.Context() are aborted.
+func (s *Server) Run(ctx context.Context) error {
+	const op = "httpserver.Server.Run"
+
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: listen: %w", op, err)
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+// Serve is like Run but accepts connections on the given listener.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	const op = "httpserver.Server.Serve"
+
+	log := s.log.With(slog.String("op", op))
+
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	s.server.BaseContext = func(net.Listener) context.Context { return baseCtx }
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.server.Serve(listener)
+	}()
+
+	log.Info("server started", slog.String("address", listener.Addr().String()))
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("%s: serve: %w", op, err)
+	case <-ctx.Done():
+	}
+
+	log.Info("stopping server")
+
+	for _, f := range s.onShutdown {
+		f()
+	}
+
+	// Keep accepting requests while load balancers notice failing readiness
+	if s.shutdownDelay > 0 {
+		select {
+		case err := <-serveErr:
+			return fmt.Errorf("%s: serve: %w", op, err)
+		case <-time.After(s.shutdownDelay):
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to drain connections, cancelling in-flight requests", sl.Err(err))
+		cancelBase()
+		s.server.Close()
+		return fmt.Errorf("%s: shutdown: %w", op, err)
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: serve: %w", op, err)
+	}
+
+	log.Info("server stopped")
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package httpserver_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/config"
+	"segmentify/internal/httpserver"
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestServerGracefulShutdown(t *testing.T) {
+	cases := []struct {
+		name            string
+		shutdownTimeout time.Duration
+		handlerDelay    time.Duration
+		respCode        int
+		wantRunErr      bool
+		wantCtxCanceled bool
+	}{
+		{
+			name:            "Drains in-flight request",
+			shutdownTimeout: time.Second,
+			handlerDelay:    100 * time.Millisecond,
+			respCode:        http.StatusOK,
+		},
+		{
+			name:            "Cancels request context after timeout",
+			shutdownTimeout: 50 * time.Millisecond,
+			handlerDelay:    5 * time.Second,
+			wantRunErr:      true,
+			wantCtxCanceled: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			ctxCanceled := make(chan bool, 1)
+
+			// The handler stands in for a storage call that honours r.Context()
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tc.handlerDelay):
+					ctxCanceled <- false
+					w.WriteHeader(http.StatusOK)
+				case <-r.Context().Done():
+					ctxCanceled <- true
+				}
+			})
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			server := httpserver.New(
+				slogdiscard.NewDiscardLogger(),
+				config.HTTPServer{
+					Timeout:         10 * time.Second,
+					IdleTimeout:     10 * time.Second,
+					ShutdownTimeout: tc.shutdownTimeout,
+				},
+				handler,
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- server.Serve(ctx, listener)
+			}()
+
+			respCode := make(chan int, 1)
+			go func() {
+				resp, err := http.Get("http://" + listener.Addr().String())
+				if err != nil {
+					respCode <- 0
+					return
+				}
+				resp.Body.Close()
+				respCode <- resp.StatusCode
+			}()
+
+			<-started
+			cancel() // What SIGINT/SIGTERM does to the context in main
+
+			select {
+			case err := <-runErr:
+				if tc.wantRunErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("server did not stop")
+			}
+
+			require.Equal(t, tc.wantCtxCanceled, <-ctxCanceled)
+			if !tc.wantCtxCanceled {
+				require.Equal(t, tc.respCode, <-respCode)
+			}
+
+			_, err = net.Dial("tcp", listener.Addr().String())
+			require.Error(t, err, "listener must be closed after shutdown")
+		})
+	}
+}
@@ -26,6 +26,20 @@ func ErrInvalidRequest(msg string) *ErrResponse {
 	}
 }
 
+func ErrUnauthorized(msg string) *ErrResponse {
+	return &ErrResponse{
+		HTTPStatusCode: http.StatusUnauthorized,
+		ErrorText:      msg,
+	}
+}
+
+func ErrForbidden(msg string) *ErrResponse {
+	return &ErrResponse{
+		HTTPStatusCode: http.StatusForbidden,
+		ErrorText:      msg,
+	}
+}
+
 func ErrNotFound(msg string) *ErrResponse {
 	return &ErrResponse{
 		HTTPStatusCode: http.StatusNotFound,
@@ -33,6 +47,20 @@ func ErrNotFound(msg string) *ErrResponse {
 	}
 }
 
+func ErrConflict(msg string) *ErrResponse {
+	return &ErrResponse{
+		HTTPStatusCode: http.StatusConflict,
+		ErrorText:      msg,
+	}
+}
+
+func ErrTooManyRequests(msg string) *ErrResponse {
+	return &ErrResponse{
+		HTTPStatusCode: http.StatusTooManyRequests,
+		ErrorText:      msg,
+	}
+}
+
 func ErrRender(msg string) *ErrResponse {
 	return &ErrResponse{
 		HTTPStatusCode: http.StatusUnprocessableEntity,
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"segmentify/internal/cache"
+	"segmentify/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectTimeout bounds the database queries made during a single scrape.
+const collectTimeout = 5 * time.Second
+
+type PoolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+type poolCollector struct {
+	stater PoolStater
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+// NewPoolCollector exposes pgxpool statistics, read on every scrape.
+func NewPoolCollector(stater PoolStater) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+
+	return &poolCollector{
+		stater:               stater,
+		acquiredConns:        desc("acquired_conns", "Number of currently acquired connections."),
+		idleConns:            desc("idle_conns", "Number of currently idle connections."),
+		totalConns:           desc("total_conns", "Total number of connections in the pool."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		acquireCount:         desc("acquire_total", "Number of successful connection acquires."),
+		acquireDuration:      desc("acquire_wait_seconds_total", "Total time spent waiting for a connection."),
+		emptyAcquireCount:    desc("empty_acquire_total", "Number of acquires that had to wait for a connection."),
+		canceledAcquireCount: desc("canceled_acquire_total", "Number of acquires cancelled by a context."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquireCount
+	ch <- c.canceledAcquireCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stater.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+type SegmentMembersCounter interface {
+	CountSegmentMembers(ctx context.Context) ([]models.SegmentMembers, error)
+}
+
+type segmentsCollector struct {
+	counter SegmentMembersCounter
+
+	segments       *prometheus.Desc
+	segmentMembers *prometheus.Desc
+	up             *prometheus.Desc
+}
+
+// NewSegmentsCollector exposes business gauges queried from storage on
+// every scrape: the number of segments per project and active members per
+// segment.
+func NewSegmentsCollector(counter SegmentMembersCounter) prometheus.Collector {
+	return &segmentsCollector{
+		counter: counter,
+		segments: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "segments"),
+			"Number of segments.",
+			[]string{"project"}, nil,
+		),
+		segmentMembers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "segment_members"),
+			"Number of users with an active membership in the segment.",
+			[]string{"project", "segment"}, nil,
+		),
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "segments_scrape_success"),
+			"Whether the last scrape of segment gauges succeeded.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *segmentsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.segments
+	ch <- c.segmentMembers
+	ch <- c.up
+}
+
+func (c *segmentsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	members, err := c.counter.CountSegmentMembers(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	segments := map[string]int{}
+	for _, m := range members {
+		segments[m.Project]++
+		ch <- prometheus.MustNewConstMetric(c.segmentMembers, prometheus.GaugeValue, float64(m.Members), m.Project, m.Slug)
+	}
+	for project, count := range segments {
+		ch <- prometheus.MustNewConstMetric(c.segments, prometheus.GaugeValue, float64(count), project)
+	}
+}
+
+type CacheStater interface {
+	Stats() cache.Stats
+}
+
+type cacheCollector struct {
+	stater CacheStater
+
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+	errors *prometheus.Desc
+}
+
+// NewCacheCollector exposes the hit and miss counts of the user segments
+// cache, read on every scrape.
+func NewCacheCollector(stater CacheStater) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "user_segments_cache", name), help, nil, nil)
+	}
+
+	return &cacheCollector{
+		stater: stater,
+		hits:   desc("hits_total", "Number of user segments lookups served from the cache."),
+		misses: desc("misses_total", "Number of user segments lookups read from storage."),
+		errors: desc("errors_total", "Number of failed cache operations."),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.errors
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stater.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stats.Errors))
+}
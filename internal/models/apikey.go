@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// APIKey is bound to a single project when Project is set,
+// otherwise it grants access to all projects.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Role      string     `json:"role"`
+	Project   string     `json:"project,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records a single administrative change. Before is empty for
+// created entities and After is empty for deleted ones.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Project   string          `json:"project,omitempty"`
+	Actor     string          `json:"actor"`
+	APIKeyID  *int64          `json:"api_key_id,omitempty"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Before    json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After     json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	RequestID string          `json:"request_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit entries. Zero fields do not filter. Entries are
+// returned newest first; Cursor continues a previous page and holds the ID
+// of its last entry.
+type AuditFilter struct {
+	Project  string
+	Actor    string
+	Action   string
+	Entity   string
+	EntityID string
+	From     time.Time
+	To       time.Time
+	Cursor   int64
+	Limit    int64
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Job struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+	LastRun  *JobRun   `json:"last_run"`
+}
+
+type JobRun struct {
+	ID           int64      `json:"id"`
+	JobName      string     `json:"job_name"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	RowsAffected int64      `json:"rows_affected"`
+	Error        string     `json:"error,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Segment event types. They are published through the outbox only, webhooks
+// are limited to membership events.
+const (
+	EventSegmentCreated = "segment.created"
+	EventSegmentDeleted = "segment.deleted"
+)
+
+// EventSnapshotImported is written for every project a snapshot was imported
+// into. The import changes segments and memberships without events of their
+// own, so consumers keeping state of the project should drop it.
+const EventSnapshotImported = "snapshot.imported"
+
+// SegmentEvent is the payload of segment events.
+type SegmentEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Project    string    `json:"project"`
+	Segment    string    `json:"segment"`
+	Percent    int64     `json:"percent"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// SnapshotEvent is the payload of snapshot events.
+type SnapshotEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Project    string    `json:"project"`
+	Mode       string    `json:"mode"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// OutboxEvent is a change event waiting in the outbox. ID orders events: as
+// they were written for the outbox relay, as they were committed for event
+// streams, which resume after it. EventID identifies the event for consumers
+// and stays the same when the event is published again. Payload is a
+// MembershipEvent, a SegmentEvent or a SnapshotEvent.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	EventID   string          `json:"event_id"`
+	Type      string          `json:"type"`
+	Project   string          `json:"project"`
+	Payload   json.RawMessage `json:"payload" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// DefaultProject holds data created before projects existed and serves the
+// unscoped /segments and /users routes.
+const DefaultProject = "default"
+
+type Project struct {
+	Slug      string    `json:"slug" validate:"required"`
+	Name      string    `json:"name" validate:"required"`
+	CreatedAt time.Time `json:"created_at"`
+}
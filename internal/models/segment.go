@@ -15,3 +15,46 @@ type SegmentToAdd struct {
 type SegmentToRemove struct {
 	Slug string `json:"slug" validate:"required"`
 }
+
+type SegmentMembers struct {
+	Project string `json:"project"`
+	Slug    string `json:"slug"`
+	Members int64  `json:"members"`
+}
+
+// Preview is what a change would do, found by a dry run. UsersAffected lists
+// at most PreviewUsersLimit of the users, UsersAffectedCount counts all of
+// them. SegmentSizes are the numbers of active members of the segments the
+// change touches, as they would be after it.
+type Preview struct {
+	UsersAffected      []int64          `json:"users_affected"`
+	UsersAffectedCount int64            `json:"users_affected_count"`
+	Added              int64            `json:"added"`
+	Removed            int64            `json:"removed"`
+	Conflicts          []string         `json:"conflicts"`
+	SegmentSizes       map[string]int64 `json:"segment_sizes"`
+}
+
+const PreviewUsersLimit = 1000
+
+// SegmentStats describes the members of a segment. Percent is the configured
+// percentage, ActualPercent the share of the project users that are active
+// members now. Days are the days of the requested range in order.
+type SegmentStats struct {
+	Slug           string       `json:"slug"`
+	Percent        int64        `json:"percent"`
+	ActualPercent  float64      `json:"actual_percent"`
+	Members        int64        `json:"members"`
+	MembersWithTTL int64        `json:"members_with_ttl"`
+	Users          int64        `json:"users"`
+	Days           []SegmentDay `json:"days"`
+}
+
+// SegmentDay counts the changes of a segment's history on a UTC day. Size is
+// the number of members at the end of the day according to the history.
+type SegmentDay struct {
+	Date    string `json:"date" example:"2023-09-12"`
+	Added   int64  `json:"added"`
+	Removed int64  `json:"removed"`
+	Size    int64  `json:"size"`
+}
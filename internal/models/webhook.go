@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Membership event types.
+const (
+	EventUserAdded   = "segment.user_added"
+	EventUserRemoved = "segment.user_removed"
+)
+
+// Reasons of membership changes.
+const (
+	ReasonManual  = "manual"
+	ReasonRollout = "rollout"
+	ReasonExpired = "expired"
+	// ReasonSegmentDeleted removes the members of a deleted segment.
+	ReasonSegmentDeleted = "segment_deleted"
+)
+
+// Webhook delivery statuses. Dead deliveries ran out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Webhook subscribes URL to membership events of a project. Empty Segments or
+// Events subscribe to all of them. Secret signs deliveries and is only
+// returned on creation.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Project   string    `json:"project"`
+	URL       string    `json:"url" validate:"required,http_url"`
+	Secret    string    `json:"secret,omitempty"`
+	Segments  []string  `json:"segments"`
+	Events    []string  `json:"events" validate:"dive,oneof=segment.user_added segment.user_removed" example:"segment.user_added"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MembershipEvent is the body of webhook deliveries and the payload of outbox
+// events. ID is shared by the outbox event and its deliveries to webhooks.
+type MembershipEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Project    string    `json:"project"`
+	Segment    string    `json:"segment"`
+	UserID     int64     `json:"user_id"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int64           `json:"webhook_id"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	// AttemptsLog is only filled when a single delivery is requested.
+	AttemptsLog []WebhookAttempt `json:"attempts_log,omitempty"`
+
+	// URL and Secret of the webhook are filled for the dispatcher only.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookAttempt struct {
+	AttemptedAt    time.Time `json:"attempted_at"`
+	DurationMS     int64     `json:"duration_ms"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// WebhookDeliveryFilter selects deliveries of a project, newest first. Zero
+// fields do not filter.
+type WebhookDeliveryFilter struct {
+	WebhookID int64
+	Status    string
+	Limit     int64
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/models"
+)
+
+// lockName is the advisory lock held while relaying a batch, so replicas
+// sharing the database never publish events concurrently or out of order.
+const lockName = "outbox_relay"
+
+// Publisher sends events to a broker or another consumer. Publish must not
+// return before the event is accepted: the event is only marked published
+// afterwards. An event may be published more than once, consumers should drop
+// duplicates by its EventID.
+type Publisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+type Storage interface {
+	TryAdvisoryLock(ctx context.Context, name string) (func(), bool, error)
+	GetUnpublishedEvents(ctx context.Context, limit int64) ([]models.OutboxEvent, error)
+	MarkEventsPublished(ctx context.Context, ids []int64) error
+}
+
+// Relay publishes events from the outbox in the order they were written.
+type Relay struct {
+	log       *slog.Logger
+	storage   Storage
+	publisher Publisher
+	cfg       config.Outbox
+}
+
+func New(log *slog.Logger, storage Storage, publisher Publisher, cfg config.Outbox) *Relay {
+	return &Relay{
+		log:       log.With(slog.String("component", "outbox")),
+		storage:   storage,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+// Run relays events every poll interval until ctx is done. After a failure
+// the wait is doubled up to RetryMax, so an unavailable publisher is not
+// hammered.
+func (r *Relay) Run(ctx context.Context) {
+	wait := r.cfg.PollInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		failed := false
+
+		// Drain the outbox before waiting again
+		for {
+			n, err := r.Relay(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.log.Error("failed to relay events", sl.Err(err))
+				}
+				failed = true
+				break
+			}
+			if int64(n) < r.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if failed {
+			wait = min(2*wait, max(r.cfg.RetryMax, r.cfg.PollInterval))
+		} else {
+			wait = r.cfg.PollInterval
+		}
+	}
+}
+
+// Relay publishes one batch of events and returns how many were published.
+// Publishing stops at the first failure so later events are not published
+// ahead of it. Nothing is published if another replica is relaying.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	const op = "outbox.Relay.Relay"
+
+	release, acquired, err := r.storage.TryAdvisoryLock(ctx, lockName)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !acquired {
+		return 0, nil
+	}
+	defer release()
+
+	events, err := r.storage.GetUnpublishedEvents(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	published := make([]int64, 0, len(events))
+
+	var publishErr error
+	for _, event := range events {
+		if publishErr = r.publisher.Publish(ctx, event); publishErr != nil {
+			publishErr = fmt.Errorf("%s: publish event %s: %w", op, event.EventID, publishErr)
+			break
+		}
+		published = append(published, event.ID)
+	}
+
+	if len(published) > 0 {
+		// Mark even if the relay is stopping, otherwise the events would be
+		// published once more by the next relay
+		if err := r.storage.MarkEventsPublished(context.WithoutCancel(ctx), published); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(published), publishErr
+}
@@ -0,0 +1,146 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/config"
+	"segmentify/internal/models"
+	"segmentify/internal/outbox"
+	"segmentify/internal/webhooks"
+)
+
+// memoryStorage keeps the outbox in memory.
+type memoryStorage struct {
+	events    []models.OutboxEvent
+	published map[int64]bool
+	locked    bool
+}
+
+func (s *memoryStorage) TryAdvisoryLock(context.Context, string) (func(), bool, error) {
+	if s.locked {
+		return nil, false, nil
+	}
+	s.locked = true
+	return func() { s.locked = false }, true, nil
+}
+
+func (s *memoryStorage) GetUnpublishedEvents(_ context.Context, limit int64) ([]models.OutboxEvent, error) {
+	events := []models.OutboxEvent{}
+	for _, e := range s.events {
+		if !s.published[e.ID] && int64(len(events)) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStorage) MarkEventsPublished(_ context.Context, ids []int64) error {
+	for _, id := range ids {
+		s.published[id] = true
+	}
+	return nil
+}
+
+// flakyPublisher records published event IDs and fails on the events in fail.
+type flakyPublisher struct {
+	published []string
+	fail      map[string]bool
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event models.OutboxEvent) error {
+	if p.fail[event.EventID] {
+		return errors.New("broker is unavailable")
+	}
+	p.published = append(p.published, event.EventID)
+	return nil
+}
+
+func newEvent(id int64) models.OutboxEvent {
+	eventID := "e" + strconv.FormatInt(id, 10)
+	return models.OutboxEvent{
+		ID:      id,
+		EventID: eventID,
+		Type:    models.EventUserAdded,
+		Project: models.DefaultProject,
+		Payload: json.RawMessage(`{"id":"` + eventID + `"}`),
+	}
+}
+
+func TestRelay(t *testing.T) {
+	storage := &memoryStorage{published: map[int64]bool{}}
+	for id := int64(1); id <= 5; id++ {
+		storage.events = append(storage.events, newEvent(id))
+	}
+	publisher := &flakyPublisher{fail: map[string]bool{"e3": true}}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	relay := outbox.New(log, storage, publisher, config.Outbox{BatchSize: 10})
+
+	// Publishing stops at the failed event, so later events keep their order
+	n, err := relay.Relay(context.Background())
+	require.Error(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"e1", "e2"}, publisher.published)
+	require.False(t, storage.locked)
+
+	// Another replica is relaying
+	storage.locked = true
+	delete(publisher.fail, "e3")
+	n, err = relay.Relay(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+	storage.locked = false
+
+	n, err = relay.Relay(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, []string{"e1", "e2", "e3", "e4", "e5"}, publisher.published)
+
+	n, err = relay.Relay(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := outbox.NewWriterPublisher(&buf)
+
+	require.NoError(t, publisher.Publish(context.Background(), newEvent(1)))
+	require.NoError(t, publisher.Publish(context.Background(), newEvent(2)))
+
+	require.Equal(t, "{\"id\":\"e1\"}\n{\"id\":\"e2\"}\n", buf.String())
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var received []string
+	status := http.StatusAccepted
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, models.EventUserAdded, r.Header.Get(webhooks.HeaderEvent))
+		require.JSONEq(t, `{"id":"`+r.Header.Get(webhooks.HeaderEventID)+`"}`, string(body))
+		received = append(received, r.Header.Get(webhooks.HeaderEventID))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	publisher := outbox.NewHTTPPublisher(server.URL, server.Client())
+
+	require.NoError(t, publisher.Publish(context.Background(), newEvent(1)))
+
+	status = http.StatusServiceUnavailable
+	require.Error(t, publisher.Publish(context.Background(), newEvent(2)))
+
+	require.Equal(t, []string{"e1", "e2"}, received)
+}
@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"segmentify/internal/config"
+	"segmentify/internal/models"
+	"segmentify/internal/tracing"
+	"segmentify/internal/webhooks"
+)
+
+// Publishers accepted by the OUTBOX_PUBLISHER setting.
+const (
+	PublisherNone   = "none"
+	PublisherStdout = "stdout"
+	PublisherFile   = "file"
+	PublisherHTTP   = "http"
+)
+
+// maxErrorBody bounds how much of a failed response is kept in the error.
+const maxErrorBody = 512
+
+// NewPublisher returns the publisher selected by cfg and a function closing
+// it.
+func NewPublisher(cfg config.Outbox) (Publisher, func() error, error) {
+	const op = "outbox.NewPublisher"
+
+	noop := func() error { return nil }
+
+	switch cfg.Publisher {
+	case PublisherNone:
+		return Discard{}, noop, nil
+	case PublisherStdout:
+		return NewWriterPublisher(os.Stdout), noop, nil
+	case PublisherFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: open file: %w", op, err)
+		}
+		return NewWriterPublisher(f), f.Close, nil
+	case PublisherHTTP:
+		if cfg.URL == "" {
+			return nil, nil, fmt.Errorf("%s: URL is required by the http publisher", op)
+		}
+		return NewHTTPPublisher(cfg.URL, &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &tracing.Transport{},
+		}), noop, nil
+	default:
+		return nil, nil, fmt.Errorf("%s: unknown publisher %q", op, cfg.Publisher)
+	}
+}
+
+// Discard drops events, marking them published without sending them anywhere.
+type Discard struct{}
+
+func (Discard) Publish(context.Context, models.OutboxEvent) error {
+	return nil
+}
+
+// WriterPublisher writes event payloads to w as newline-delimited JSON.
+// Writes to files are synced before an event counts as published.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+func (p *WriterPublisher) Publish(_ context.Context, event models.OutboxEvent) error {
+	const op = "outbox.WriterPublisher.Publish"
+
+	line := make([]byte, 0, len(event.Payload)+1)
+	line = append(line, event.Payload...)
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.w.Write(line); err != nil {
+		return fmt.Errorf("%s: write event: %w", op, err)
+	}
+
+	if f, ok := p.w.(*os.File); ok && f != os.Stdout {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("%s: sync file: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// HTTPPublisher posts every event payload to a URL. The headers are the same
+// as of webhook deliveries, without the signature. Any 2xx response accepts
+// the event.
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, client *http.Client) *HTTPPublisher {
+	return &HTTPPublisher{url: url, client: client}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	const op = "outbox.HTTPPublisher.Publish"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(event.Payload))
+	if err != nil {
+		return fmt.Errorf("%s: create request: %w", op, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "segmentify-outbox")
+	req.Header.Set(webhooks.HeaderEvent, event.Type)
+	req.Header.Set(webhooks.HeaderEventID, event.EventID)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: send request: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("%s: unexpected status %d: %s", op, resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
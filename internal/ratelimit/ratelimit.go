@@ -0,0 +1,223 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentify/internal/config"
+)
+
+// DefaultRule names the limit applied to routes without a limit of their own.
+const DefaultRule = "default"
+
+// sweepInterval is how often buckets that refilled completely are dropped,
+// so memory is bounded by the number of recently active clients.
+const sweepInterval = time.Minute
+
+// Limit allows Requests per Period. A client may spend all of them at once,
+// after which they are refilled evenly over the period.
+type Limit struct {
+	Requests int64
+	Period   time.Duration
+}
+
+// ParseLimit parses "<requests>/<period>", where period is a duration such
+// as "10s", or a bare unit: "s", "m" or "h". For example "100/s" or "1000/1h".
+func ParseLimit(spec string) (Limit, error) {
+	const op = "ratelimit.ParseLimit"
+
+	requests, period, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%s: invalid limit %q, expected <requests>/<period>", op, spec)
+	}
+
+	n, err := strconv.ParseInt(requests, 10, 64)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%s: invalid number of requests in %q", op, spec)
+	}
+
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%s: invalid period in %q", op, spec)
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// ParseConfig parses the default limit and the per-route limits of cfg.
+func ParseConfig(cfg config.RateLimit) (Limit, map[string]Limit, error) {
+	const op = "ratelimit.ParseConfig"
+
+	def, err := ParseLimit(cfg.Default)
+	if err != nil {
+		return Limit{}, nil, fmt.Errorf("%s: default: %w", op, err)
+	}
+
+	rules := make(map[string]Limit, len(cfg.Routes))
+
+	for _, spec := range cfg.Routes {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		i := strings.LastIndex(spec, "=")
+		if i < 0 {
+			return Limit{}, nil, fmt.Errorf("%s: invalid route limit %q, expected \"METHOD pattern=limit\"", op, spec)
+		}
+
+		route := strings.Join(strings.Fields(spec[:i]), " ")
+		if method, pattern, ok := strings.Cut(route, " "); !ok || method == "" || !strings.HasPrefix(pattern, "/") {
+			return Limit{}, nil, fmt.Errorf("%s: invalid route in %q, expected \"METHOD pattern\"", op, spec)
+		}
+
+		limit, err := ParseLimit(spec[i+1:])
+		if err != nil {
+			return Limit{}, nil, fmt.Errorf("%s: route %s: %w", op, route, err)
+		}
+		rules[route] = limit
+	}
+
+	return def, rules, nil
+}
+
+// ParseAddressLimit parses the limit of requests per remote address of cfg.
+func ParseAddressLimit(cfg config.RateLimit) (Limit, error) {
+	const op = "ratelimit.ParseAddressLimit"
+
+	limit, err := ParseLimit(cfg.Address)
+	if err != nil {
+		return Limit{}, fmt.Errorf("%s: address: %w", op, err)
+	}
+
+	return limit, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// rate returns tokens refilled per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result describes the state of a client bucket after a request.
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// RetryAfter is how long to wait until the next request is allowed. It is
+	// zero for allowed requests.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.Requests), b.tokens+now.Sub(b.last).Seconds()*b.limit.rate())
+	b.last = now
+}
+
+type bucketKey struct {
+	rule   string
+	client string
+}
+
+// Limiter keeps a token bucket per client and rule. Rules are route names
+// with limits of their own; all other routes share the default rule.
+type Limiter struct {
+	mu        sync.Mutex
+	def       Limit
+	rules     map[string]Limit
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New(def Limit, rules map[string]Limit) *Limiter {
+	return &Limiter{
+		def:     def,
+		rules:   rules,
+		buckets: map[bucketKey]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// SetLimits replaces the limits. Existing buckets keep their tokens and
+// switch to the new limits on their next request.
+func (l *Limiter) SetLimits(def Limit, rules map[string]Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.def = def
+	l.rules = rules
+}
+
+// Allow takes a token for a request of client to route.
+func (l *Limiter) Allow(client, route string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	rule, limit := route, l.rules[route]
+	if limit.Requests == 0 {
+		rule, limit = DefaultRule, l.def
+	}
+
+	key := bucketKey{rule: rule, client: client}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limit: limit, tokens: float64(limit.Requests), last: now}
+		l.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+
+	res := Result{Limit: limit.Requests}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.rate())
+	}
+
+	res.Remaining = int64(b.tokens)
+	res.Reset = seconds((float64(limit.Requests) - b.tokens) / limit.rate())
+
+	return res
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
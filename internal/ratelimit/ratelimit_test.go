@@ -0,0 +1,85 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/config"
+	"segmentify/internal/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	cases := []struct {
+		spec  string
+		limit ratelimit.Limit
+		err   bool
+	}{
+		{spec: "100/s", limit: ratelimit.Limit{Requests: 100, Period: time.Second}},
+		{spec: "10/m", limit: ratelimit.Limit{Requests: 10, Period: time.Minute}},
+		{spec: "1000/1h", limit: ratelimit.Limit{Requests: 1000, Period: time.Hour}},
+		{spec: "5/10s", limit: ratelimit.Limit{Requests: 5, Period: 10 * time.Second}},
+		{spec: "100", err: true},
+		{spec: "0/s", err: true},
+		{spec: "10/fortnight", err: true},
+	}
+
+	for _, tc := range cases {
+		limit, err := ratelimit.ParseLimit(tc.spec)
+		if tc.err {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.limit, limit)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	def, rules, err := ratelimit.ParseConfig(config.RateLimit{
+		Default: "100/s",
+		Routes:  []string{"PATCH  /users/{id}/segments=10/s", ""},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.Limit{Requests: 100, Period: time.Second}, def)
+	require.Equal(t, map[string]ratelimit.Limit{
+		"PATCH /users/{id}/segments": {Requests: 10, Period: time.Second},
+	}, rules)
+
+	_, _, err = ratelimit.ParseConfig(config.RateLimit{Default: "100/s", Routes: []string{"/users=10/s"}})
+	require.Error(t, err)
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := ratelimit.New(
+		ratelimit.Limit{Requests: 3, Period: time.Hour},
+		map[string]ratelimit.Limit{"PATCH /users/{id}/segments": {Requests: 1, Period: time.Hour}},
+	)
+
+	// A route with its own limit has its own bucket
+	res := limiter.Allow("key:1", "PATCH /users/{id}/segments")
+	require.True(t, res.Allowed)
+	require.Equal(t, int64(1), res.Limit)
+	require.Equal(t, int64(0), res.Remaining)
+
+	res = limiter.Allow("key:1", "PATCH /users/{id}/segments")
+	require.False(t, res.Allowed)
+	require.InDelta(t, time.Hour.Seconds(), res.RetryAfter.Seconds(), 1)
+
+	// Other routes share the default bucket
+	for i := 0; i < 3; i++ {
+		res = limiter.Allow("key:1", "GET /segments/{slug}")
+		require.True(t, res.Allowed)
+	}
+	res = limiter.Allow("key:1", "POST /users")
+	require.False(t, res.Allowed)
+	require.Equal(t, int64(3), res.Limit)
+	require.InDelta(t, (20 * time.Minute).Seconds(), res.RetryAfter.Seconds(), 1)
+	require.InDelta(t, time.Hour.Seconds(), res.Reset.Seconds(), 1)
+
+	// Clients do not share buckets
+	res = limiter.Allow("key:2", "POST /users")
+	require.True(t, res.Allowed)
+	require.Equal(t, int64(2), res.Remaining)
+}
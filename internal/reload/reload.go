@@ -0,0 +1,226 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/sl"
+)
+
+// Triggers of a reload.
+const (
+	TriggerSignal = "signal"
+	TriggerFile   = "file"
+	TriggerAPI    = "api"
+)
+
+// Statuses of a reload.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Applier checks the reloadable settings of cfg and returns a function
+// applying them. It must not change anything itself: the returned functions
+// are called only once every applier succeeded, so a reload applies all of
+// the settings or none.
+type Applier func(cfg *config.Config) (apply func(), err error)
+
+// Auditor records reloads in the audit log.
+type Auditor interface {
+	WriteAudit(ctx context.Context, action, entity, entityID string, before, after any) error
+}
+
+// Result describes a reload.
+type Result struct {
+	At      time.Time `json:"at"`
+	Trigger string    `json:"trigger"`
+	Status  string    `json:"status"`
+	// Changed lists the reloadable settings that changed, applied or, if
+	// the reload failed, rejected.
+	Changed []string `json:"changed"`
+	// RestartRequired lists the changed settings that are applied on restart
+	// only.
+	RestartRequired []string `json:"restart_required"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// Reloader loads the configuration again and applies the settings that can
+// change at runtime.
+type Reloader struct {
+	log     *slog.Logger
+	load    func() (*config.Config, error)
+	auditor Auditor
+	now     func() time.Time
+
+	mu       sync.Mutex
+	appliers []Applier
+	// started is the configuration the service was started with, current
+	// is the one applied last.
+	started *config.Config
+	current *config.Config
+	last    *Result
+}
+
+// New returns a reloader of the configuration cfg, which load reads again.
+// Every reload is recorded by auditor, if it is not nil.
+func New(log *slog.Logger, cfg *config.Config, load func() (*config.Config, error), auditor Auditor) *Reloader {
+	return &Reloader{
+		log:     log.With(slog.String("component", "reload")),
+		load:    load,
+		auditor: auditor,
+		now:     time.Now,
+		started: cfg,
+		current: cfg,
+	}
+}
+
+// Add registers an applier of reloadable settings.
+func (r *Reloader) Add(applier Applier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.appliers = append(r.appliers, applier)
+}
+
+// Reload loads the configuration and applies it. Failures are reported in
+// the result and leave the running configuration as it is. The reload is
+// recorded as done by the actor of ctx.
+func (r *Reloader) Reload(ctx context.Context, trigger string) Result {
+	res := r.reload(trigger)
+
+	if r.auditor != nil {
+		entityID := r.started.File
+		if entityID == "" {
+			entityID = "env"
+		}
+		// Only the keys are recorded, values may be secrets
+		if err := r.auditor.WriteAudit(ctx, audit.ActionReload, audit.EntityConfig, entityID, nil, res); err != nil {
+			r.log.Error("failed to audit config reload", sl.Err(err))
+		}
+	}
+
+	return res
+}
+
+func (r *Reloader) reload(trigger string) Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := Result{At: r.now(), Trigger: trigger, Changed: []string{}, RestartRequired: []string{}}
+
+	if err := r.apply(&res); err != nil {
+		res.Status = StatusFailed
+		res.Error = err.Error()
+		r.log.Error("failed to reload config", slog.String("trigger", trigger), sl.Err(err))
+	} else {
+		res.Status = StatusOK
+		r.log.Info("config reloaded",
+			slog.String("trigger", trigger),
+			slog.Any("changed", res.Changed),
+			slog.Any("restart_required", res.RestartRequired),
+		)
+	}
+
+	r.last = &res
+
+	return res
+}
+
+func (r *Reloader) apply(res *Result) error {
+	cfg, err := r.load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	// Changes are listed before they are applied, so rejected ones are
+	// reported too
+	if changed, _ := config.Diff(r.current, cfg); changed != nil {
+		res.Changed = changed
+	}
+	if _, restart := config.Diff(r.started, cfg); restart != nil {
+		res.RestartRequired = restart
+	}
+
+	applies := make([]func(), 0, len(r.appliers))
+	for _, applier := range r.appliers {
+		apply, err := applier(cfg)
+		if err != nil {
+			return err
+		}
+		applies = append(applies, apply)
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+	r.current = cfg
+
+	return nil
+}
+
+// LastReload returns the result of the last reload, if there was one.
+func (r *Reloader) LastReload() (Result, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.last == nil {
+		return Result{}, false
+	}
+	return *r.last, true
+}
+
+// Run reloads on SIGHUP and, if interval is positive, when the config file
+// changes, until ctx is done.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	file := r.started.File
+
+	var watch <-chan time.Time
+	if interval > 0 && file != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+
+	modTime := fileModTime(file)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload(ctx, TriggerSignal)
+		case <-watch:
+			// Editors often replace the file, so compare the time rather
+			// than watch the inode
+			if t := fileModTime(file); !t.Equal(modTime) {
+				modTime = t
+				r.Reload(ctx, TriggerFile)
+			}
+		}
+	}
+}
+
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
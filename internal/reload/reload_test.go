@@ -0,0 +1,110 @@
+package reload_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+	"segmentify/internal/reload"
+)
+
+// auditor keeps the recorded reloads.
+type auditor struct {
+	entries []reload.Result
+}
+
+func (a *auditor) WriteAudit(_ context.Context, action, entity, _ string, _, after any) error {
+	if action == audit.ActionReload && entity == audit.EntityConfig {
+		a.entries = append(a.entries, after.(reload.Result))
+	}
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	started := &config.Config{
+		Env:       "prod",
+		Log:       config.Log{Level: "info"},
+		Postgres:  config.Postgres{URL: "postgres://localhost:5432/segmentify"},
+		RateLimit: config.RateLimit{Default: "100/s"},
+	}
+
+	next := *started
+	var loadErr error
+	load := func() (*config.Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		cfg := next
+		return &cfg, nil
+	}
+
+	audited := &auditor{}
+	reloader := reload.New(slogdiscard.NewDiscardLogger(), started, load, audited)
+
+	var level, limit string
+	reloader.Add(func(cfg *config.Config) (func(), error) {
+		return func() { level = cfg.Log.Level }, nil
+	})
+	reloader.Add(func(cfg *config.Config) (func(), error) {
+		if cfg.RateLimit.Default == "" {
+			return nil, errors.New("no default limit")
+		}
+		return func() { limit = cfg.RateLimit.Default }, nil
+	})
+
+	_, ok := reloader.LastReload()
+	require.False(t, ok)
+
+	// Reloadable settings are applied, others are reported
+	next.Log.Level = "debug"
+	next.Postgres.URL = "postgres://replica:5432/segmentify"
+
+	res := reloader.Reload(context.Background(), reload.TriggerAPI)
+	require.Equal(t, reload.StatusOK, res.Status)
+	require.Equal(t, []string{"LOG_LEVEL"}, res.Changed)
+	require.Equal(t, []string{"POSTGRES_URL"}, res.RestartRequired)
+	require.Equal(t, "debug", level)
+	require.Equal(t, "100/s", limit)
+
+	last, ok := reloader.LastReload()
+	require.True(t, ok)
+	require.Equal(t, res, last)
+
+	// A failing applier keeps every setting as it is
+	next.Log.Level = "warn"
+	next.RateLimit.Default = ""
+
+	res = reloader.Reload(context.Background(), reload.TriggerSignal)
+	require.Equal(t, reload.StatusFailed, res.Status)
+	require.Equal(t, "no default limit", res.Error)
+	require.Equal(t, []string{"LOG_LEVEL", "RATE_LIMIT_DEFAULT"}, res.Changed)
+	require.Equal(t, "debug", level)
+
+	loadErr = errors.New("no such file")
+
+	res = reloader.Reload(context.Background(), reload.TriggerFile)
+	require.Equal(t, reload.StatusFailed, res.Status)
+	require.Contains(t, res.Error, "no such file")
+
+	// Changes are reported against the settings applied last
+	loadErr = nil
+	next.Log.Level = "debug"
+	next.RateLimit.Default = "10/s"
+
+	res = reloader.Reload(context.Background(), reload.TriggerAPI)
+	require.Equal(t, reload.StatusOK, res.Status)
+	require.Equal(t, []string{"RATE_LIMIT_DEFAULT"}, res.Changed)
+	require.Equal(t, "10/s", limit)
+
+	// Applied and rejected reloads are audited alike
+	require.Len(t, audited.entries, 4)
+	require.Equal(t, []string{reload.StatusOK, reload.StatusFailed, reload.StatusFailed, reload.StatusOK}, []string{
+		audited.entries[0].Status, audited.entries[1].Status, audited.entries[2].Status, audited.entries[3].Status,
+	})
+	require.Equal(t, []string{"LOG_LEVEL", "RATE_LIMIT_DEFAULT"}, audited.entries[1].Changed)
+}
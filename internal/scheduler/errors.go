@@ -0,0 +1,21 @@
+package scheduler
+
+import (
+	"fmt"
+)
+
+type ErrJobNotFound struct {
+	Name string
+}
+
+func (e ErrJobNotFound) Error() string {
+	return fmt.Sprintf("job with name=%s not found", e.Name)
+}
+
+type ErrJobLocked struct {
+	Name string
+}
+
+func (e ErrJobLocked) Error() string {
+	return fmt.Sprintf("job with name=%s is already running", e.Name)
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule returns the next activation time strictly after t.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule fires on multiples of every since the Unix epoch, so all
+// replicas agree on the activation times regardless of when they started.
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.every).Add(s.every)
+}
+
+// ParseSchedule accepts either "@every <duration>" or a standard five-field
+// cron expression (descriptors such as "@hourly" are supported as well).
+func ParseSchedule(spec string) (Schedule, error) {
+	const op = "scheduler.ParseSchedule"
+
+	spec = strings.TrimSpace(spec)
+
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil {
+			return nil, fmt.Errorf("%s: parse interval %q: %w", op, spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("%s: interval %q must be at least 1s", op, spec)
+		}
+		return intervalSchedule{every: d}, nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: parse cron %q: %w", op, spec, err)
+	}
+
+	return schedule, nil
+}
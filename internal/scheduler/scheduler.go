@@ -0,0 +1,331 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/models"
+	"segmentify/internal/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// JobFunc performs one run of a job and reports how many rows it affected.
+type JobFunc func(ctx context.Context) (int64, error)
+
+type Storage interface {
+	TryAdvisoryLock(ctx context.Context, name string) (func(), bool, error)
+	CreateJobRun(ctx context.Context, name string, startedAt time.Time) (int64, error)
+	FinishJobRun(ctx context.Context, run models.JobRun) error
+	GetJobRuns(ctx context.Context, name string, limit int64) ([]models.JobRun, error)
+}
+
+// job is a registered job. spec and schedule are guarded by Scheduler.mu
+// as they change on Reschedule, which signals rescheduled.
+type job struct {
+	name        string
+	spec        string
+	schedule    Schedule
+	fn          JobFunc
+	rescheduled chan struct{}
+}
+
+type Scheduler struct {
+	log     *slog.Logger
+	storage Storage
+	now     func() time.Time
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	onRun   []func(models.JobRun)
+	running atomic.Bool
+}
+
+func New(log *slog.Logger, storage Storage) *Scheduler {
+	return &Scheduler{
+		log:     log.With(slog.String("component", "scheduler")),
+		storage: storage,
+		now:     time.Now,
+		jobs:    map[string]*job{},
+	}
+}
+
+// Register adds a named job running on the given schedule spec.
+// See ParseSchedule for the accepted formats.
+func (s *Scheduler) Register(name, spec string, fn JobFunc) error {
+	const op = "scheduler.Scheduler.Register"
+
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("%s: job %s: %w", op, name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("%s: job %s is already registered", op, name)
+	}
+
+	s.jobs[name] = &job{name: name, spec: spec, schedule: schedule, fn: fn, rescheduled: make(chan struct{}, 1)}
+
+	return nil
+}
+
+// Reschedule changes the schedule of the named job. A running scheduler
+// waits for the next activation of the new schedule; a run in progress is
+// not interrupted.
+func (s *Scheduler) Reschedule(name, spec string) error {
+	const op = "scheduler.Scheduler.Reschedule"
+
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("%s: job %s: %w", op, name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, exists := s.jobs[name]
+	if !exists {
+		return fmt.Errorf("%s: %w", op, &ErrJobNotFound{Name: name})
+	}
+	if j.spec == spec {
+		return nil
+	}
+
+	j.spec = spec
+	j.schedule = schedule
+
+	select {
+	case j.rescheduled <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// OnRun registers f to be called after every finished run, scheduled or manual.
+func (s *Scheduler) OnRun(f func(models.JobRun)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onRun = append(s.onRun, f)
+}
+
+// Run starts every registered job and blocks until ctx is done and all
+// in-progress runs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, j := range s.snapshot() {
+		j := j
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+
+	s.running.Store(true)
+	defer s.running.Store(false)
+
+	s.log.Info("scheduler started")
+
+	wg.Wait()
+
+	s.log.Info("scheduler stopped")
+}
+
+// CheckRunning fails unless Run is in progress.
+func (s *Scheduler) CheckRunning(_ context.Context) error {
+	if !s.running.Load() {
+		return errors.New("scheduler is not running")
+	}
+
+	return nil
+}
+
+// Jobs lists the registered jobs with their next activation and last run.
+func (s *Scheduler) Jobs(ctx context.Context) ([]models.Job, error) {
+	const op = "scheduler.Scheduler.Jobs"
+
+	jobs := []models.Job{}
+
+	for _, j := range s.snapshot() {
+		spec, schedule := s.scheduleOf(j)
+
+		info := models.Job{
+			Name:     j.name,
+			Schedule: spec,
+			NextRun:  schedule.Next(s.now()),
+		}
+
+		runs, err := s.storage.GetJobRuns(ctx, j.name, 1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: get job runs: %w", op, err)
+		}
+		if len(runs) > 0 {
+			info.LastRun = &runs[0]
+		}
+
+		jobs = append(jobs, info)
+	}
+
+	return jobs, nil
+}
+
+// Trigger runs the named job immediately, outside of its schedule.
+// It fails with ErrJobLocked if the job is running on any replica.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (models.JobRun, error) {
+	const op = "scheduler.Scheduler.Trigger"
+
+	s.mu.Lock()
+	j, exists := s.jobs[name]
+	s.mu.Unlock()
+
+	if !exists {
+		return models.JobRun{}, fmt.Errorf("%s: %w", op, &ErrJobNotFound{Name: name})
+	}
+
+	run, ran, err := s.runLocked(ctx, j, time.Time{})
+	if err != nil {
+		return models.JobRun{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ran {
+		return models.JobRun{}, fmt.Errorf("%s: %w", op, &ErrJobLocked{Name: name})
+	}
+
+	return run, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	log := s.log.With(slog.String("job", j.name))
+
+	for {
+		_, schedule := s.scheduleOf(j)
+		next := schedule.Next(s.now())
+
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-j.rescheduled:
+			timer.Stop()
+			log.Info("job rescheduled")
+			continue
+		case <-timer.C:
+		}
+
+		run, ran, err := s.runLocked(ctx, j, next)
+		switch {
+		case err != nil:
+			log.Error("failed to run a job", sl.Err(err))
+		case !ran:
+			log.Debug("job skipped, already run by another replica")
+		case run.Error != "":
+			log.Error("job failed", slog.String("error", run.Error))
+		default:
+			log.Info("job completed", slog.Int64("rowsAffected", run.RowsAffected))
+		}
+	}
+}
+
+// runLocked runs j while holding its advisory lock. For scheduled runs,
+// slot is the activation time: if another replica has already started a
+// run at or after it, the run is skipped. A zero slot always runs.
+func (s *Scheduler) runLocked(ctx context.Context, j *job, slot time.Time) (models.JobRun, bool, error) {
+	fail := func(msg string, err error) (models.JobRun, bool, error) {
+		return models.JobRun{}, false, fmt.Errorf("%s: %w", msg, err)
+	}
+
+	release, acquired, err := s.storage.TryAdvisoryLock(ctx, lockKey(j.name))
+	if err != nil {
+		return fail("lock job", err)
+	}
+	if !acquired {
+		return models.JobRun{}, false, nil
+	}
+	defer release()
+
+	if !slot.IsZero() {
+		runs, err := s.storage.GetJobRuns(ctx, j.name, 1)
+		if err != nil {
+			return fail("get last job run", err)
+		}
+		if len(runs) > 0 && !runs[0].StartedAt.Before(slot) {
+			return models.JobRun{}, false, nil
+		}
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "scheduler.job "+j.name)
+	defer span.End()
+
+	run := models.JobRun{JobName: j.name, StartedAt: s.now()}
+
+	run.ID, err = s.storage.CreateJobRun(ctx, j.name, run.StartedAt)
+	if err != nil {
+		return fail("create job run", err)
+	}
+
+	rowsAffected, jobErr := j.fn(ctx)
+
+	finishedAt := s.now()
+	run.FinishedAt = &finishedAt
+	run.RowsAffected = rowsAffected
+	if jobErr != nil {
+		run.Error = jobErr.Error()
+		span.SetStatus(codes.Error, run.Error)
+	}
+
+	s.mu.Lock()
+	onRun := s.onRun
+	s.mu.Unlock()
+
+	for _, f := range onRun {
+		f(run)
+	}
+
+	// Record the outcome even if ctx was cancelled mid-run
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := s.storage.FinishJobRun(finishCtx, run); err != nil {
+		return fail("finish job run", err)
+	}
+
+	return run, true, nil
+}
+
+func (s *Scheduler) snapshot() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].name < jobs[k].name })
+
+	return jobs
+}
+
+func (s *Scheduler) scheduleOf(j *job) (string, Schedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return j.spec, j.schedule
+}
+
+func lockKey(name string) string {
+	return "segmentify.scheduler." + name
+}
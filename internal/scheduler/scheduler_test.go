@@ -0,0 +1,213 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+	"segmentify/internal/models"
+	"segmentify/internal/scheduler"
+)
+
+type fakeStorage struct {
+	mu     sync.Mutex
+	locked map[string]bool
+	runs   []models.JobRun
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{locked: map[string]bool{}}
+}
+
+func (f *fakeStorage) TryAdvisoryLock(_ context.Context, name string) (func(), bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.locked[name] {
+		return nil, false, nil
+	}
+	f.locked[name] = true
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.locked, name)
+	}, true, nil
+}
+
+func (f *fakeStorage) CreateJobRun(_ context.Context, name string, startedAt time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.runs = append(f.runs, models.JobRun{ID: int64(len(f.runs) + 1), JobName: name, StartedAt: startedAt})
+
+	return int64(len(f.runs)), nil
+}
+
+func (f *fakeStorage) FinishJobRun(_ context.Context, run models.JobRun) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.runs[run.ID-1] = run
+
+	return nil
+}
+
+func (f *fakeStorage) GetJobRuns(_ context.Context, name string, limit int64) ([]models.JobRun, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	runs := []models.JobRun{}
+	for i := len(f.runs) - 1; i >= 0 && int64(len(runs)) < limit; i-- {
+		if f.runs[i].JobName == name {
+			runs = append(runs, f.runs[i])
+		}
+	}
+
+	return runs, nil
+}
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2023, 9, 12, 15, 49, 26, 0, time.UTC)
+
+	cases := []struct {
+		name    string
+		spec    string
+		next    time.Time
+		wantErr bool
+	}{
+		{
+			name: "Interval aligned to epoch",
+			spec: "@every 1h",
+			next: time.Date(2023, 9, 12, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Cron expression",
+			spec: "30 3 * * *",
+			next: time.Date(2023, 9, 13, 3, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "Cron descriptor",
+			spec: "@daily",
+			next: time.Date(2023, 9, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Invalid interval",
+			spec:    "@every often",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid cron",
+			spec:    "* * *",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			schedule, err := scheduler.ParseSchedule(tc.spec)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.next, schedule.Next(base))
+		})
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	storage := newFakeStorage()
+	jobs := scheduler.New(slogdiscard.NewDiscardLogger(), storage)
+
+	jobErr := errors.New("boom")
+	require.NoError(t, jobs.Register("ok", "@every 1h", func(context.Context) (int64, error) {
+		return 42, nil
+	}))
+	require.NoError(t, jobs.Register("failing", "@hourly", func(context.Context) (int64, error) {
+		return 0, jobErr
+	}))
+	require.Error(t, jobs.Register("ok", "@every 1h", nil), "duplicate name")
+
+	ctx := context.Background()
+
+	run, err := jobs.Trigger(ctx, "ok")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), run.RowsAffected)
+	require.NotNil(t, run.FinishedAt)
+	require.Empty(t, run.Error)
+
+	run, err = jobs.Trigger(ctx, "failing")
+	require.NoError(t, err)
+	require.Equal(t, jobErr.Error(), run.Error)
+
+	_, err = jobs.Trigger(ctx, "missing")
+	var errJobNotFound *scheduler.ErrJobNotFound
+	require.ErrorAs(t, err, &errJobNotFound)
+
+	// Another replica holds the lock
+	release, _, _ := storage.TryAdvisoryLock(ctx, "segmentify.scheduler.ok")
+	_, err = jobs.Trigger(ctx, "ok")
+	var errJobLocked *scheduler.ErrJobLocked
+	require.ErrorAs(t, err, &errJobLocked)
+	release()
+
+	list, err := jobs.Jobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "failing", list[0].Name)
+	require.Equal(t, "ok", list[1].Name)
+	require.NotNil(t, list[1].LastRun)
+	require.Equal(t, int64(42), list[1].LastRun.RowsAffected)
+}
+
+func TestReschedule(t *testing.T) {
+	jobs := scheduler.New(slogdiscard.NewDiscardLogger(), newFakeStorage())
+
+	ran := make(chan struct{}, 1)
+	require.NoError(t, jobs.Register("job", "@every 1h", func(context.Context) (int64, error) {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return 0, nil
+	}))
+
+	var errJobNotFound *scheduler.ErrJobNotFound
+	require.ErrorAs(t, jobs.Reschedule("missing", "@every 1s"), &errJobNotFound)
+	require.Error(t, jobs.Reschedule("job", "sometimes"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobs.Run(ctx)
+	}()
+
+	// The running loop switches to the new schedule without waiting an hour
+	require.NoError(t, jobs.Reschedule("job", "@every 1s"))
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run on the new schedule")
+	}
+
+	list, err := jobs.Jobs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "@every 1s", list[0].Schedule)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,15 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrInvalid lists the problems found in an archive.
+type ErrInvalid struct {
+	Problems []string
+}
+
+func (e ErrInvalid) Error() string {
+	return fmt.Sprintf("invalid snapshot: %s", strings.Join(e.Problems, "; "))
+}
@@ -0,0 +1,333 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxProblems caps the problems an ErrInvalid lists, an archive written by
+// hand can be wrong on every row.
+const maxProblems = 20
+
+// Archive is an opened and validated archive. Its rows stay in the archive
+// and are streamed table by table with NewRows, so an import does not hold
+// them in memory.
+type Archive struct {
+	Manifest Manifest
+
+	files map[string]*zip.File
+}
+
+// Open opens and validates an archive. The rows of every table must match
+// the manifest counts, keys must be unique and rows must only reference rows
+// of the same archive. Validation problems are reported as *ErrInvalid.
+// Tables are streamed to validate them, only the keys of projects, segments,
+// users and memberships are held in memory. r must stay open while the
+// archive is used.
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	const op = "snapshot.Open"
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, &ErrInvalid{Problems: []string{"not a zip archive"}})
+	}
+
+	a := &Archive{files: map[string]*zip.File{}}
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+	}
+
+	v := newValidator()
+
+	if err := readJSON(a.files[manifestFile], &a.Manifest); err != nil {
+		v.report("%s: %s", manifestFile, err)
+		return nil, fmt.Errorf("%s: %w", op, v.err())
+	}
+	if a.Manifest.Version != Version {
+		v.report("unsupported snapshot version %d, expected %d", a.Manifest.Version, Version)
+		return nil, fmt.Errorf("%s: %w", op, v.err())
+	}
+
+	readTable(v, a, TableProjects, v.project)
+	readTable(v, a, TableSegments, v.segment)
+	readTable(v, a, TableUsers, v.user)
+	readTable(v, a, TableMemberships, v.membership)
+	readTable(v, a, TableHistory, v.history)
+	if err := v.err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a, nil
+}
+
+// Read opens an archive and reads all of its rows into memory.
+func Read(r io.ReaderAt, size int64) (*Data, error) {
+	const op = "snapshot.Read"
+
+	a, err := Open(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Data{Manifest: a.Manifest}
+
+	if err := collect(a, TableProjects, &data.Projects); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := collect(a, TableSegments, &data.Segments); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := collect(a, TableUsers, &data.Users); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := collect(a, TableMemberships, &data.Memberships); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := collect(a, TableHistory, &data.History); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// Rows streams the rows of a table of an archive:
+//
+//	for rows.Next() {
+//		row := rows.Row()
+//		...
+//	}
+//	err := rows.Err()
+type Rows[T any] struct {
+	rc  io.ReadCloser
+	dec *json.Decoder
+	row T
+	err error
+}
+
+// NewRows starts reading the rows of the table. Rows must be closed.
+func NewRows[T any](a *Archive, table string) (*Rows[T], error) {
+	f := a.files[table+tableFileExtension]
+	if f == nil {
+		return nil, fmt.Errorf("snapshot.NewRows: %s: missing", table)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot.NewRows: %s: %w", table, err)
+	}
+
+	dec := json.NewDecoder(rc)
+	dec.DisallowUnknownFields()
+
+	return &Rows[T]{rc: rc, dec: dec}, nil
+}
+
+// Next decodes the next row. It returns false at the end of the table or
+// on an error, see Err.
+func (r *Rows[T]) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	var row T
+	if err := r.dec.Decode(&row); err != nil {
+		if !errors.Is(err, io.EOF) {
+			r.err = err
+		}
+		return false
+	}
+	r.row = row
+
+	return true
+}
+
+func (r *Rows[T]) Row() T {
+	return r.row
+}
+
+func (r *Rows[T]) Err() error {
+	return r.err
+}
+
+func (r *Rows[T]) Close() error {
+	return r.rc.Close()
+}
+
+// collect reads all rows of the table into rows.
+func collect[T any](a *Archive, table string, rows *[]T) error {
+	r, err := NewRows[T](a, table)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	*rows = []T{}
+	for r.Next() {
+		*rows = append(*rows, r.Row())
+	}
+
+	return r.Err()
+}
+
+func readJSON(f *zip.File, v any) error {
+	if f == nil {
+		return errors.New("missing")
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dec := json.NewDecoder(rc)
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(v)
+}
+
+// readTable streams the rows of the table through check and compares their
+// number with the manifest. Once a table failed to decode, later tables are
+// only counted, their references would be reported wrongly.
+func readTable[T any](v *validator, a *Archive, table string, check func(T)) {
+	name := table + tableFileExtension
+
+	rows, err := NewRows[T](a, table)
+	if err != nil {
+		v.report("%s: missing", name)
+		v.broken = true
+		return
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		count++
+		if !v.broken {
+			check(rows.Row())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		v.report("%s: row %d: %s", name, count+1, err)
+		v.broken = true
+	}
+
+	if want, ok := a.Manifest.Counts[table]; !ok {
+		v.report("%s: not counted in the manifest", table)
+	} else if want != count {
+		v.report("%s: manifest counts %d rows, archive has %d", table, want, count)
+	}
+}
+
+func (v *validator) project(p Project) {
+	switch {
+	case p.Slug == "":
+		v.report("%s: empty slug", TableProjects)
+	case v.projects[p.Slug]:
+		v.report("%s: duplicate project %s", TableProjects, p.Slug)
+	}
+	v.projects[p.Slug] = true
+}
+
+func (v *validator) segment(s Segment) {
+	key := [2]string{s.Project, s.Slug}
+	switch {
+	case s.Slug == "":
+		v.report("%s: empty slug in project %s", TableSegments, s.Project)
+	case !v.projects[s.Project]:
+		v.report("%s: segment %s/%s references unknown project", TableSegments, s.Project, s.Slug)
+	case s.Percent < 0 || s.Percent > 100:
+		v.report("%s: segment %s/%s has percent %d out of 0..100", TableSegments, s.Project, s.Slug, s.Percent)
+	case v.segments[key]:
+		v.report("%s: duplicate segment %s/%s", TableSegments, s.Project, s.Slug)
+	}
+	v.segments[key] = true
+}
+
+func (v *validator) user(u User) {
+	switch _, exists := v.users[u.ID]; {
+	case u.ID <= 0:
+		v.report("%s: invalid id %d", TableUsers, u.ID)
+	case !v.projects[u.Project]:
+		v.report("%s: user %d references unknown project %s", TableUsers, u.ID, u.Project)
+	case exists:
+		v.report("%s: duplicate user %d", TableUsers, u.ID)
+	}
+	v.users[u.ID] = u.Project
+}
+
+func (v *validator) membership(m Membership) {
+	key := strconv.FormatInt(m.UserID, 10) + "/" + m.Segment
+	if v.references(TableMemberships, m.Project, m.UserID, m.Segment) && v.memberships[key] {
+		v.report("%s: duplicate membership of user %d in %s", TableMemberships, m.UserID, m.Segment)
+	}
+	v.memberships[key] = true
+}
+
+func (v *validator) history(h HistoryEntry) {
+	if v.references(TableHistory, h.Project, h.UserID, h.Segment) && h.Operation != "add" && h.Operation != "remove" {
+		v.report("%s: unknown operation %q", TableHistory, h.Operation)
+	}
+}
+
+// references checks that a row of the table references a user and a segment
+// of the same project.
+func (v *validator) references(table string, project string, userID int64, segment string) bool {
+	if p, ok := v.users[userID]; !ok || p != project {
+		v.report("%s: user %d of project %s not found", table, userID, project)
+		return false
+	}
+	if !v.segments[[2]string{project, segment}] {
+		v.report("%s: segment %s/%s not found", table, project, segment)
+		return false
+	}
+	return true
+}
+
+// validator collects the problems of an archive and the keys rows are
+// checked against.
+type validator struct {
+	problems []string
+	dropped  int
+	// broken is set once a table could not be read.
+	broken bool
+
+	projects    map[string]bool
+	segments    map[[2]string]bool
+	users       map[int64]string
+	memberships map[string]bool
+}
+
+func newValidator() *validator {
+	return &validator{
+		projects:    map[string]bool{},
+		segments:    map[[2]string]bool{},
+		users:       map[int64]string{},
+		memberships: map[string]bool{},
+	}
+}
+
+func (v *validator) report(format string, args ...any) {
+	if len(v.problems) >= maxProblems {
+		v.dropped++
+		return
+	}
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	problems := v.problems
+	if v.dropped > 0 {
+		problems = append(problems, fmt.Sprintf("and %d more", v.dropped))
+	}
+
+	return &ErrInvalid{Problems: problems}
+}
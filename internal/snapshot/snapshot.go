@@ -0,0 +1,136 @@
+// Package snapshot defines the archive segments, users, memberships and
+// history are exported to and imported from. An archive is a zip file with
+// an NDJSON file per table and a manifest holding the format and schema
+// versions and the number of rows of every table.
+package snapshot
+
+import "time"
+
+// Version is the version of the archive format. Archives of other versions
+// are rejected on import.
+const Version = 1
+
+const (
+	TableProjects    = "projects"
+	TableSegments    = "segments"
+	TableUsers       = "users"
+	TableMemberships = "users_segments"
+	TableHistory     = "users_segments_history"
+)
+
+const (
+	manifestFile       = "manifest.json"
+	tableFileExtension = ".ndjson"
+)
+
+// Tables lists the tables of an archive in the order they are imported.
+var Tables = []string{TableProjects, TableSegments, TableUsers, TableMemberships, TableHistory}
+
+// Mode is how an import treats data that already exists.
+type Mode string
+
+const (
+	// ModeUpsert adds the rows of the archive and overwrites rows with the
+	// same keys. Other rows are left as they are.
+	ModeUpsert Mode = "upsert"
+	// ModeReplace deletes the segments and users of the projects in the
+	// archive before adding its rows. Other projects are left as they are.
+	ModeReplace Mode = "replace"
+)
+
+func ParseMode(s string) (Mode, bool) {
+	switch mode := Mode(s); mode {
+	case ModeUpsert, ModeReplace:
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+type Manifest struct {
+	Version       int              `json:"version"`
+	SchemaVersion int              `json:"schema_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Counts        map[string]int64 `json:"counts"`
+}
+
+type Project struct {
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Segment struct {
+	Project string `json:"project"`
+	Slug    string `json:"slug"`
+	Percent int64  `json:"percent"`
+}
+
+type User struct {
+	Project string `json:"project"`
+	ID      int64  `json:"id"`
+}
+
+type Membership struct {
+	Project  string     `json:"project"`
+	UserID   int64      `json:"user_id"`
+	Segment  string     `json:"segment"`
+	ExpireAt *time.Time `json:"expire_at"`
+}
+
+type HistoryEntry struct {
+	Project   string    `json:"project"`
+	UserID    int64     `json:"user_id"`
+	Segment   string    `json:"segment"`
+	Operation string    `json:"operation"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Data is a read archive.
+type Data struct {
+	Manifest    Manifest
+	Projects    []Project
+	Segments    []Segment
+	Users       []User
+	Memberships []Membership
+	History     []HistoryEntry
+}
+
+// Conflict is a row of an archive that differs from the stored row with the
+// same key. Resolution tells whether the stored row was overwritten or the
+// archive row, along with the rows referencing it, was skipped.
+type Conflict struct {
+	Table      string `json:"table"`
+	Key        string `json:"key"`
+	Resolution string `json:"resolution"`
+	Detail     string `json:"detail"`
+}
+
+const (
+	ResolutionOverwritten = "overwritten"
+	ResolutionSkipped     = "skipped"
+)
+
+type TableResult struct {
+	Inserted int64 `json:"inserted"`
+	Updated  int64 `json:"updated"`
+	Deleted  int64 `json:"deleted"`
+}
+
+type Result struct {
+	Mode      Mode                   `json:"mode"`
+	Tables    map[string]TableResult `json:"tables"`
+	Conflicts []Conflict             `json:"conflicts"`
+}
+
+// Skipped reports whether rows of the archive were not imported because of
+// conflicts.
+func (r Result) Skipped() bool {
+	for _, c := range r.Conflicts {
+		if c.Resolution == ResolutionSkipped {
+			return true
+		}
+	}
+
+	return false
+}
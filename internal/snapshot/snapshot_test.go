@@ -0,0 +1,182 @@
+package snapshot_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/snapshot"
+)
+
+var createdAt = time.Date(2023, 9, 12, 15, 49, 26, 0, time.UTC)
+
+// writeArchive writes the data as an archive, skipping the tables in skip.
+func writeArchive(t *testing.T, data snapshot.Data, skip ...string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	w := snapshot.NewWriter(buf, 1)
+
+	write := func(table string, rows ...any) {
+		for _, s := range skip {
+			if s == table {
+				return
+			}
+		}
+		require.NoError(t, w.Table(table))
+		for _, row := range rows {
+			require.NoError(t, w.Write(row))
+		}
+	}
+
+	write(snapshot.TableProjects, toAny(data.Projects)...)
+	write(snapshot.TableSegments, toAny(data.Segments)...)
+	write(snapshot.TableUsers, toAny(data.Users)...)
+	write(snapshot.TableMemberships, toAny(data.Memberships)...)
+	write(snapshot.TableHistory, toAny(data.History)...)
+
+	manifest, err := w.Close()
+	require.NoError(t, err)
+	require.Equal(t, snapshot.Version, manifest.Version)
+	require.Equal(t, 1, manifest.SchemaVersion)
+
+	return buf.Bytes()
+}
+
+func toAny[T any](rows []T) []any {
+	out := make([]any, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row)
+	}
+	return out
+}
+
+func validData() snapshot.Data {
+	expireAt := createdAt.Add(24 * time.Hour)
+
+	return snapshot.Data{
+		Projects:    []snapshot.Project{{Slug: "default", Name: "Default project", CreatedAt: createdAt}},
+		Segments:    []snapshot.Segment{{Project: "default", Slug: "A", Percent: 10}, {Project: "default", Slug: "B"}},
+		Users:       []snapshot.User{{Project: "default", ID: 1}, {Project: "default", ID: 2}},
+		Memberships: []snapshot.Membership{{Project: "default", UserID: 1, Segment: "A", ExpireAt: &expireAt}, {Project: "default", UserID: 2, Segment: "B"}},
+		History: []snapshot.HistoryEntry{
+			{Project: "default", UserID: 1, Segment: "A", Operation: "add", CreatedAt: createdAt},
+			{Project: "default", UserID: 2, Segment: "A", Operation: "remove", CreatedAt: createdAt},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := validData()
+	archive := writeArchive(t, want)
+
+	got, err := snapshot.Read(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]int64{
+		snapshot.TableProjects:    1,
+		snapshot.TableSegments:    2,
+		snapshot.TableUsers:       2,
+		snapshot.TableMemberships: 2,
+		snapshot.TableHistory:     2,
+	}, got.Manifest.Counts)
+	require.Equal(t, want.Projects, got.Projects)
+	require.Equal(t, want.Segments, got.Segments)
+	require.Equal(t, want.Users, got.Users)
+	require.Equal(t, want.History, got.History)
+	require.Len(t, got.Memberships, 2)
+	require.True(t, want.Memberships[0].ExpireAt.Equal(*got.Memberships[0].ExpireAt))
+	require.Nil(t, got.Memberships[1].ExpireAt)
+}
+
+func TestReadInvalid(t *testing.T) {
+	cases := []struct {
+		name    string
+		archive func(t *testing.T) []byte
+		problem string
+	}{
+		{
+			name:    "Not a zip",
+			archive: func(*testing.T) []byte { return []byte("hello") },
+			problem: "not a zip archive",
+		},
+		{
+			name: "Missing manifest",
+			archive: func(t *testing.T) []byte {
+				buf := new(bytes.Buffer)
+				zw := zip.NewWriter(buf)
+				require.NoError(t, zw.Close())
+				return buf.Bytes()
+			},
+			problem: "manifest.json: missing",
+		},
+		{
+			name: "Missing table",
+			archive: func(t *testing.T) []byte {
+				return writeArchive(t, validData(), snapshot.TableHistory)
+			},
+			problem: "users_segments_history.ndjson: missing",
+		},
+		{
+			name: "Unknown project",
+			archive: func(t *testing.T) []byte {
+				data := validData()
+				data.Segments = append(data.Segments, snapshot.Segment{Project: "other", Slug: "C"})
+				return writeArchive(t, data)
+			},
+			problem: "segments: segment other/C references unknown project",
+		},
+		{
+			name: "Duplicate user",
+			archive: func(t *testing.T) []byte {
+				data := validData()
+				data.Users = append(data.Users, snapshot.User{Project: "default", ID: 1})
+				return writeArchive(t, data)
+			},
+			problem: "users: duplicate user 1",
+		},
+		{
+			name: "Membership of unknown segment",
+			archive: func(t *testing.T) []byte {
+				data := validData()
+				data.Memberships = append(data.Memberships, snapshot.Membership{Project: "default", UserID: 1, Segment: "C"})
+				return writeArchive(t, data)
+			},
+			problem: "users_segments: segment default/C not found",
+		},
+		{
+			name: "Unknown operation",
+			archive: func(t *testing.T) []byte {
+				data := validData()
+				data.History[0].Operation = "move"
+				return writeArchive(t, data)
+			},
+			problem: `users_segments_history: unknown operation "move"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := tc.archive(t)
+
+			_, err := snapshot.Read(bytes.NewReader(archive), int64(len(archive)))
+
+			var errInvalid *snapshot.ErrInvalid
+			require.True(t, errors.As(err, &errInvalid), "got %v", err)
+			require.Contains(t, errInvalid.Problems, tc.problem)
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	mode, ok := snapshot.ParseMode("replace")
+	require.True(t, ok)
+	require.Equal(t, snapshot.ModeReplace, mode)
+
+	_, ok = snapshot.ParseMode("merge")
+	require.False(t, ok)
+}
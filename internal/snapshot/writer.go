@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Writer streams tables into an archive. Rows of a table are written after
+// starting it with Table, tables one after another.
+type Writer struct {
+	zw            *zip.Writer
+	enc           *json.Encoder
+	table         string
+	schemaVersion int
+	counts        map[string]int64
+}
+
+func NewWriter(w io.Writer, schemaVersion int) *Writer {
+	return &Writer{
+		zw:            zip.NewWriter(w),
+		schemaVersion: schemaVersion,
+		counts:        map[string]int64{},
+	}
+}
+
+// Table starts writing the rows of the named table.
+func (w *Writer) Table(name string) error {
+	f, err := w.zw.Create(name + tableFileExtension)
+	if err != nil {
+		return fmt.Errorf("snapshot.Writer.Table: %w", err)
+	}
+
+	w.enc = json.NewEncoder(f)
+	w.table = name
+	w.counts[name] = 0
+
+	return nil
+}
+
+// Write adds a row to the current table.
+func (w *Writer) Write(row any) error {
+	if w.enc == nil {
+		return fmt.Errorf("snapshot.Writer.Write: no table started")
+	}
+
+	if err := w.enc.Encode(row); err != nil {
+		return fmt.Errorf("snapshot.Writer.Write: %w", err)
+	}
+	w.counts[w.table]++
+
+	return nil
+}
+
+// Close writes the manifest and finishes the archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() (Manifest, error) {
+	const op = "snapshot.Writer.Close"
+
+	manifest := Manifest{
+		Version:       Version,
+		SchemaVersion: w.schemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Counts:        w.counts,
+	}
+
+	f, err := w.zw.Create(manifestFile)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.zw.Close(); err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return manifest, nil
+}
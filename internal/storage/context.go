@@ -0,0 +1,19 @@
+package storage
+
+import "context"
+
+type primaryKey struct{}
+
+// WithPrimary sends the reads made with the context to the primary database.
+// It gives read-your-writes to clients that just changed data, as read
+// replicas may lag behind.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryRequired reports whether reads made with the context must go to the
+// primary database.
+func PrimaryRequired(ctx context.Context) bool {
+	required, _ := ctx.Value(primaryKey{}).(bool)
+	return required
+}
@@ -28,6 +28,14 @@ func (e ErrUserNotFound) Error() string {
 	return fmt.Sprintf("user with id=%d not found", e.ID)
 }
 
+type ErrUserExists struct {
+	ID int64
+}
+
+func (e ErrUserExists) Error() string {
+	return fmt.Sprintf("user with id=%d exists in another project", e.ID)
+}
+
 type ErrUserSegmentNotFound struct {
 	Slug string
 }
@@ -43,3 +51,46 @@ type ErrUserSegmentExists struct {
 func (e ErrUserSegmentExists) Error() string {
 	return fmt.Sprintf("user segment with slug=%s exists", e.Slug)
 }
+
+type ErrAPIKeyNotFound struct {
+	ID int64
+}
+
+func (e ErrAPIKeyNotFound) Error() string {
+	if e.ID == 0 {
+		return "api key not found"
+	}
+	return fmt.Sprintf("api key with id=%d not found", e.ID)
+}
+
+type ErrProjectNotFound struct {
+	Slug string
+}
+
+func (e ErrProjectNotFound) Error() string {
+	return fmt.Sprintf("project with slug=%s not found", e.Slug)
+}
+
+type ErrProjectExists struct {
+	Slug string
+}
+
+func (e ErrProjectExists) Error() string {
+	return fmt.Sprintf("project with slug=%s exists", e.Slug)
+}
+
+type ErrWebhookNotFound struct {
+	ID int64
+}
+
+func (e ErrWebhookNotFound) Error() string {
+	return fmt.Sprintf("webhook with id=%d not found", e.ID)
+}
+
+type ErrWebhookDeliveryNotFound struct {
+	ID int64
+}
+
+func (e ErrWebhookDeliveryNotFound) Error() string {
+	return fmt.Sprintf("webhook delivery with id=%d not found", e.ID)
+}
@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CreateAPIKey")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.APIKey, error) {
+		return models.APIKey{}, fmt.Errorf("storage.postgres.CreateAPIKey: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.QueryRow(ctx, `
+		INSERT INTO api_keys(name, prefix, key_hash, role, project_slug)
+		VALUES($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id, created_at
+	`, key.Name, key.Prefix, keyHash, key.Role, key.Project).Scan(&key.ID, &key.CreatedAt); err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return fail("insert api key with returning", &storage.ErrProjectNotFound{Slug: key.Project})
+		}
+		return fail("insert api key with returning", err)
+	}
+
+	if err = writeAudit(ctx, tx, key.Project, audit.ActionCreate, audit.EntityAPIKey, strconv.FormatInt(key.ID, 10), nil, key); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return key, nil
+}
+
+// EnsureAPIKey creates the key unless one with the same hash already exists.
+// It is used to seed the bootstrap admin key from configuration.
+func (s *Storage) EnsureAPIKey(ctx context.Context, key models.APIKey, keyHash string) error {
+	ctx, span := startSpan(ctx, "storage.postgres.EnsureAPIKey")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.EnsureAPIKey: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.QueryRow(ctx, `
+		INSERT INTO api_keys(name, prefix, key_hash, role)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (key_hash) DO NOTHING
+		RETURNING id, created_at
+	`, key.Name, key.Prefix, keyHash, key.Role).Scan(&key.ID, &key.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fail("insert api key", err)
+	}
+
+	if err = writeAudit(ctx, tx, "", audit.ActionCreate, audit.EntityAPIKey, strconv.FormatInt(key.ID, 10), nil, key); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash returns the active key with the given hash.
+// Revoked keys are reported as not found.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetAPIKeyByHash")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.APIKey, error) {
+		return models.APIKey{}, fmt.Errorf("storage.postgres.GetAPIKeyByHash: %s: %w", msg, err)
+	}
+
+	var key models.APIKey
+
+	if err := s.pool.QueryRow(ctx, `
+		SELECT id, name, prefix, role, COALESCE(project_slug, ''), created_at
+		FROM api_keys
+		WHERE key_hash = $1
+		AND revoked_at IS NULL
+	`, keyHash).Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.Project, &key.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("query api key", &storage.ErrAPIKeyNotFound{})
+		}
+		return fail("query api key", err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ListAPIKeys")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.APIKey, error) {
+		return []models.APIKey{}, fmt.Errorf("storage.postgres.ListAPIKeys: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, prefix, role, COALESCE(project_slug, ''), created_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return fail("query api keys", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.Project, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return fail("scan api keys", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate api keys", err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "storage.postgres.RevokeAPIKey")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.RevokeAPIKey: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var key models.APIKey
+
+	if err = tx.QueryRow(ctx, `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1
+		AND revoked_at IS NULL
+		RETURNING id, name, prefix, role, COALESCE(project_slug, ''), created_at, revoked_at
+	`, id).Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.Project, &key.CreatedAt, &key.RevokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("revoke api key", &storage.ErrAPIKeyNotFound{ID: id})
+		}
+		return fail("revoke api key", err)
+	}
+
+	before := key
+	before.RevokedAt = nil
+
+	if err = writeAudit(ctx, tx, key.Project, audit.ActionRevoke, audit.EntityAPIKey, strconv.FormatInt(id, 10), before, key); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// writeAudit appends an entry for a change made by the actor of ctx. It is
+// called with the transaction of the change, so the entry is written if and
+// only if the change is committed. before and after are stored as JSON, nil
+// is stored as NULL.
+func writeAudit(
+	ctx context.Context,
+	db execer,
+	project, action, entity, entityID string,
+	before, after any,
+) error {
+	actor := audit.ActorFromContext(ctx)
+
+	beforeJSON, err := marshalAuditState(before)
+	if err != nil {
+		return fmt.Errorf("marshal before: %w", err)
+	}
+	afterJSON, err := marshalAuditState(after)
+	if err != nil {
+		return fmt.Errorf("marshal after: %w", err)
+	}
+
+	if _, err := db.Exec(ctx, `
+		INSERT INTO audit_log(project_slug, actor, api_key_id, action, entity, entity_id, before, after, request_id)
+		VALUES(NULLIF($1, ''), $2, NULLIF($3, 0), $4, $5, $6, $7, $8, $9)
+	`, project, actor.Name, actor.APIKeyID, action, entity, entityID, beforeJSON, afterJSON, actor.RequestID); err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func marshalAuditState(state any) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// WriteAudit appends an entry for a change made outside of the database,
+// such as a config reload.
+func (s *Storage) WriteAudit(ctx context.Context, action, entity, entityID string, before, after any) error {
+	ctx, span := startSpan(ctx, "storage.postgres.WriteAudit")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := writeAudit(ctx, s.pool, "", action, entity, entityID, before, after); err != nil {
+		return fmt.Errorf("storage.postgres.WriteAudit: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetAuditLog")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.AuditEntry, error) {
+		return []models.AuditEntry{}, fmt.Errorf("storage.postgres.GetAuditLog: %s: %w", msg, err)
+	}
+
+	var conds []string
+	var args []any
+
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.Project != "" {
+		where("project_slug = ?", filter.Project)
+	}
+	if filter.Actor != "" {
+		where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		where("action = ?", filter.Action)
+	}
+	if filter.Entity != "" {
+		where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		where("entity_id = ?", filter.EntityID)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < ?", filter.To)
+	}
+	if filter.Cursor > 0 {
+		where("id < ?", filter.Cursor)
+	}
+
+	query := `
+		SELECT id, COALESCE(project_slug, ''), actor, api_key_id, action, entity, entity_id, before, after, request_id, created_at
+		FROM audit_log
+	`
+	if len(conds) > 0 {
+		query += "WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return fail("query audit log", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(
+			&e.ID, &e.Project, &e.Actor, &e.APIKeyID, &e.Action, &e.Entity,
+			&e.EntityID, &e.Before, &e.After, &e.RequestID, &e.CreatedAt,
+		); err != nil {
+			return fail("scan audit log", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate audit log", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"segmentify/internal/models"
+)
+
+// eventsChannel is notified by the outbox_notify trigger.
+const eventsChannel = "outbox"
+
+// sequenceLock serializes numbering of events.
+const sequenceLock = "outbox_seq"
+
+// ListenEvents calls notify whenever new events are committed to the outbox.
+// It holds a dedicated connection and blocks until ctx is done or the
+// connection fails.
+func (s *Storage) ListenEvents(ctx context.Context, notify func()) error {
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.ListenEvents: %s: %w", msg, err)
+	}
+
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fail("acquire connection", err)
+	}
+	// The connection keeps listening until it is closed, so take it out of
+	// the pool instead of returning it
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		return fail("listen", err)
+	}
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return fail("wait for notification", err)
+		}
+		notify()
+	}
+}
+
+// sequenceEvents numbers the committed events that have no number yet. The
+// numbering transactions hold a lock until they commit, so numbers become
+// visible in order: whoever sees a number sees every lower one, and events
+// committed later get higher numbers.
+func (s *Storage) sequenceEvents(ctx context.Context) error {
+	var pending bool
+
+	if err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM outbox WHERE seq IS NULL)
+	`).Scan(&pending); err != nil {
+		return fmt.Errorf("query unsequenced events: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(hashtext($1))
+	`, sequenceLock); err != nil {
+		return fmt.Errorf("lock sequence: %w", err)
+	}
+
+	// Taken after the lock, the snapshot sees the numbers of the previous
+	// holder
+	if _, err = tx.Exec(ctx, `
+		UPDATE outbox
+		SET seq = numbered.seq
+		FROM (
+			SELECT id, nextval('outbox_seq') AS seq
+			FROM (
+				SELECT id
+				FROM outbox
+				WHERE seq IS NULL
+				ORDER BY id
+			) AS unsequenced
+		) AS numbered
+		WHERE outbox.id = numbered.id
+	`); err != nil {
+		return fmt.Errorf("number events: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetEventsAfter returns events committed after the event with the given ID,
+// oldest first. IDs are assigned in commit order, so a stream resuming after
+// an ID misses none of the events committed concurrently. An empty project
+// selects events of all projects.
+func (s *Storage) GetEventsAfter(ctx context.Context, project string, afterID, limit int64) ([]models.OutboxEvent, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetEventsAfter")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.OutboxEvent, error) {
+		return []models.OutboxEvent{}, fmt.Errorf("storage.postgres.GetEventsAfter: %s: %w", msg, err)
+	}
+
+	if err := s.sequenceEvents(ctx); err != nil {
+		return fail("sequence events", err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT seq, event_id::text, event_type, project_slug, payload, created_at
+		FROM outbox
+		WHERE seq > $1
+		AND ($2 = '' OR project_slug = $2)
+		ORDER BY seq
+		LIMIT $3
+	`, afterID, project, limit)
+	if err != nil {
+		return fail("query outbox", err)
+	}
+	defer rows.Close()
+
+	events := []models.OutboxEvent{}
+
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.EventID, &e.Type, &e.Project, &e.Payload, &e.CreatedAt); err != nil {
+			return fail("scan outbox", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate outbox", err)
+	}
+
+	return events, nil
+}
+
+// GetLastEventID returns the ID of the latest event, or 0 if there are none.
+func (s *Storage) GetLastEventID(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetLastEventID")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (int64, error) {
+		return 0, fmt.Errorf("storage.postgres.GetLastEventID: %s: %w", msg, err)
+	}
+
+	if err := s.sequenceEvents(ctx); err != nil {
+		return fail("sequence events", err)
+	}
+
+	var id int64
+
+	if err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(MAX(seq), 0)
+		FROM outbox
+	`).Scan(&id); err != nil {
+		return fail("query outbox", err)
+	}
+
+	return id, nil
+}
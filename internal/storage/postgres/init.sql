@@ -1,22 +1,230 @@
+CREATE TABLE IF NOT EXISTS projects (
+    slug TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO projects(slug, name)
+VALUES('default', 'Default project')
+ON CONFLICT (slug) DO NOTHING;
+
 CREATE TABLE IF NOT EXISTS users (
-    id BIGSERIAL PRIMARY KEY
+    id BIGSERIAL PRIMARY KEY,
+    project_slug TEXT NOT NULL REFERENCES projects(slug) ON DELETE CASCADE,
+    UNIQUE (id, project_slug)
 );
 
 CREATE TABLE IF NOT EXISTS segments (
-    slug TEXT PRIMARY KEY,
-    percent SMALLINT NOT NULL CHECK (percent >= 0 AND percent <= 100)
+    project_slug TEXT NOT NULL REFERENCES projects(slug) ON DELETE CASCADE,
+    slug TEXT NOT NULL,
+    percent SMALLINT NOT NULL CHECK (percent >= 0 AND percent <= 100),
+    PRIMARY KEY (project_slug, slug)
 );
 
 CREATE TABLE IF NOT EXISTS users_segments (
-    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
-    segment_slug TEXT REFERENCES segments(slug) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    project_slug TEXT NOT NULL,
+    segment_slug TEXT NOT NULL,
     expire_at TIMESTAMP,
-    PRIMARY KEY (user_id, segment_slug)
+    PRIMARY KEY (user_id, segment_slug),
+    FOREIGN KEY (user_id, project_slug) REFERENCES users(id, project_slug) ON DELETE CASCADE,
+    FOREIGN KEY (project_slug, segment_slug) REFERENCES segments(project_slug, slug) ON DELETE CASCADE
 );
 
 CREATE TABLE IF NOT EXISTS users_segments_history (
-    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
-    segment_slug TEXT REFERENCES segments(slug) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    project_slug TEXT NOT NULL,
+    segment_slug TEXT NOT NULL,
     operation TEXT NOT NULL CHECK (operation IN ('add', 'remove')),
-    created_at TIMESTAMP DEFAULT NOW()
+    created_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (user_id, project_slug) REFERENCES users(id, project_slug) ON DELETE CASCADE,
+    FOREIGN KEY (project_slug, segment_slug) REFERENCES segments(project_slug, slug) ON DELETE CASCADE
+);
+
+-- Move databases created before projects existed into the default project.
+-- Foreign keys become composite, so a user can only be added to segments of
+-- its own project.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM information_schema.columns
+        WHERE table_schema = current_schema()
+        AND table_name = 'segments'
+        AND column_name = 'project_slug'
+    ) THEN
+        ALTER TABLE users_segments DROP CONSTRAINT IF EXISTS users_segments_user_id_fkey;
+        ALTER TABLE users_segments DROP CONSTRAINT IF EXISTS users_segments_segment_slug_fkey;
+        ALTER TABLE users_segments_history DROP CONSTRAINT IF EXISTS users_segments_history_user_id_fkey;
+        ALTER TABLE users_segments_history DROP CONSTRAINT IF EXISTS users_segments_history_segment_slug_fkey;
+
+        ALTER TABLE users ADD COLUMN project_slug TEXT NOT NULL DEFAULT 'default'
+            REFERENCES projects(slug) ON DELETE CASCADE;
+        ALTER TABLE users ALTER COLUMN project_slug DROP DEFAULT;
+        ALTER TABLE users ADD UNIQUE (id, project_slug);
+
+        ALTER TABLE segments ADD COLUMN project_slug TEXT NOT NULL DEFAULT 'default'
+            REFERENCES projects(slug) ON DELETE CASCADE;
+        ALTER TABLE segments ALTER COLUMN project_slug DROP DEFAULT;
+        ALTER TABLE segments DROP CONSTRAINT segments_pkey;
+        ALTER TABLE segments ADD PRIMARY KEY (project_slug, slug);
+
+        ALTER TABLE users_segments ADD COLUMN project_slug TEXT NOT NULL DEFAULT 'default';
+        ALTER TABLE users_segments ALTER COLUMN project_slug DROP DEFAULT;
+        ALTER TABLE users_segments
+            ADD FOREIGN KEY (user_id, project_slug) REFERENCES users(id, project_slug) ON DELETE CASCADE,
+            ADD FOREIGN KEY (project_slug, segment_slug) REFERENCES segments(project_slug, slug) ON DELETE CASCADE;
+
+        DELETE FROM users_segments_history WHERE user_id IS NULL OR segment_slug IS NULL;
+        ALTER TABLE users_segments_history ADD COLUMN project_slug TEXT NOT NULL DEFAULT 'default';
+        ALTER TABLE users_segments_history ALTER COLUMN project_slug DROP DEFAULT;
+        ALTER TABLE users_segments_history
+            ALTER COLUMN user_id SET NOT NULL,
+            ALTER COLUMN segment_slug SET NOT NULL,
+            ADD FOREIGN KEY (user_id, project_slug) REFERENCES users(id, project_slug) ON DELETE CASCADE,
+            ADD FOREIGN KEY (project_slug, segment_slug) REFERENCES segments(project_slug, slug) ON DELETE CASCADE;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job_name TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    rows_affected BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
 );
+
+CREATE INDEX IF NOT EXISTS job_runs_job_name_started_at_idx ON job_runs (job_name, started_at DESC);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('reader', 'assigner', 'admin')),
+    project_slug TEXT REFERENCES projects(slug) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+-- audit_log is append-only: the trigger below rejects updates and deletes.
+-- project_slug has no foreign key so entries outlive deleted projects.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    project_slug TEXT,
+    actor TEXT NOT NULL,
+    api_key_id BIGINT,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, entity_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- An empty segments or events array subscribes to all of them.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    project_slug TEXT NOT NULL REFERENCES projects(slug) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    segments TEXT[] NOT NULL DEFAULT '{}',
+    events TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempted_at TIMESTAMP NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    response_status INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_id_idx ON webhook_delivery_attempts (delivery_id);
+
+-- Change events written in the same transaction as the changes and published
+-- by the outbox relay in id order. event_id is kept across republishing, so
+-- consumers can drop duplicates.
+-- ids are taken when a transaction inserts, and transactions commit in any
+-- order, so event streams resume after seq instead: it is assigned from
+-- outbox_seq once the event is committed, in commit order.
+CREATE SEQUENCE IF NOT EXISTS outbox_seq;
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type TEXT NOT NULL,
+    project_slug TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP,
+    seq BIGINT UNIQUE
+);
+
+-- Events written before seq existed keep their ids, so streams resume where
+-- they were.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM information_schema.columns
+        WHERE table_schema = current_schema()
+        AND table_name = 'outbox'
+        AND column_name = 'seq'
+    ) THEN
+        ALTER TABLE outbox ADD COLUMN seq BIGINT UNIQUE;
+        UPDATE outbox SET seq = id;
+        PERFORM setval('outbox_seq', COALESCE((SELECT MAX(id) FROM outbox), 0) + 1, false);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_unsequenced_idx ON outbox (id) WHERE seq IS NULL;
+
+-- Wakes up event streams of every replica once per statement writing events.
+-- The notification is sent on commit, so listeners only see committed events.
+CREATE OR REPLACE FUNCTION outbox_notify() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('outbox', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+CREATE TRIGGER outbox_notify
+    AFTER INSERT ON outbox
+    FOR EACH STATEMENT EXECUTE FUNCTION outbox_notify();
@@ -3,20 +3,216 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"segmentify/internal/models"
 )
 
 func (s *Storage) DeleteExpiredUsersSegments(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.DeleteExpiredUsersSegments")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) (int64, error) {
 		return 0, fmt.Errorf("storage.postgres.DeleteExpiredUsersSegments: %s: %w", msg, err)
 	}
 
-	res, err := s.pool.Exec(ctx, `
-		DELETE FROM users_segments
-		WHERE expire_at < NOW()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The removal is dated when the membership expired rather than when
+	// the job ran, so the history matches the memberships day by day
+	rows, err := tx.Query(ctx, `
+		WITH expired AS (
+			DELETE FROM users_segments
+			WHERE expire_at < NOW()
+			RETURNING project_slug, user_id, segment_slug, expire_at
+		), history AS (
+			INSERT INTO users_segments_history(user_id, project_slug, segment_slug, operation, created_at)
+			SELECT user_id, project_slug, segment_slug, 'remove', expire_at
+			FROM expired
+		)
+		SELECT project_slug, user_id, segment_slug
+		FROM expired
 	`)
 	if err != nil {
 		return fail("delete users segments", err)
 	}
 
-	return res.RowsAffected(), nil
+	var expired membershipChanges
+
+	for rows.Next() {
+		var project, segment string
+		var userID int64
+		if err := rows.Scan(&project, &userID, &segment); err != nil {
+			rows.Close()
+			return fail("scan users segments", err)
+		}
+		expired.add(project, userID, segment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fail("iterate users segments", err)
+	}
+
+	if err = writeMembershipEvents(ctx, tx, models.EventUserRemoved, models.ReasonExpired, expired); err != nil {
+		return fail("write membership events", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	for i, userID := range expired.users {
+		s.userSegments.InvalidateUser(ctx, expired.projects[i], userID)
+	}
+
+	return int64(len(expired.users)), nil
+}
+
+// TryAdvisoryLock tries to take a session-level advisory lock keyed by name.
+// The lock is held on a dedicated connection until release is called, so only
+// one replica sharing the database can hold it at a time.
+func (s *Storage) TryAdvisoryLock(ctx context.Context, name string) (func(), bool, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.TryAdvisoryLock")
+	defer span.End()
+
+	fail := func(msg string, err error) (func(), bool, error) {
+		return nil, false, fmt.Errorf("storage.postgres.TryAdvisoryLock: %s: %w", msg, err)
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fail("acquire connection", err)
+	}
+
+	var acquired bool
+
+	if err := conn.QueryRow(ctx, `
+		SELECT pg_try_advisory_lock(hashtext($1))
+	`, name).Scan(&acquired); err != nil {
+		conn.Release()
+		return fail("try advisory lock", err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	release := func() {
+		// The caller's context may already be cancelled, but the lock must
+		// not outlive the job, so unlock with a fresh one.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := conn.Exec(ctx, `
+			SELECT pg_advisory_unlock(hashtext($1))
+		`, name); err != nil {
+			// Closing the connection drops every session lock it holds
+			conn.Conn().Close(ctx)
+		}
+		conn.Release()
+	}
+
+	return release, true, nil
+}
+
+func (s *Storage) CreateJobRun(ctx context.Context, name string, startedAt time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CreateJobRun")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (int64, error) {
+		return 0, fmt.Errorf("storage.postgres.CreateJobRun: %s: %w", msg, err)
+	}
+
+	var dbID int64
+
+	if err := s.pool.QueryRow(ctx, `
+		INSERT INTO job_runs(job_name, started_at)
+		VALUES($1, $2)
+		RETURNING id
+	`, name, startedAt.UTC()).Scan(&dbID); err != nil {
+		return fail("insert job run with returning", err)
+	}
+
+	return dbID, nil
+}
+
+func (s *Storage) FinishJobRun(ctx context.Context, run models.JobRun) error {
+	ctx, span := startSpan(ctx, "storage.postgres.FinishJobRun")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.FinishJobRun: %s: %w", msg, err)
+	}
+
+	var finishedAt *time.Time
+	if run.FinishedAt != nil {
+		t := run.FinishedAt.UTC()
+		finishedAt = &t
+	}
+
+	if _, err := s.pool.Exec(ctx, `
+		UPDATE job_runs
+		SET finished_at = $2, rows_affected = $3, error = $4
+		WHERE id = $1
+	`, run.ID, finishedAt, run.RowsAffected, run.Error); err != nil {
+		return fail("update job run", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetJobRuns(ctx context.Context, name string, limit int64) ([]models.JobRun, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetJobRuns")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.JobRun, error) {
+		return []models.JobRun{}, fmt.Errorf("storage.postgres.GetJobRuns: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, job_name, started_at, finished_at, rows_affected, error
+		FROM job_runs
+		WHERE job_name = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`, name, limit)
+	if err != nil {
+		return fail("query job runs", err)
+	}
+	defer rows.Close()
+
+	runs := []models.JobRun{}
+
+	for rows.Next() {
+		var run models.JobRun
+		if err := rows.Scan(
+			&run.ID,
+			&run.JobName,
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.RowsAffected,
+			&run.Error,
+		); err != nil {
+			return fail("scan job runs", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate job runs", err)
+	}
+
+	return runs, nil
 }
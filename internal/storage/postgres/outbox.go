@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"segmentify/internal/models"
+	"segmentify/internal/snapshot"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// membershipChanges collects users entering or leaving segments, so events
+// about them can be written with a single query.
+type membershipChanges struct {
+	projects []string
+	users    []int64
+	segments []string
+}
+
+func (c *membershipChanges) add(project string, userID int64, segment string) {
+	c.projects = append(c.projects, project)
+	c.users = append(c.users, userID)
+	c.segments = append(c.segments, segment)
+}
+
+// writeMembershipEvents writes the changes to the outbox and enqueues their
+// deliveries to every matching webhook. It is called with the transaction of
+// the changes, so events are published if and only if the changes are
+// committed.
+func writeMembershipEvents(ctx context.Context, tx pgx.Tx, eventType, reason string, changes membershipChanges) error {
+	if len(changes.users) == 0 {
+		return nil
+	}
+
+	// Deliveries reuse the ID of the outbox event, so consumers of both see
+	// the same event under the same ID.
+	if _, err := tx.Exec(ctx, `
+		WITH events AS (
+			INSERT INTO outbox(event_id, event_type, project_slug, payload)
+			SELECT e.id, $4, e.project_slug, jsonb_build_object(
+				'id', e.id,
+				'type', $4::text,
+				'project', e.project_slug,
+				'segment', e.segment_slug,
+				'user_id', e.user_id,
+				'reason', $5::text,
+				'occurred_at', $6::text
+			)
+			FROM (
+				SELECT gen_random_uuid() AS id, c.*
+				FROM unnest($1::text[], $2::bigint[], $3::text[]) WITH ORDINALITY AS c(project_slug, user_id, segment_slug, n)
+			) AS e
+			ORDER BY e.n
+			RETURNING id, event_id, project_slug, payload
+		)
+		INSERT INTO webhook_deliveries(webhook_id, event_id, event_type, payload)
+		SELECT webhooks.id, events.event_id, $4, events.payload
+		FROM events
+		JOIN webhooks
+		ON webhooks.project_slug = events.project_slug
+		AND (cardinality(webhooks.segments) = 0 OR events.payload->>'segment' = ANY(webhooks.segments))
+		AND (cardinality(webhooks.events) = 0 OR $4 = ANY(webhooks.events))
+		ORDER BY events.id, webhooks.id
+	`,
+		changes.projects, changes.users, changes.segments,
+		eventType, reason, time.Now().UTC().Format(time.RFC3339Nano),
+	); err != nil {
+		return fmt.Errorf("insert membership events: %w", err)
+	}
+
+	return nil
+}
+
+// writeSegmentEvent writes a segment event to the outbox with the transaction
+// of the change.
+func writeSegmentEvent(ctx context.Context, db execer, eventType, project string, segment models.Segment) error {
+	if _, err := db.Exec(ctx, `
+		INSERT INTO outbox(event_id, event_type, project_slug, payload)
+		SELECT e.id, $1, $2, jsonb_build_object(
+			'id', e.id,
+			'type', $1::text,
+			'project', $2::text,
+			'segment', $3::text,
+			'percent', $4::bigint,
+			'occurred_at', $5::text
+		)
+		FROM (SELECT gen_random_uuid() AS id) AS e
+	`, eventType, project, segment.Slug, segment.Percent, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("insert segment event: %w", err)
+	}
+
+	return nil
+}
+
+// writeSnapshotEvents writes a snapshot event for every project of the
+// archive being imported and returns the projects.
+func writeSnapshotEvents(ctx context.Context, tx pgx.Tx, mode snapshot.Mode) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		INSERT INTO outbox(event_id, event_type, project_slug, payload)
+		SELECT e.id, $1, e.slug, jsonb_build_object(
+			'id', e.id,
+			'type', $1::text,
+			'project', e.slug,
+			'mode', $2::text,
+			'occurred_at', $3::text
+		)
+		FROM (
+			SELECT gen_random_uuid() AS id, slug
+			FROM snapshot_projects
+			ORDER BY slug
+		) AS e
+		RETURNING project_slug
+	`, models.EventSnapshotImported, string(mode), time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return nil, fmt.Errorf("insert snapshot events: %w", err)
+	}
+
+	defer rows.Close()
+
+	projects := []string{}
+	for rows.Next() {
+		var project string
+		if err := rows.Scan(&project); err != nil {
+			return nil, fmt.Errorf("scan snapshot events: %w", err)
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("insert snapshot events: %w", err)
+	}
+
+	return projects, nil
+}
+
+// GetUnpublishedEvents returns the oldest events not published yet, in the
+// order they were written.
+func (s *Storage) GetUnpublishedEvents(ctx context.Context, limit int64) ([]models.OutboxEvent, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetUnpublishedEvents")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.OutboxEvent, error) {
+		return []models.OutboxEvent{}, fmt.Errorf("storage.postgres.GetUnpublishedEvents: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, event_id::text, event_type, project_slug, payload, created_at
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return fail("query outbox", err)
+	}
+	defer rows.Close()
+
+	events := []models.OutboxEvent{}
+
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.EventID, &e.Type, &e.Project, &e.Payload, &e.CreatedAt); err != nil {
+			return fail("scan outbox", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate outbox", err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) MarkEventsPublished(ctx context.Context, ids []int64) error {
+	ctx, span := startSpan(ctx, "storage.postgres.MarkEventsPublished")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.MarkEventsPublished: %s: %w", msg, err)
+	}
+
+	if _, err := s.pool.Exec(ctx, `
+		UPDATE outbox
+		SET published_at = NOW()
+		WHERE id = ANY($1)
+	`, ids); err != nil {
+		return fail("update outbox", err)
+	}
+
+	return nil
+}
+
+// PruneOutbox deletes events published before the given time.
+func (s *Storage) PruneOutbox(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.PruneOutbox")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (int64, error) {
+		return 0, fmt.Errorf("storage.postgres.PruneOutbox: %s: %w", msg, err)
+	}
+
+	res, err := s.pool.Exec(ctx, `
+		DELETE FROM outbox
+		WHERE published_at < $1
+	`, before.UTC())
+	if err != nil {
+		return fail("delete outbox", err)
+	}
+
+	return res.RowsAffected(), nil
+}
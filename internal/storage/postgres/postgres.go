@@ -2,48 +2,127 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"segmentify/internal/cache"
+	"segmentify/internal/config"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-const maxConnAttempts = 10
+// createTableRe extracts table names from init.sql, so the schema check
+// never falls behind the tables it creates.
+var createTableRe = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS\s+(\w+)`)
+
+// SchemaVersion is recorded in snapshots, which are only imported into the
+// schema they were exported from. Bump it when init.sql changes a table of
+// a snapshot.
+const SchemaVersion = 1
 
 type Storage struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	cfg    config.Postgres
+	tables []string
+	// userSegments caches GetUserSegments. It is nil if caching is disabled.
+	userSegments *cache.UserSegments
+
+	// replicas serve reads of segments and user segments, see reader.
+	replicas      []*replica
+	nextReplica   atomic.Uint64
+	maxReplicaLag time.Duration
 }
 
-func New(ctx context.Context, storagePath string) (*Storage, error) {
+// New connects to the primary database, retrying with backoff as configured,
+// so the service can start before the database accepts connections.
+func New(ctx context.Context, cfg config.Postgres) (*Storage, error) {
 	fail := func(msg string, err error) (*Storage, error) {
 		return nil, fmt.Errorf("storage.postgres.New: %s: %w", msg, err)
 	}
 
-	pool, err := pgxpool.New(ctx, storagePath)
+	poolConfig, err := newPoolConfig(cfg.URL, cfg)
 	if err != nil {
-		return fail("create a database poll", err)
+		return fail("parse a database url", err)
 	}
 
-	connAttempts := maxConnAttempts
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fail("create a database pool", err)
+	}
 
-	for connAttempts > 0 {
-		time.Sleep(time.Second)
+	wait := cfg.ConnectRetryBase
 
+	for attempt := 1; ; attempt++ {
 		err = pool.Ping(ctx)
 		if err == nil {
 			break
 		}
 
-		connAttempts--
+		if attempt >= cfg.ConnectAttempts {
+			pool.Close()
+			return fail(fmt.Sprintf("ping a database, %d attempts", attempt), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			pool.Close()
+			return fail("ping a database", ctx.Err())
+		case <-time.After(wait):
+		}
+
+		wait = min(2*wait, cfg.ConnectRetryMax)
 	}
 
+	return &Storage{pool: pool, cfg: cfg}, nil
+}
+
+// newPoolConfig applies the pool settings to the pool of the database at url.
+func newPoolConfig(url string, cfg config.Postgres) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(url)
 	if err != nil {
-		return fail("ping a database", err)
+		return nil, err
+	}
+
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	poolConfig.ConnConfig.Tracer = tracer{}
+
+	// Timestamps are stored without a time zone and read as UTC, so NOW()
+	// defaults and date casts must be in UTC too
+	poolConfig.ConnConfig.RuntimeParams["timezone"] = "UTC"
+
+	if cfg.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	}
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
 	}
 
-	return &Storage{pool: pool}, nil
+	return poolConfig, nil
+}
+
+// withTimeout bounds a storage operation by the query timeout.
+func (s *Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.cfg.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.cfg.QueryTimeout)
+}
+
+// SetUserSegmentsCache puts the cache in front of GetUserSegments. Changes of
+// user segments made through this storage invalidate it.
+func (s *Storage) SetUserSegmentsCache(userSegments *cache.UserSegments) {
+	s.userSegments = userSegments
 }
 
 func (s *Storage) Init(ctx context.Context) error {
@@ -66,11 +145,59 @@ func (s *Storage) Init(ctx context.Context) error {
 		return fail("init storage", err)
 	}
 
+	tables := []string{}
+	for _, match := range createTableRe.FindAllStringSubmatch(string(query), -1) {
+		tables = append(tables, match[1])
+	}
+	s.tables = tables
+
 	return nil
 }
 
+func (s *Storage) Ping(ctx context.Context) error {
+	if err := s.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("storage.postgres.Ping: %w", err)
+	}
+
+	return nil
+}
+
+// CheckSchema verifies that Init has run and every table it creates exists.
+func (s *Storage) CheckSchema(ctx context.Context) error {
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.CheckSchema: %s: %w", msg, err)
+	}
+
+	if len(s.tables) == 0 {
+		return fail("check tables", errors.New("storage is not initialized"))
+	}
+
+	var missing []string
+
+	if err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(name), '{}')
+		FROM unnest($1::text[]) AS name
+		WHERE to_regclass(name) IS NULL
+	`, s.tables).Scan(&missing); err != nil {
+		return fail("query tables", err)
+	}
+
+	if len(missing) > 0 {
+		return fail("check tables", fmt.Errorf("missing tables: %s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+func (s *Storage) Stat() *pgxpool.Stat {
+	return s.pool.Stat()
+}
+
 func (s *Storage) Close() {
 	if s.pool != nil {
 		s.pool.Close()
 	}
+	for _, r := range s.replicas {
+		r.pool.Close()
+	}
 }
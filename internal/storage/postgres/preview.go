@@ -0,0 +1,21 @@
+package postgres
+
+import "segmentify/internal/models"
+
+func newPreview() models.Preview {
+	return models.Preview{
+		UsersAffected: []int64{},
+		Conflicts:     []string{},
+		SegmentSizes:  map[string]int64{},
+	}
+}
+
+// addAffectedUsers counts the users as affected, listing them up to the limit.
+func addAffectedUsers(preview *models.Preview, ids ...int64) {
+	for _, id := range ids {
+		if len(preview.UsersAffected) < models.PreviewUsersLimit {
+			preview.UsersAffected = append(preview.UsersAffected, id)
+		}
+		preview.UsersAffectedCount++
+	}
+}
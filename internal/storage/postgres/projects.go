@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *Storage) CreateProject(ctx context.Context, project models.Project) (models.Project, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CreateProject")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.Project, error) {
+		return models.Project{}, fmt.Errorf("storage.postgres.CreateProject: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.QueryRow(ctx, `
+		INSERT INTO projects(slug, name)
+		VALUES($1, $2)
+		RETURNING created_at
+	`, project.Slug, project.Name).Scan(&project.CreatedAt); err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			return fail("insert project", &storage.ErrProjectExists{Slug: project.Slug})
+		}
+		return fail("insert project", err)
+	}
+
+	if err = writeAudit(ctx, tx, project.Slug, audit.ActionCreate, audit.EntityProject, project.Slug, nil, project); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return project, nil
+}
+
+func (s *Storage) GetProject(ctx context.Context, slug string) (models.Project, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetProject")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.Project, error) {
+		return models.Project{}, fmt.Errorf("storage.postgres.GetProject: %s: %w", msg, err)
+	}
+
+	project := models.Project{Slug: slug}
+
+	if err := s.pool.QueryRow(ctx, `
+		SELECT name, created_at
+		FROM projects
+		WHERE slug = $1
+	`, slug).Scan(&project.Name, &project.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("query project", &storage.ErrProjectNotFound{Slug: slug})
+		}
+		return fail("query project", err)
+	}
+
+	return project, nil
+}
+
+func (s *Storage) ListProjects(ctx context.Context) ([]models.Project, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ListProjects")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.Project, error) {
+		return []models.Project{}, fmt.Errorf("storage.postgres.ListProjects: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT slug, name, created_at
+		FROM projects
+		ORDER BY slug
+	`)
+	if err != nil {
+		return fail("query projects", err)
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+
+	for rows.Next() {
+		var project models.Project
+		if err := rows.Scan(&project.Slug, &project.Name, &project.CreatedAt); err != nil {
+			return fail("scan projects", err)
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate projects", err)
+	}
+
+	return projects, nil
+}
+
+// DeleteProject removes the project with all of its segments, users,
+// memberships, history and API keys.
+func (s *Storage) DeleteProject(ctx context.Context, slug string) error {
+	ctx, span := startSpan(ctx, "storage.postgres.DeleteProject")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.DeleteProject: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	project := models.Project{Slug: slug}
+
+	if err = tx.QueryRow(ctx, `
+		DELETE FROM projects
+		WHERE slug = $1
+		RETURNING name, created_at
+	`, slug).Scan(&project.Name, &project.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("delete project", &storage.ErrProjectNotFound{Slug: slug})
+		}
+		return fail("delete project", err)
+	}
+
+	if err = writeAudit(ctx, tx, slug, audit.ActionDelete, audit.EntityProject, slug, project, nil); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	s.userSegments.InvalidateProject(ctx, slug)
+
+	return nil
+}
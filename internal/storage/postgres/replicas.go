@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/storage"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaCheckTimeout bounds a single health check of a replica.
+const replicaCheckTimeout = 2 * time.Second
+
+// replica is a read replica. healthy is kept up to date by RunReplicaChecks.
+type replica struct {
+	host    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// ConnectReplicas adds read replicas. They serve reads once a check found
+// them healthy, i.e. answering and lagging behind the primary by maxLag at
+// most. Replica pools use the settings of the primary one. Empty URLs are
+// skipped.
+func (s *Storage) ConnectReplicas(ctx context.Context, urls []string, maxLag time.Duration) error {
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.ConnectReplicas: %s: %w", msg, err)
+	}
+
+	for _, url := range urls {
+		if strings.TrimSpace(url) == "" {
+			continue
+		}
+
+		poolConfig, err := newPoolConfig(url, s.cfg)
+		if err != nil {
+			return fail("parse a replica url", err)
+		}
+
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			return fail("create a replica pool", err)
+		}
+
+		s.replicas = append(s.replicas, &replica{host: poolConfig.ConnConfig.Host, pool: pool})
+	}
+
+	s.maxReplicaLag = maxLag
+
+	return nil
+}
+
+// RunReplicaChecks checks the replicas every interval until ctx is done.
+// Unhealthy replicas stop serving reads, which fall back to other replicas
+// or the primary, and serve them again once they recover.
+func (s *Storage) RunReplicaChecks(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	const op = "storage.postgres.RunReplicaChecks"
+
+	if len(s.replicas) == 0 {
+		return
+	}
+
+	log = log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for i, r := range s.replicas {
+			err := s.checkReplica(ctx, r)
+			if ctx.Err() != nil {
+				return
+			}
+
+			healthy := err == nil
+			if r.healthy.Swap(healthy) == healthy {
+				continue
+			}
+
+			log := log.With(slog.Int("replica", i), slog.String("host", r.host))
+			if healthy {
+				log.Info("replica is healthy")
+			} else {
+				log.Warn("replica is unhealthy, reads fall back", sl.Err(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkReplica fails if the replica does not answer or lags too far behind.
+// A replica that replayed everything it received does not lag, however long
+// ago the primary last wrote.
+func (s *Storage) checkReplica(ctx context.Context, r *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lag float64
+
+	if err := r.pool.QueryRow(ctx, `
+		SELECT CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+		END::float8
+	`).Scan(&lag); err != nil {
+		return fmt.Errorf("query replication lag: %w", err)
+	}
+
+	if d := time.Duration(lag * float64(time.Second)); d > s.maxReplicaLag {
+		return fmt.Errorf("replication lag %s exceeds %s", d.Round(time.Millisecond), s.maxReplicaLag)
+	}
+
+	return nil
+}
+
+// reader returns the pool to read from: a healthy replica, taken in turn, or
+// the primary if there is none or the context requires it.
+func (s *Storage) reader(ctx context.Context) *pgxpool.Pool {
+	if len(s.replicas) == 0 || storage.PrimaryRequired(ctx) {
+		return s.pool
+	}
+
+	n := uint64(len(s.replicas))
+	start := s.nextReplica.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+
+	return s.pool
+}
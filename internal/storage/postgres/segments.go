@@ -4,21 +4,60 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"segmentify/internal/audit"
 	"segmentify/internal/models"
 	"segmentify/internal/storage"
 	"strconv"
+	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func (s *Storage) CreateSegment(ctx context.Context, segment models.Segment) (models.Segment, error) {
+func (s *Storage) CreateSegment(ctx context.Context, project string, segment models.Segment) (models.Segment, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CreateSegment")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) (models.Segment, error) {
 		return models.Segment{}, fmt.Errorf("storage.postgres.CreateSegment: %s: %w", msg, err)
 	}
-	failRowsAffected := func(msg, expected, got string) (models.Segment, error) {
-		return fail(msg, fmt.Errorf("not enough rows affected; expected: %s, got: %s", expected, got))
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = s.createSegment(ctx, tx, project, segment); err != nil {
+		return fail("create segment", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fail("commit transaction", err)
+	}
+
+	// The roll-out added the segment to users of the project
+	if segment.Percent > 0 {
+		s.userSegments.InvalidateProject(ctx, project)
+	}
+
+	return segment, nil
+}
+
+// PreviewCreateSegment creates the segment in a transaction that is rolled
+// back, and reports the users the roll-out would add it to. An existing
+// segment is reported as a conflict.
+func (s *Storage) PreviewCreateSegment(ctx context.Context, project string, segment models.Segment) (models.Preview, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.PreviewCreateSegment")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.Preview, error) {
+		return models.Preview{}, fmt.Errorf("storage.postgres.PreviewCreateSegment: %s: %w", msg, err)
 	}
 
 	tx, err := s.pool.Begin(ctx)
@@ -27,40 +66,80 @@ func (s *Storage) CreateSegment(ctx context.Context, segment models.Segment) (mo
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err = tx.Exec(ctx, `
-		INSERT INTO segments(slug, percent)
-		VALUES($1, $2)
-	`, segment.Slug, segment.Percent); err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.UniqueViolation {
-			return fail("insert segment", &storage.ErrSegmentExists{Slug: segment.Slug})
+	preview := newPreview()
+
+	added, err := s.createSegment(ctx, tx, project, segment)
+	if err != nil {
+		var errSegmentExists *storage.ErrSegmentExists
+
+		if !errors.As(err, &errSegmentExists) {
+			return fail("create segment", err)
 		}
-		return fail("insert segment", err)
+		preview.Conflicts = append(preview.Conflicts, errSegmentExists.Error())
+	}
+
+	addAffectedUsers(&preview, added...)
+	preview.Added = int64(len(added))
+
+	if preview.SegmentSizes, err = segmentSizes(ctx, tx, project, []string{segment.Slug}); err != nil {
+		return fail("count segment sizes", err)
+	}
+
+	return preview, nil
+}
+
+// createSegment creates the segment in tx and adds it to the given percent
+// of users of the project. It returns the users the segment was added to.
+func (s *Storage) createSegment(ctx context.Context, tx pgx.Tx, project string, segment models.Segment) ([]int64, error) {
+	failRowsAffected := func(msg, expected, got string) ([]int64, error) {
+		return nil, fmt.Errorf("%s: not enough rows affected; expected: %s, got: %s", msg, expected, got)
+	}
+
+	// A conflict leaves the transaction usable, so a preview can go on
+	res, err := tx.Exec(ctx, `
+		INSERT INTO segments(project_slug, slug, percent)
+		VALUES($1, $2, $3)
+		ON CONFLICT (project_slug, slug) DO NOTHING
+	`, project, segment.Slug, segment.Percent)
+	if err != nil {
+		return nil, fmt.Errorf("insert segment: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return nil, fmt.Errorf("insert segment: %w", &storage.ErrSegmentExists{Slug: segment.Slug})
+	}
+
+	if err = writeSegmentEvent(ctx, tx, models.EventSegmentCreated, project, segment); err != nil {
+		return nil, fmt.Errorf("write segment event: %w", err)
 	}
 
+	usersToAdd := []int64{}
+
 	if segment.Percent > 0 {
 		var usersCount int64
 		if err = tx.QueryRow(ctx, `
-			SELECT COUNT(*) FROM users
-		`).Scan(&usersCount); err != nil {
-			return fail("count users", err)
+			SELECT COUNT(*)
+			FROM users
+			WHERE project_slug = $1
+		`, project).Scan(&usersCount); err != nil {
+			return nil, fmt.Errorf("count users: %w", err)
 		}
 
 		usersToAddCount := usersCount * segment.Percent / 100
-		usersToAdd, err := s.GetRandomUsers(ctx, usersToAddCount)
+		usersToAdd, err = s.GetRandomUsers(ctx, project, usersToAddCount)
 		if err != nil {
-			return fail("get random users", err)
+			return nil, fmt.Errorf("get random users: %w", err)
 		}
 
 		rowsAffected, err := tx.CopyFrom(
 			ctx,
 			pgx.Identifier{"users_segments"},
-			[]string{"user_id", "segment_slug", "expire_at"},
+			[]string{"user_id", "project_slug", "segment_slug", "expire_at"},
 			pgx.CopyFromSlice(len(usersToAdd), func(i int) ([]any, error) {
-				return []any{usersToAdd[i], segment.Slug, nil}, nil
+				return []any{usersToAdd[i], project, segment.Slug, nil}, nil
 			}),
 		)
 		if err != nil {
-			return fail("insert users segments", err)
+			return nil, fmt.Errorf("insert users segments: %w", err)
 		}
 		if rowsAffected != usersToAddCount {
 			return failRowsAffected(
@@ -73,13 +152,13 @@ func (s *Storage) CreateSegment(ctx context.Context, segment models.Segment) (mo
 		rowsAffected, err = tx.CopyFrom(
 			ctx,
 			pgx.Identifier{"users_segments_history"},
-			[]string{"user_id", "segment_slug", "operation"},
+			[]string{"user_id", "project_slug", "segment_slug", "operation"},
 			pgx.CopyFromSlice(len(usersToAdd), func(i int) ([]any, error) {
-				return []any{usersToAdd[i], segment.Slug, "add"}, nil
+				return []any{usersToAdd[i], project, segment.Slug, "add"}, nil
 			}),
 		)
 		if err != nil {
-			return fail("insert users segments history", err)
+			return nil, fmt.Errorf("insert users segments history: %w", err)
 		}
 		if rowsAffected != usersToAddCount {
 			return failRowsAffected(
@@ -88,28 +167,77 @@ func (s *Storage) CreateSegment(ctx context.Context, segment models.Segment) (mo
 				strconv.FormatInt(rowsAffected, 10),
 			)
 		}
+
+		var added membershipChanges
+		for _, user := range usersToAdd {
+			added.add(project, user, segment.Slug)
+		}
+		if err = writeMembershipEvents(ctx, tx, models.EventUserAdded, models.ReasonRollout, added); err != nil {
+			return nil, fmt.Errorf("write membership events: %w", err)
+		}
 	}
 
-	err = tx.Commit(ctx)
+	if err = writeAudit(ctx, tx, project, audit.ActionCreate, audit.EntitySegment, segment.Slug, nil, segment); err != nil {
+		return nil, fmt.Errorf("write audit: %w", err)
+	}
+
+	return usersToAdd, nil
+}
+
+// segmentSizes counts the active members of the segments as seen by tx.
+// Segments that do not exist are left out.
+func segmentSizes(ctx context.Context, tx pgx.Tx, project string, slugs []string) (map[string]int64, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT segments.slug, COUNT(users_segments.user_id)
+		FROM segments
+		LEFT JOIN users_segments
+		ON users_segments.project_slug = segments.project_slug
+		AND users_segments.segment_slug = segments.slug
+		AND (
+			users_segments.expire_at IS NULL
+			OR users_segments.expire_at > NOW()
+		)
+		WHERE segments.project_slug = $1
+		AND segments.slug = ANY($2)
+		GROUP BY segments.slug
+	`, project, slugs)
 	if err != nil {
-		return fail("commit transaction", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	return segment, nil
+	sizes := map[string]int64{}
+
+	for rows.Next() {
+		var slug string
+		var size int64
+		if err := rows.Scan(&slug, &size); err != nil {
+			return nil, err
+		}
+		sizes[slug] = size
+	}
+
+	return sizes, rows.Err()
 }
 
-func (s *Storage) GetSegment(ctx context.Context, slug string) (models.Segment, error) {
+func (s *Storage) GetSegment(ctx context.Context, project, slug string) (models.Segment, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetSegment")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) (models.Segment, error) {
 		return models.Segment{}, fmt.Errorf("storage.postgres.GetSegment: %s: %w", msg, err)
 	}
 
 	var dbPercent int64
 
-	if err := s.pool.QueryRow(ctx, `
+	if err := s.reader(ctx).QueryRow(ctx, `
 		SELECT percent
 		FROM segments
-		WHERE slug = $1
-	`, slug).Scan(&dbPercent); err != nil {
+		WHERE project_slug = $1
+		AND slug = $2
+	`, project, slug).Scan(&dbPercent); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return fail("query segment", &storage.ErrSegmentNotFound{Slug: slug})
 		}
@@ -119,22 +247,267 @@ func (s *Storage) GetSegment(ctx context.Context, slug string) (models.Segment,
 	return models.Segment{Slug: slug, Percent: dbPercent}, nil
 }
 
-func (s *Storage) DeleteSegment(ctx context.Context, slug string) error {
+// GetSegmentStats returns the current members of the segment and its history
+// for every UTC day from from to to, both inclusive. The size of a day counts
+// all the history up to its end, including the days before from.
+func (s *Storage) GetSegmentStats(ctx context.Context, project, slug string, from, to time.Time) (models.SegmentStats, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetSegmentStats")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.SegmentStats, error) {
+		return models.SegmentStats{}, fmt.Errorf("storage.postgres.GetSegmentStats: %s: %w", msg, err)
+	}
+
+	stats := models.SegmentStats{Slug: slug, Days: []models.SegmentDay{}}
+
+	if err := s.reader(ctx).QueryRow(ctx, `
+		SELECT
+			segments.percent,
+			COUNT(users_segments.user_id),
+			COUNT(users_segments.expire_at),
+			(SELECT COUNT(*) FROM users WHERE project_slug = $1)
+		FROM segments
+		LEFT JOIN users_segments
+		ON users_segments.project_slug = segments.project_slug
+		AND users_segments.segment_slug = segments.slug
+		AND (
+			users_segments.expire_at IS NULL
+			OR users_segments.expire_at > NOW()
+		)
+		WHERE segments.project_slug = $1
+		AND segments.slug = $2
+		GROUP BY segments.percent
+	`, project, slug).Scan(&stats.Percent, &stats.Members, &stats.MembersWithTTL, &stats.Users); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("query segment", &storage.ErrSegmentNotFound{Slug: slug})
+		}
+		return fail("query segment", err)
+	}
+
+	if stats.Users > 0 {
+		stats.ActualPercent = math.Round(float64(stats.Members)*10000/float64(stats.Users)) / 100
+	}
+
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT
+			created_at::date,
+			COUNT(*) FILTER (WHERE operation = 'add'),
+			COUNT(*) FILTER (WHERE operation = 'remove')
+		FROM users_segments_history
+		WHERE project_slug = $1
+		AND segment_slug = $2
+		AND created_at < $3::date + 1
+		GROUP BY 1
+		ORDER BY 1
+	`, project, slug, to.Format(time.DateOnly))
+	if err != nil {
+		return fail("query history", err)
+	}
+	defer rows.Close()
+
+	changes := map[string]models.SegmentDay{}
+	var size int64
+
+	for rows.Next() {
+		var day time.Time
+		var change models.SegmentDay
+		if err := rows.Scan(&day, &change.Added, &change.Removed); err != nil {
+			return fail("scan history", err)
+		}
+		change.Date = day.Format(time.DateOnly)
+		if change.Date < from.Format(time.DateOnly) {
+			size += change.Added - change.Removed
+			continue
+		}
+		changes[change.Date] = change
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate history", err)
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		change := changes[day.Format(time.DateOnly)]
+		size += change.Added - change.Removed
+		stats.Days = append(stats.Days, models.SegmentDay{
+			Date:    day.Format(time.DateOnly),
+			Added:   change.Added,
+			Removed: change.Removed,
+			Size:    size,
+		})
+	}
+
+	return stats, nil
+}
+
+// ListSegments returns the segments of the project ordered by slug.
+func (s *Storage) ListSegments(ctx context.Context, project string) ([]models.Segment, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ListSegments")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.Segment, error) {
+		return []models.Segment{}, fmt.Errorf("storage.postgres.ListSegments: %s: %w", msg, err)
+	}
+
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT slug, percent
+		FROM segments
+		WHERE project_slug = $1
+		ORDER BY slug
+	`, project)
+	if err != nil {
+		return fail("query segments", err)
+	}
+	defer rows.Close()
+
+	segments := []models.Segment{}
+
+	for rows.Next() {
+		var segment models.Segment
+		if err := rows.Scan(&segment.Slug, &segment.Percent); err != nil {
+			return fail("scan segments", err)
+		}
+		segments = append(segments, segment)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate segments", err)
+	}
+
+	return segments, nil
+}
+
+func (s *Storage) DeleteSegment(ctx context.Context, project, slug string) error {
+	ctx, span := startSpan(ctx, "storage.postgres.DeleteSegment")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) error {
 		return fmt.Errorf("storage.postgres.DeleteSegment: %s: %w", msg, err)
 	}
 
-	res, err := s.pool.Exec(ctx, `
-		DELETE FROM segments
-		WHERE slug = $1
-	`, slug)
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	segment := models.Segment{Slug: slug}
+
+	// The lock keeps members from being added until the segment is gone
+	if err = tx.QueryRow(ctx, `
+		SELECT percent
+		FROM segments
+		WHERE project_slug = $1
+		AND slug = $2
+		FOR UPDATE
+	`, project, slug).Scan(&segment.Percent); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("lock segment", &storage.ErrSegmentNotFound{Slug: slug})
+		}
+		return fail("lock segment", err)
+	}
+
+	// Members are deleted before the segment rather than by the cascade, so
+	// subscribers are told they left it
+	rows, err := tx.Query(ctx, `
+		DELETE FROM users_segments
+		WHERE project_slug = $1
+		AND segment_slug = $2
+		RETURNING user_id
+	`, project, slug)
+	if err != nil {
+		return fail("delete users segments", err)
+	}
+
+	var removed membershipChanges
+
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return fail("scan users segments", err)
+		}
+		removed.add(project, userID, slug)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fail("iterate users segments", err)
+	}
+
+	if _, err = tx.Exec(ctx, `
+		DELETE FROM segments
+		WHERE project_slug = $1
+		AND slug = $2
+	`, project, slug); err != nil {
 		return fail("delete segment", err)
 	}
 
-	if res.RowsAffected() == 0 {
-		return fail("rows affected", &storage.ErrSegmentNotFound{Slug: slug})
+	if err = writeMembershipEvents(ctx, tx, models.EventUserRemoved, models.ReasonSegmentDeleted, removed); err != nil {
+		return fail("write membership events", err)
+	}
+
+	if err = writeSegmentEvent(ctx, tx, models.EventSegmentDeleted, project, segment); err != nil {
+		return fail("write segment event", err)
+	}
+
+	if err = writeAudit(ctx, tx, project, audit.ActionDelete, audit.EntitySegment, slug, segment, nil); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
 	}
 
+	s.userSegments.InvalidateProject(ctx, project)
+
 	return nil
 }
+
+// CountSegmentMembers returns the number of active members for every segment
+// of every project, including segments with no members at all.
+func (s *Storage) CountSegmentMembers(ctx context.Context) ([]models.SegmentMembers, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CountSegmentMembers")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.SegmentMembers, error) {
+		return []models.SegmentMembers{}, fmt.Errorf("storage.postgres.CountSegmentMembers: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT segments.project_slug, segments.slug, COUNT(users_segments.user_id)
+		FROM segments
+		LEFT JOIN users_segments
+		ON users_segments.project_slug = segments.project_slug
+		AND users_segments.segment_slug = segments.slug
+		AND (
+			users_segments.expire_at IS NULL
+			OR users_segments.expire_at > NOW()
+		)
+		GROUP BY segments.project_slug, segments.slug
+	`)
+	if err != nil {
+		return fail("query segment members", err)
+	}
+	defer rows.Close()
+
+	members := []models.SegmentMembers{}
+
+	for rows.Next() {
+		var m models.SegmentMembers
+		if err = rows.Scan(&m.Project, &m.Slug, &m.Members); err != nil {
+			return fail("scan segment members", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate segment members", err)
+	}
+
+	return members, nil
+}
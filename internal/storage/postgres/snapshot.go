@@ -0,0 +1,437 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/snapshot"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExportSnapshot writes the projects, segments, users, memberships and
+// history to w as a snapshot archive. The tables are read in one repeatable
+// read transaction, so the archive is consistent. Whole tables are read, so
+// neither the query nor the statement timeout applies.
+func (s *Storage) ExportSnapshot(ctx context.Context, w io.Writer) (snapshot.Manifest, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ExportSnapshot")
+	defer span.End()
+
+	fail := func(msg string, err error) (snapshot.Manifest, error) {
+		return snapshot.Manifest{}, fmt.Errorf("storage.postgres.ExportSnapshot: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return fail("disable statement timeout", err)
+	}
+
+	sw := snapshot.NewWriter(w, SchemaVersion)
+
+	if err = exportTable(ctx, tx, sw, snapshot.TableProjects, `
+		SELECT slug, name, created_at
+		FROM projects
+		ORDER BY slug
+	`, func(rows pgx.Rows) (any, error) {
+		var p snapshot.Project
+		err := rows.Scan(&p.Slug, &p.Name, &p.CreatedAt)
+		return p, err
+	}); err != nil {
+		return fail("export projects", err)
+	}
+
+	if err = exportTable(ctx, tx, sw, snapshot.TableSegments, `
+		SELECT project_slug, slug, percent
+		FROM segments
+		ORDER BY project_slug, slug
+	`, func(rows pgx.Rows) (any, error) {
+		var seg snapshot.Segment
+		err := rows.Scan(&seg.Project, &seg.Slug, &seg.Percent)
+		return seg, err
+	}); err != nil {
+		return fail("export segments", err)
+	}
+
+	if err = exportTable(ctx, tx, sw, snapshot.TableUsers, `
+		SELECT project_slug, id
+		FROM users
+		ORDER BY id
+	`, func(rows pgx.Rows) (any, error) {
+		var u snapshot.User
+		err := rows.Scan(&u.Project, &u.ID)
+		return u, err
+	}); err != nil {
+		return fail("export users", err)
+	}
+
+	if err = exportTable(ctx, tx, sw, snapshot.TableMemberships, `
+		SELECT project_slug, user_id, segment_slug, expire_at
+		FROM users_segments
+		ORDER BY user_id, segment_slug
+	`, func(rows pgx.Rows) (any, error) {
+		var m snapshot.Membership
+		err := rows.Scan(&m.Project, &m.UserID, &m.Segment, &m.ExpireAt)
+		return m, err
+	}); err != nil {
+		return fail("export memberships", err)
+	}
+
+	if err = exportTable(ctx, tx, sw, snapshot.TableHistory, `
+		SELECT project_slug, user_id, segment_slug, operation, created_at
+		FROM users_segments_history
+		ORDER BY created_at, user_id, segment_slug
+	`, func(rows pgx.Rows) (any, error) {
+		var h snapshot.HistoryEntry
+		err := rows.Scan(&h.Project, &h.UserID, &h.Segment, &h.Operation, &h.CreatedAt)
+		return h, err
+	}); err != nil {
+		return fail("export history", err)
+	}
+
+	manifest, err := sw.Close()
+	if err != nil {
+		return fail("close archive", err)
+	}
+
+	return manifest, nil
+}
+
+// exportTable writes the rows returned by the query to the table of the archive.
+func exportTable(
+	ctx context.Context,
+	tx pgx.Tx,
+	w *snapshot.Writer,
+	table, query string,
+	scan func(rows pgx.Rows) (any, error),
+) error {
+	if err := w.Table(table); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ImportSnapshot imports an archive in one transaction, see snapshot.Mode.
+// Rows are streamed from the archive into the database. Stored rows
+// differing from the archive are reported as conflicts. A user whose ID
+// belongs to another project can not be imported, so it is skipped along
+// with its memberships and history. Instead of an event per change, one
+// snapshot event is written for every imported project.
+func (s *Storage) ImportSnapshot(ctx context.Context, archive *snapshot.Archive, mode snapshot.Mode) (snapshot.Result, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ImportSnapshot")
+	defer span.End()
+
+	fail := func(msg string, err error) (snapshot.Result, error) {
+		return snapshot.Result{}, fmt.Errorf("storage.postgres.ImportSnapshot: %s: %w", msg, err)
+	}
+
+	if archive.Manifest.SchemaVersion != SchemaVersion {
+		return fail("check schema version", &snapshot.ErrInvalid{Problems: []string{
+			fmt.Sprintf("schema version %d, expected %d", archive.Manifest.SchemaVersion, SchemaVersion),
+		}})
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return fail("disable statement timeout", err)
+	}
+
+	if err = copySnapshot(ctx, tx, archive); err != nil {
+		return fail("copy snapshot", err)
+	}
+
+	result := snapshot.Result{
+		Mode:      mode,
+		Tables:    map[string]snapshot.TableResult{},
+		Conflicts: []snapshot.Conflict{},
+	}
+	for _, table := range snapshot.Tables {
+		result.Tables[table] = snapshot.TableResult{}
+	}
+
+	if mode == snapshot.ModeReplace {
+		// Dependent rows are deleted first, so they are counted
+		for _, table := range []string{snapshot.TableHistory, snapshot.TableMemberships, snapshot.TableSegments, snapshot.TableUsers} {
+			res, err := tx.Exec(ctx, fmt.Sprintf(`
+				DELETE FROM %s
+				WHERE project_slug IN (SELECT slug FROM snapshot_projects)
+			`, pgx.Identifier{table}.Sanitize()))
+			if err != nil {
+				return fail("delete "+table, err)
+			}
+
+			r := result.Tables[table]
+			r.Deleted = res.RowsAffected()
+			result.Tables[table] = r
+		}
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT $1::text, p.slug, $5::text, format('name %s -> %s', p.name, sp.name)
+		FROM snapshot_projects sp
+		JOIN projects p ON p.slug = sp.slug
+		WHERE p.name <> sp.name
+		UNION ALL
+		SELECT $2::text, s.project_slug || '/' || s.slug, $5::text, format('percent %s -> %s', s.percent, ss.percent)
+		FROM snapshot_segments ss
+		JOIN segments s ON s.project_slug = ss.project_slug AND s.slug = ss.slug
+		WHERE s.percent <> ss.percent
+		UNION ALL
+		SELECT $3::text, su.id::text, $6::text, format('user belongs to project %s', u.project_slug)
+		FROM snapshot_users su
+		JOIN users u ON u.id = su.id
+		WHERE u.project_slug <> su.project_slug
+		UNION ALL
+		SELECT $4::text, sm.user_id || '/' || sm.segment_slug, $5::text,
+			format('expire_at %s -> %s', COALESCE(m.expire_at::text, 'none'), COALESCE(sm.expire_at::text, 'none'))
+		FROM snapshot_users_segments sm
+		JOIN users_segments m ON m.user_id = sm.user_id AND m.segment_slug = sm.segment_slug
+		WHERE m.project_slug = sm.project_slug
+		AND m.expire_at IS DISTINCT FROM sm.expire_at
+	`,
+		snapshot.TableProjects, snapshot.TableSegments, snapshot.TableUsers, snapshot.TableMemberships,
+		snapshot.ResolutionOverwritten, snapshot.ResolutionSkipped,
+	)
+	if err != nil {
+		return fail("query conflicts", err)
+	}
+	for rows.Next() {
+		var c snapshot.Conflict
+		if err := rows.Scan(&c.Table, &c.Key, &c.Resolution, &c.Detail); err != nil {
+			rows.Close()
+			return fail("scan conflicts", err)
+		}
+		result.Conflicts = append(result.Conflicts, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fail("iterate conflicts", err)
+	}
+
+	// Memberships and history are only added for users of the same project,
+	// the ones skipped above are left out
+	upserts := []struct {
+		table string
+		query string
+	}{
+		{snapshot.TableProjects, `
+			INSERT INTO projects(slug, name, created_at)
+			SELECT slug, name, created_at
+			FROM snapshot_projects
+			ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name
+			WHERE projects.name <> EXCLUDED.name
+		`},
+		{snapshot.TableSegments, `
+			INSERT INTO segments(project_slug, slug, percent)
+			SELECT project_slug, slug, percent
+			FROM snapshot_segments
+			ON CONFLICT (project_slug, slug) DO UPDATE SET percent = EXCLUDED.percent
+			WHERE segments.percent <> EXCLUDED.percent
+		`},
+		{snapshot.TableUsers, `
+			INSERT INTO users(id, project_slug)
+			SELECT id, project_slug
+			FROM snapshot_users
+			ON CONFLICT (id) DO NOTHING
+		`},
+		{snapshot.TableMemberships, `
+			INSERT INTO users_segments(user_id, project_slug, segment_slug, expire_at)
+			SELECT sm.user_id, sm.project_slug, sm.segment_slug, sm.expire_at
+			FROM snapshot_users_segments sm
+			JOIN users u ON u.id = sm.user_id AND u.project_slug = sm.project_slug
+			ON CONFLICT (user_id, segment_slug) DO UPDATE SET expire_at = EXCLUDED.expire_at
+			WHERE users_segments.expire_at IS DISTINCT FROM EXCLUDED.expire_at
+		`},
+		{snapshot.TableHistory, `
+			INSERT INTO users_segments_history(user_id, project_slug, segment_slug, operation, created_at)
+			SELECT sh.user_id, sh.project_slug, sh.segment_slug, sh.operation, sh.created_at
+			FROM snapshot_users_segments_history sh
+			JOIN users u ON u.id = sh.user_id AND u.project_slug = sh.project_slug
+			WHERE NOT EXISTS (
+				SELECT 1
+				FROM users_segments_history h
+				WHERE h.user_id = sh.user_id
+				AND h.project_slug = sh.project_slug
+				AND h.segment_slug = sh.segment_slug
+				AND h.operation = sh.operation
+				AND h.created_at = sh.created_at
+			)
+		`},
+	}
+
+	for _, upsert := range upserts {
+		r := result.Tables[upsert.table]
+
+		// xmax is zero for inserted rows only
+		if err = tx.QueryRow(ctx, fmt.Sprintf(`
+			WITH upserted AS (%s RETURNING xmax = 0 AS inserted)
+			SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted)
+			FROM upserted
+		`, upsert.query)).Scan(&r.Inserted, &r.Updated); err != nil {
+			return fail("import "+upsert.table, err)
+		}
+
+		result.Tables[upsert.table] = r
+	}
+
+	// IDs were given explicitly, so the sequence has to be moved past them
+	if _, err = tx.Exec(ctx, `
+		SELECT setval(pg_get_serial_sequence('users', 'id'), GREATEST(MAX(id), 1))
+		FROM users
+	`); err != nil {
+		return fail("advance user id sequence", err)
+	}
+
+	projects, err := writeSnapshotEvents(ctx, tx, mode)
+	if err != nil {
+		return fail("write events", err)
+	}
+
+	if err = writeAudit(ctx, tx, "", audit.ActionImport, audit.EntitySnapshot, archive.Manifest.CreatedAt.Format(time.RFC3339), nil, result); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	for _, project := range projects {
+		s.userSegments.InvalidateProject(ctx, project)
+	}
+
+	return result, nil
+}
+
+// copySnapshot copies the archive into temporary tables dropped on commit.
+func copySnapshot(ctx context.Context, tx pgx.Tx, archive *snapshot.Archive) error {
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE snapshot_projects (
+			slug TEXT NOT NULL,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		) ON COMMIT DROP;
+		CREATE TEMP TABLE snapshot_segments (
+			project_slug TEXT NOT NULL,
+			slug TEXT NOT NULL,
+			percent SMALLINT NOT NULL
+		) ON COMMIT DROP;
+		CREATE TEMP TABLE snapshot_users (
+			id BIGINT NOT NULL,
+			project_slug TEXT NOT NULL
+		) ON COMMIT DROP;
+		CREATE TEMP TABLE snapshot_users_segments (
+			user_id BIGINT NOT NULL,
+			project_slug TEXT NOT NULL,
+			segment_slug TEXT NOT NULL,
+			expire_at TIMESTAMP
+		) ON COMMIT DROP;
+		CREATE TEMP TABLE snapshot_users_segments_history (
+			user_id BIGINT NOT NULL,
+			project_slug TEXT NOT NULL,
+			segment_slug TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		) ON COMMIT DROP;
+	`); err != nil {
+		return fmt.Errorf("create tables: %w", err)
+	}
+
+	copies := []func() error{
+		func() error {
+			return copyTable(ctx, tx, archive, snapshot.TableProjects, []string{"slug", "name", "created_at"}, func(p snapshot.Project) []any {
+				return []any{p.Slug, p.Name, p.CreatedAt}
+			})
+		},
+		func() error {
+			return copyTable(ctx, tx, archive, snapshot.TableSegments, []string{"project_slug", "slug", "percent"}, func(seg snapshot.Segment) []any {
+				return []any{seg.Project, seg.Slug, seg.Percent}
+			})
+		},
+		func() error {
+			return copyTable(ctx, tx, archive, snapshot.TableUsers, []string{"id", "project_slug"}, func(u snapshot.User) []any {
+				return []any{u.ID, u.Project}
+			})
+		},
+		func() error {
+			return copyTable(ctx, tx, archive, snapshot.TableMemberships, []string{"user_id", "project_slug", "segment_slug", "expire_at"}, func(m snapshot.Membership) []any {
+				return []any{m.UserID, m.Project, m.Segment, m.ExpireAt}
+			})
+		},
+		func() error {
+			return copyTable(ctx, tx, archive, snapshot.TableHistory, []string{"user_id", "project_slug", "segment_slug", "operation", "created_at"}, func(h snapshot.HistoryEntry) []any {
+				return []any{h.UserID, h.Project, h.Segment, h.Operation, h.CreatedAt}
+			})
+		},
+	}
+
+	for _, copyRows := range copies {
+		if err := copyRows(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyTable streams the rows of a table of the archive into its temporary
+// table.
+func copyTable[T any](ctx context.Context, tx pgx.Tx, archive *snapshot.Archive, table string, columns []string, values func(T) []any) error {
+	rows, err := snapshot.NewRows[T](archive, table)
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	if _, err = tx.CopyFrom(ctx, pgx.Identifier{"snapshot_" + table}, columns, copySource[T]{rows: rows, values: values}); err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// copySource feeds the rows of an archive table to COPY.
+type copySource[T any] struct {
+	rows   *snapshot.Rows[T]
+	values func(T) []any
+}
+
+func (s copySource[T]) Next() bool {
+	return s.rows.Next()
+}
+
+func (s copySource[T]) Values() ([]any, error) {
+	return s.values(s.rows.Row()), nil
+}
+
+func (s copySource[T]) Err() error {
+	return s.rows.Err()
+}
@@ -15,7 +15,12 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func (s *Storage) CreateUser(ctx context.Context) (int64, error) {
+func (s *Storage) CreateUser(ctx context.Context, project string) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CreateUser")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) (int64, error) {
 		return 0, fmt.Errorf("storage.postgres.CreateUser: %s: %w", msg, err)
 	}
@@ -23,28 +28,99 @@ func (s *Storage) CreateUser(ctx context.Context) (int64, error) {
 	var dbID int64
 
 	if err := s.pool.QueryRow(ctx, `
-		INSERT INTO users
-		DEFAULT VALUES
+		INSERT INTO users(project_slug)
+		VALUES($1)
 		RETURNING id
-	`).Scan(&dbID); err != nil {
+	`, project).Scan(&dbID); err != nil {
 		return fail("insert user with returning", err)
 	}
 
 	return dbID, nil
 }
 
-func (s *Storage) GetUser(ctx context.Context, id int64) (int64, error) {
+// ImportUsers creates the users with the given IDs in the project, skipping
+// the ones that already exist there, and returns how many were created. It
+// fails with ErrUserExists if an ID belongs to another project. Users created
+// afterwards get IDs above the imported ones.
+func (s *Storage) ImportUsers(ctx context.Context, project string, ids []int64) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ImportUsers")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (int64, error) {
+		return 0, fmt.Errorf("storage.postgres.ImportUsers: %s: %w", msg, err)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `
+		INSERT INTO users(id, project_slug)
+		SELECT DISTINCT unnest($2::bigint[]), $1
+		ON CONFLICT (id) DO NOTHING
+	`, project, ids)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return fail("insert users", &storage.ErrProjectNotFound{Slug: project})
+		}
+		return fail("insert users", err)
+	}
+
+	var foreignID int64
+
+	if err = tx.QueryRow(ctx, `
+		SELECT id
+		FROM users
+		WHERE id = ANY($2)
+		AND project_slug <> $1
+		LIMIT 1
+	`, project, ids).Scan(&foreignID); err == nil {
+		return fail("check users", &storage.ErrUserExists{ID: foreignID})
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fail("check users", err)
+	}
+
+	// IDs were given explicitly, so the sequence has to be moved past them
+	if _, err = tx.Exec(ctx, `
+		SELECT setval(pg_get_serial_sequence('users', 'id'), GREATEST(MAX(id), 1))
+		FROM users
+	`); err != nil {
+		return fail("advance user id sequence", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return res.RowsAffected(), nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, project string, id int64) (int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetUser")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) (int64, error) {
 		return 0, fmt.Errorf("storage.postgres.GetUser: %s: %w", msg, err)
 	}
 
 	var dbID int64
 
-	if err := s.pool.QueryRow(ctx, `
+	if err := s.reader(ctx).QueryRow(ctx, `
 		SELECT id
 		FROM users
-		WHERE id = $1
-	`, id).Scan(&dbID); err != nil {
+		WHERE project_slug = $1
+		AND id = $2
+	`, project, id).Scan(&dbID); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return fail("query user", &storage.ErrUserNotFound{ID: id})
 		}
@@ -54,7 +130,12 @@ func (s *Storage) GetUser(ctx context.Context, id int64) (int64, error) {
 	return dbID, nil
 }
 
-func (s *Storage) GetRandomUsers(ctx context.Context, usersCount int64) ([]int64, error) {
+func (s *Storage) GetRandomUsers(ctx context.Context, project string, usersCount int64) ([]int64, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetRandomUsers")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) ([]int64, error) {
 		return []int64{}, fmt.Errorf("storage.postgres.GetRandomUsers: %s: %w", msg, err)
 	}
@@ -62,9 +143,10 @@ func (s *Storage) GetRandomUsers(ctx context.Context, usersCount int64) ([]int64
 	rows, err := s.pool.Query(ctx, `
 		SELECT id
 		FROM users
+		WHERE project_slug = $1
 		ORDER BY RANDOM()
-		LIMIT $1
-	`, usersCount)
+		LIMIT $2
+	`, project, usersCount)
 	if err != nil {
 		return fail("query users", err)
 	}
@@ -83,49 +165,160 @@ func (s *Storage) GetRandomUsers(ctx context.Context, usersCount int64) ([]int64
 	return users, nil
 }
 
-func (s *Storage) GetUserSegments(ctx context.Context, id int64) ([]string, error) {
-	fail := func(msg string, err error) ([]string, error) {
-		return []string{}, fmt.Errorf("storage.postgres.GetUserSegments: %s: %w", msg, err)
+func (s *Storage) GetUserSegments(ctx context.Context, project string, id int64) ([]string, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetUserSegments")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var segments []string
+	var err error
+
+	// Reads that must see recent writes skip the cache, whose entries may
+	// be up to a TTL old
+	if s.userSegments == nil || storage.PrimaryRequired(ctx) {
+		segments, _, err = s.loadUserSegments(ctx, project, id)
+	} else {
+		// The cache is only filled from the primary database. A lagging
+		// replica would have it serve stale segments for a whole TTL, past
+		// the read-your-writes window and invalidation.
+		segments, err = s.userSegments.Get(ctx, project, id, func(ctx context.Context) ([]string, time.Time, error) {
+			return s.loadUserSegments(storage.WithPrimary(ctx), project, id)
+		})
+	}
+	if err != nil {
+		return []string{}, fmt.Errorf("storage.postgres.GetUserSegments: %w", err)
+	}
+
+	return segments, nil
+}
+
+// loadUserSegments reads the active segments of the user and when the
+// earliest of them expires.
+func (s *Storage) loadUserSegments(ctx context.Context, project string, id int64) ([]string, time.Time, error) {
+	fail := func(msg string, err error) ([]string, time.Time, error) {
+		return []string{}, time.Time{}, fmt.Errorf("load user segments: %s: %w", msg, err)
 	}
 
-	dbID, err := s.GetUser(ctx, id)
+	dbID, err := s.GetUser(ctx, project, id)
 	if err != nil {
 		return fail("get user", err)
 	}
 
-	rows, err := s.pool.Query(ctx, `
-		SELECT segment_slug
+	// The expiry is computed by the database, which compares expire_at with
+	// its own clock
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT segment_slug, EXTRACT(EPOCH FROM expire_at - LOCALTIMESTAMP)::float8
 		FROM users_segments
-		WHERE users_segments.user_id = $1
+		WHERE users_segments.project_slug = $1
+		AND users_segments.user_id = $2
 		AND (
 			users_segments.expire_at IS NULL
 			OR users_segments.expire_at > NOW()
 		)
-	`, dbID)
+	`, project, dbID)
 	if err != nil {
 		return fail("query user segments", err)
 	}
 	defer rows.Close()
 
+	now := time.Now()
 	segments := []string{}
+	var expireAt time.Time
 
 	for rows.Next() {
 		var segment string
-		if err = rows.Scan(&segment); err != nil {
+		var expiresIn *float64
+		if err = rows.Scan(&segment, &expiresIn); err != nil {
 			return fail("scan user segments", err)
 		}
 		segments = append(segments, segment)
+
+		if expiresIn != nil {
+			at := now.Add(time.Duration(*expiresIn * float64(time.Second)))
+			if expireAt.IsZero() || at.Before(expireAt) {
+				expireAt = at
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fail("iterate user segments", err)
 	}
 
-	return segments, nil
+	return segments, expireAt, nil
+}
+
+// GetUsersSegments returns the active segments of the users with the given
+// IDs in a single query. Users that do not exist in the project are left out
+// of the result.
+func (s *Storage) GetUsersSegments(ctx context.Context, project string, ids []int64) (map[int64][]string, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetUsersSegments")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (map[int64][]string, error) {
+		return map[int64][]string{}, fmt.Errorf("storage.postgres.GetUsersSegments: %s: %w", msg, err)
+	}
+
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT
+			users.id,
+			COALESCE(
+				array_agg(users_segments.segment_slug ORDER BY users_segments.segment_slug)
+					FILTER (WHERE users_segments.segment_slug IS NOT NULL),
+				'{}'
+			)
+		FROM users
+		LEFT JOIN users_segments
+			ON users_segments.user_id = users.id
+			AND users_segments.project_slug = users.project_slug
+			AND (
+				users_segments.expire_at IS NULL
+				OR users_segments.expire_at > NOW()
+			)
+		WHERE users.project_slug = $1
+		AND users.id = ANY($2)
+		GROUP BY users.id
+	`, project, ids)
+	if err != nil {
+		return fail("query users segments", err)
+	}
+	defer rows.Close()
+
+	users := map[int64][]string{}
+
+	for rows.Next() {
+		var id int64
+		var segments []string
+		if err = rows.Scan(&id, &segments); err != nil {
+			return fail("scan users segments", err)
+		}
+		users[id] = segments
+	}
+	if err = rows.Err(); err != nil {
+		return fail("iterate users segments", err)
+	}
+
+	return users, nil
 }
 
 func (s *Storage) UpdateUserSegments(
 	ctx context.Context,
+	project string,
 	id int64,
 	segmentsToAdd []models.SegmentToAdd,
 	segmentsToRemove []models.SegmentToRemove,
 ) error {
+	ctx, span := startSpan(ctx, "storage.postgres.UpdateUserSegments")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// The user and segments are looked up outside of the transaction, they
+	// must not come from a lagging replica
+	ctx = storage.WithPrimary(ctx)
+
 	fail := func(msg string, err error) error {
 		return fmt.Errorf("storage.postgres.UpdateUserSegments: %s: %w", msg, err)
 	}
@@ -136,16 +329,122 @@ func (s *Storage) UpdateUserSegments(
 	}
 	defer tx.Rollback(ctx)
 
-	userID, err := s.GetUser(ctx, id)
+	if _, _, err = s.updateUserSegments(ctx, tx, project, id, segmentsToAdd, segmentsToRemove, nil); err != nil {
+		return fail("update user segments", err)
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
-		return fail("get user", err)
+		return fail("commit transaction", err)
+	}
+
+	s.userSegments.InvalidateUser(ctx, project, id)
+
+	return nil
+}
+
+// PreviewUserSegmentsUpdate updates the user segments in a transaction that
+// is rolled back, and reports what the update would do. Segments that can
+// not be added or removed are reported as conflicts instead of failing.
+func (s *Storage) PreviewUserSegmentsUpdate(
+	ctx context.Context,
+	project string,
+	id int64,
+	segmentsToAdd []models.SegmentToAdd,
+	segmentsToRemove []models.SegmentToRemove,
+) (models.Preview, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.PreviewUserSegmentsUpdate")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	ctx = storage.WithPrimary(ctx)
+
+	fail := func(msg string, err error) (models.Preview, error) {
+		return models.Preview{}, fmt.Errorf("storage.postgres.PreviewUserSegmentsUpdate: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	preview := newPreview()
+
+	added, removed, err := s.updateUserSegments(ctx, tx, project, id, segmentsToAdd, segmentsToRemove, &preview.Conflicts)
+	if err != nil {
+		return fail("update user segments", err)
+	}
+
+	preview.Added = int64(len(added.users))
+	preview.Removed = int64(len(removed.users))
+	if preview.Added+preview.Removed > 0 {
+		addAffectedUsers(&preview, id)
+	}
+
+	slugs := []string{}
+	for _, segment := range segmentsToAdd {
+		slugs = append(slugs, segment.Slug)
+	}
+	for _, segment := range segmentsToRemove {
+		slugs = append(slugs, segment.Slug)
+	}
+
+	if preview.SegmentSizes, err = segmentSizes(ctx, tx, project, slugs); err != nil {
+		return fail("count segment sizes", err)
+	}
+
+	return preview, nil
+}
+
+// updateUserSegments adds and removes the user segments in tx. If conflicts
+// is not nil, segments that are missing, already added or not added yet are
+// reported to it and skipped, otherwise they fail the update.
+func (s *Storage) updateUserSegments(
+	ctx context.Context,
+	tx pgx.Tx,
+	project string,
+	id int64,
+	segmentsToAdd []models.SegmentToAdd,
+	segmentsToRemove []models.SegmentToRemove,
+	conflicts *[]string,
+) (added, removed membershipChanges, err error) {
+	// conflict records err if it is a conflict and conflicts are collected
+	conflict := func(err error) bool {
+		var errSegmentNotFound *storage.ErrSegmentNotFound
+		var errUserSegmentExists *storage.ErrUserSegmentExists
+		var errUserSegmentNotFound *storage.ErrUserSegmentNotFound
+
+		if conflicts == nil {
+			return false
+		}
+		switch {
+		case errors.As(err, &errSegmentNotFound):
+			*conflicts = append(*conflicts, errSegmentNotFound.Error())
+		case errors.As(err, &errUserSegmentExists):
+			*conflicts = append(*conflicts, errUserSegmentExists.Error())
+		case errors.As(err, &errUserSegmentNotFound):
+			*conflicts = append(*conflicts, errUserSegmentNotFound.Error())
+		default:
+			return false
+		}
+		return true
+	}
+
+	userID, err := s.GetUser(ctx, project, id)
+	if err != nil {
+		return added, removed, fmt.Errorf("get user: %w", err)
 	}
 
 	// Add the segments to the user
 	for _, segmentToAdd := range segmentsToAdd {
-		segment, err := s.GetSegment(ctx, segmentToAdd.Slug)
+		segment, err := s.GetSegment(ctx, project, segmentToAdd.Slug)
 		if err != nil {
-			return fail("get segment to add", err)
+			if conflict(err) {
+				continue
+			}
+			return added, removed, fmt.Errorf("get segment to add: %w", err)
 		}
 
 		expireAt := &segmentToAdd.ExpireAt
@@ -153,81 +452,109 @@ func (s *Storage) UpdateUserSegments(
 			expireAt = nil
 		}
 
-		if _, err = s.pool.Exec(ctx, `
-				INSERT INTO users_segments(user_id, segment_slug, expire_at)
-				VALUES($1, $2, $3)
-			`, userID, segment.Slug, expireAt); err != nil {
-			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.UniqueViolation {
-				return fail("insert user segment", &storage.ErrUserSegmentExists{Slug: segment.Slug})
+		// A conflict leaves the transaction usable, so a preview can go on
+		res, err := tx.Exec(ctx, `
+			INSERT INTO users_segments(user_id, project_slug, segment_slug, expire_at)
+			VALUES($1, $2, $3, $4)
+			ON CONFLICT (user_id, segment_slug) DO NOTHING
+		`, userID, project, segment.Slug, expireAt)
+		if err != nil {
+			return added, removed, fmt.Errorf("insert user segment: %w", err)
+		}
+		if res.RowsAffected() == 0 {
+			err := &storage.ErrUserSegmentExists{Slug: segment.Slug}
+			if conflict(err) {
+				continue
 			}
-			return fail("insert user segment", err)
+			return added, removed, fmt.Errorf("insert user segment: %w", err)
 		}
 
-		if _, err = s.pool.Exec(ctx, `
-			INSERT INTO users_segments_history(user_id, segment_slug, operation)
-			VALUES($1, $2, $3)
-		`, userID, segment.Slug, "add"); err != nil {
-			return fail("insert user segment history, add", err)
+		if _, err = tx.Exec(ctx, `
+			INSERT INTO users_segments_history(user_id, project_slug, segment_slug, operation)
+			VALUES($1, $2, $3, $4)
+		`, userID, project, segment.Slug, "add"); err != nil {
+			return added, removed, fmt.Errorf("insert user segment history, add: %w", err)
 		}
+
+		added.add(project, userID, segment.Slug)
 	}
 
 	// Remove the segments from the user
 	for _, segmentToRemove := range segmentsToRemove {
-		segment, err := s.GetSegment(ctx, segmentToRemove.Slug)
+		segment, err := s.GetSegment(ctx, project, segmentToRemove.Slug)
 		if err != nil {
-			return fail("get segment to remove", err)
+			if conflict(err) {
+				continue
+			}
+			return added, removed, fmt.Errorf("get segment to remove: %w", err)
 		}
 
-		res, err := s.pool.Exec(ctx, `
+		res, err := tx.Exec(ctx, `
 			DELETE FROM users_segments
-			WHERE user_id = $1
-			AND segment_slug = $2
-		`, userID, segment.Slug)
+			WHERE project_slug = $1
+			AND user_id = $2
+			AND segment_slug = $3
+		`, project, userID, segment.Slug)
 		if err != nil {
-			return fail("delete user segment", err)
+			return added, removed, fmt.Errorf("delete user segment: %w", err)
 		}
 
 		if res.RowsAffected() == 0 {
-			return fail("rows affected", &storage.ErrUserSegmentNotFound{Slug: segment.Slug})
+			err := &storage.ErrUserSegmentNotFound{Slug: segment.Slug}
+			if conflict(err) {
+				continue
+			}
+			return added, removed, fmt.Errorf("rows affected: %w", err)
 		}
 
-		_, err = s.pool.Exec(ctx, `
-			INSERT INTO users_segments_history(user_id, segment_slug, operation)
-			VALUES($1, $2, $3)
-		`, userID, segment.Slug, "remove")
+		_, err = tx.Exec(ctx, `
+			INSERT INTO users_segments_history(user_id, project_slug, segment_slug, operation)
+			VALUES($1, $2, $3, $4)
+		`, userID, project, segment.Slug, "remove")
 		if err != nil {
-			return fail("insert user segment history, remove", err)
+			return added, removed, fmt.Errorf("insert user segment history, remove: %w", err)
 		}
+
+		removed.add(project, userID, segment.Slug)
 	}
 
-	err = tx.Commit(ctx)
-	if err != nil {
-		return fail("commit transaction", err)
+	if err = writeMembershipEvents(ctx, tx, models.EventUserAdded, models.ReasonManual, added); err != nil {
+		return added, removed, fmt.Errorf("write membership events, add: %w", err)
+	}
+	if err = writeMembershipEvents(ctx, tx, models.EventUserRemoved, models.ReasonManual, removed); err != nil {
+		return added, removed, fmt.Errorf("write membership events, remove: %w", err)
 	}
 
-	return nil
+	return added, removed, nil
 }
 
 func (s *Storage) GetUserSegmentsHistory(
 	ctx context.Context,
+	project string,
 	id int64,
 	period time.Time,
 ) ([][]string, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetUserSegmentsHistory")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	fail := func(msg string, err error) ([][]string, error) {
 		return [][]string{}, fmt.Errorf("storage.postgres.GetUserSegmentsHistory: %s: %w", msg, err)
 	}
 
-	if _, err := s.GetUser(ctx, id); err != nil {
+	if _, err := s.GetUser(ctx, project, id); err != nil {
 		return fail("get user", err)
 	}
 
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.reader(ctx).Query(ctx, `
 		SELECT user_id, segment_slug, operation, created_at
 		FROM users_segments_history
-		WHERE user_id = $1
-		AND EXTRACT(YEAR FROM created_at) = $2
-		AND EXTRACT(MONTH FROM created_at) = $3
-	`, id, period.Year(), period.Month())
+		WHERE project_slug = $1
+		AND user_id = $2
+		AND EXTRACT(YEAR FROM created_at) = $3
+		AND EXTRACT(MONTH FROM created_at) = $4
+	`, project, id, period.Year(), period.Month())
 	if err != nil {
 		return fail("query history", err)
 	}
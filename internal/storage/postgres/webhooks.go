@@ -0,0 +1,400 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"segmentify/internal/audit"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Storage) CreateWebhook(ctx context.Context, project string, webhook models.Webhook) (models.Webhook, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.CreateWebhook")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.Webhook, error) {
+		return models.Webhook{}, fmt.Errorf("storage.postgres.CreateWebhook: %s: %w", msg, err)
+	}
+
+	if webhook.Segments == nil {
+		webhook.Segments = []string{}
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	webhook.Project = project
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.QueryRow(ctx, `
+		INSERT INTO webhooks(project_slug, url, secret, segments, events)
+		VALUES($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, project, webhook.URL, webhook.Secret, webhook.Segments, webhook.Events).Scan(&webhook.ID, &webhook.CreatedAt); err != nil {
+		return fail("insert webhook with returning", err)
+	}
+
+	audited := webhook
+	audited.Secret = ""
+
+	if err = writeAudit(ctx, tx, project, audit.ActionCreate, audit.EntityWebhook, strconv.FormatInt(webhook.ID, 10), nil, audited); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return webhook, nil
+}
+
+func (s *Storage) ListWebhooks(ctx context.Context, project string) ([]models.Webhook, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ListWebhooks")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.Webhook, error) {
+		return []models.Webhook{}, fmt.Errorf("storage.postgres.ListWebhooks: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, project_slug, url, segments, events, created_at
+		FROM webhooks
+		WHERE project_slug = $1
+		ORDER BY id
+	`, project)
+	if err != nil {
+		return fail("query webhooks", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+
+	for rows.Next() {
+		var w models.Webhook
+		if err := rows.Scan(&w.ID, &w.Project, &w.URL, &w.Segments, &w.Events, &w.CreatedAt); err != nil {
+			return fail("scan webhooks", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate webhooks", err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, project string, id int64) error {
+	ctx, span := startSpan(ctx, "storage.postgres.DeleteWebhook")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.DeleteWebhook: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	webhook := models.Webhook{ID: id, Project: project}
+
+	if err = tx.QueryRow(ctx, `
+		DELETE FROM webhooks
+		WHERE project_slug = $1
+		AND id = $2
+		RETURNING url, segments, events, created_at
+	`, project, id).Scan(&webhook.URL, &webhook.Segments, &webhook.Events, &webhook.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("delete webhook", &storage.ErrWebhookNotFound{ID: id})
+		}
+		return fail("delete webhook", err)
+	}
+
+	if err = writeAudit(ctx, tx, project, audit.ActionDelete, audit.EntityWebhook, strconv.FormatInt(id, 10), webhook, nil); err != nil {
+		return fail("write audit", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return nil
+}
+
+const deliveryColumns = `
+	webhook_deliveries.id, webhook_deliveries.webhook_id, webhook_deliveries.event_id::text,
+	webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.status,
+	webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, webhook_deliveries.last_error,
+	webhook_deliveries.created_at, webhook_deliveries.delivered_at
+`
+
+func scanDelivery(row pgx.Row, extra ...any) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	dest := append([]any{
+		&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status,
+		&d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+	}, extra...)
+	err := row.Scan(dest...)
+	return d, err
+}
+
+func (s *Storage) ListWebhookDeliveries(
+	ctx context.Context,
+	project string,
+	filter models.WebhookDeliveryFilter,
+) ([]models.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ListWebhookDeliveries")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.WebhookDelivery, error) {
+		return []models.WebhookDelivery{}, fmt.Errorf("storage.postgres.ListWebhookDeliveries: %s: %w", msg, err)
+	}
+
+	conds := []string{"webhooks.project_slug = $1"}
+	args := []any{project}
+
+	if filter.WebhookID != 0 {
+		args = append(args, filter.WebhookID)
+		conds = append(conds, "webhook_deliveries.webhook_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conds = append(conds, "webhook_deliveries.status = $"+strconv.Itoa(len(args)))
+	}
+	args = append(args, filter.Limit)
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY webhook_deliveries.id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return fail("query webhook deliveries", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return fail("scan webhook deliveries", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate webhook deliveries", err)
+	}
+
+	return deliveries, nil
+}
+
+// GetWebhookDelivery returns the delivery together with the log of its
+// attempts.
+func (s *Storage) GetWebhookDelivery(ctx context.Context, project string, id int64) (models.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.GetWebhookDelivery")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) (models.WebhookDelivery, error) {
+		return models.WebhookDelivery{}, fmt.Errorf("storage.postgres.GetWebhookDelivery: %s: %w", msg, err)
+	}
+
+	d, err := scanDelivery(s.pool.QueryRow(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+		WHERE webhooks.project_slug = $1
+		AND webhook_deliveries.id = $2
+	`, project, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fail("query webhook delivery", &storage.ErrWebhookDeliveryNotFound{ID: id})
+		}
+		return fail("query webhook delivery", err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT attempted_at, duration_ms, response_status, error
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return fail("query webhook delivery attempts", err)
+	}
+	defer rows.Close()
+
+	d.AttemptsLog = []models.WebhookAttempt{}
+
+	for rows.Next() {
+		var a models.WebhookAttempt
+		if err := rows.Scan(&a.AttemptedAt, &a.DurationMS, &a.ResponseStatus, &a.Error); err != nil {
+			return fail("scan webhook delivery attempts", err)
+		}
+		d.AttemptsLog = append(d.AttemptsLog, a)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate webhook delivery attempts", err)
+	}
+
+	return d, nil
+}
+
+// RetryWebhookDelivery moves a dead delivery back to the queue with a fresh
+// set of attempts.
+func (s *Storage) RetryWebhookDelivery(ctx context.Context, project string, id int64) error {
+	ctx, span := startSpan(ctx, "storage.postgres.RetryWebhookDelivery")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.RetryWebhookDelivery: %s: %w", msg, err)
+	}
+
+	res, err := s.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		FROM webhooks
+		WHERE webhooks.id = webhook_deliveries.webhook_id
+		AND webhooks.project_slug = $1
+		AND webhook_deliveries.id = $2
+		AND webhook_deliveries.status = 'dead'
+	`, project, id)
+	if err != nil {
+		return fail("update webhook delivery", err)
+	}
+
+	if res.RowsAffected() == 0 {
+		return fail("rows affected", &storage.ErrWebhookDeliveryNotFound{ID: id})
+	}
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit due deliveries of all projects
+// in order and postpones them by lease, so concurrent dispatchers skip them
+// while they are sent. A delivery whose dispatcher died is claimed again once
+// the lease expires.
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, limit int64, lease time.Duration) ([]models.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "storage.postgres.ClaimWebhookDeliveries")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) ([]models.WebhookDelivery, error) {
+		return []models.WebhookDelivery{}, fmt.Errorf("storage.postgres.ClaimWebhookDeliveries: %s: %w", msg, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM webhooks
+		WHERE webhooks.id = webhook_deliveries.webhook_id
+		AND webhook_deliveries.id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending'
+			AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns+`, webhooks.url, webhooks.secret
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return fail("claim webhook deliveries", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return fail("scan webhook deliveries", err)
+		}
+		d.URL, d.Secret = url, secret
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate webhook deliveries", err)
+	}
+
+	return deliveries, nil
+}
+
+// FinishWebhookDelivery logs an attempt and moves the delivery to status. A
+// pending delivery is attempted again at nextAttemptAt.
+func (s *Storage) FinishWebhookDelivery(
+	ctx context.Context,
+	id int64,
+	attempt models.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	ctx, span := startSpan(ctx, "storage.postgres.FinishWebhookDelivery")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fail := func(msg string, err error) error {
+		return fmt.Errorf("storage.postgres.FinishWebhookDelivery: %s: %w", msg, err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fail("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts(delivery_id, attempted_at, duration_ms, response_status, error)
+		VALUES($1, $2, $3, $4, $5)
+	`, id, attempt.AttemptedAt, attempt.DurationMS, attempt.ResponseStatus, attempt.Error); err != nil {
+		return fail("insert webhook delivery attempt", err)
+	}
+
+	if _, err = tx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = attempts + 1,
+			next_attempt_at = $3,
+			last_error = $4,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1
+	`, id, status, nextAttemptAt, attempt.Error); err != nil {
+		return fail("update webhook delivery", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fail("commit transaction", err)
+	}
+
+	return nil
+}
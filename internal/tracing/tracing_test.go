@@ -0,0 +1,113 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"segmentify/internal/config"
+	mwTracing "segmentify/internal/httpserver/middleware/tracing"
+	"segmentify/internal/lib/logger/handlers/slogtrace"
+	"segmentify/internal/tracing"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+// collector stands in for an OTLP/HTTP collector and keeps received span names by trace ID.
+type collector struct {
+	mu    sync.Mutex
+	spans map[string][]string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				id := hex.EncodeToString(span.TraceId)
+				c.spans[id] = append(c.spans[id], span.Name)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestTracing(t *testing.T) {
+	col := &collector{spans: map[string][]string{}}
+	colServer := httptest.NewServer(col)
+	defer colServer.Close()
+
+	shutdown, err := tracing.Setup(context.Background(), config.Tracing{
+		OTLPEndpoint: colServer.URL,
+		ServiceName:  "segmentify-test",
+		SampleRatio:  1,
+	})
+	require.NoError(t, err)
+
+	// Downstream service that records the propagated trace context
+	var downstreamTraceparent string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamTraceparent = r.Header.Get("traceparent")
+	}))
+	defer downstream.Close()
+
+	logs := new(bytes.Buffer)
+	log := slog.New(slogtrace.NewTraceHandler(slog.NewJSONHandler(logs, nil)))
+	client := &http.Client{Transport: &tracing.Transport{}}
+
+	router := chi.NewRouter()
+	router.Use(mwTracing.New())
+	router.Get("/segments/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		log.InfoContext(r.Context(), "handling request")
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/segments/WOW", nil)
+	req.Header.Set("traceparent", traceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	require.Contains(t, col.spans[traceID], "GET /segments/{slug}")
+	require.True(t, strings.HasPrefix(downstreamTraceparent, "00-"+traceID+"-"))
+	require.Contains(t, logs.String(), `"trace_id":"`+traceID+`"`)
+}
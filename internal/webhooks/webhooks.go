@@ -0,0 +1,244 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/sl"
+	"segmentify/internal/models"
+	"segmentify/internal/tracing"
+)
+
+// Headers of webhook deliveries. The event ID stays the same across retries,
+// so receivers can use it to drop duplicates.
+const (
+	HeaderEvent     = "X-Segmentify-Event"
+	HeaderEventID   = "X-Segmentify-Event-Id"
+	HeaderTimestamp = "X-Segmentify-Timestamp"
+	HeaderSignature = "X-Segmentify-Signature"
+)
+
+// maxErrorBody bounds how much of a failed response is kept in the log.
+const maxErrorBody = 512
+
+type Storage interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int64, lease time.Duration) ([]models.WebhookDelivery, error)
+	FinishWebhookDelivery(
+		ctx context.Context,
+		id int64,
+		attempt models.WebhookAttempt,
+		status string,
+		nextAttemptAt time.Time,
+	) error
+}
+
+// Sign returns the signature of a delivery body sent at timestamp: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed by the webhook secret, prefixed
+// with "sha256=". Including the timestamp lets receivers reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret returns a random secret for webhooks created without one.
+func GenerateSecret() (string, error) {
+	const op = "webhooks.GenerateSecret"
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("%s: read random bytes: %w", op, err)
+	}
+
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Verify reports whether signature is valid for body sent at timestamp.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff returns the delay before the attempt following the given number of
+// failed attempts: base doubled for every failure and capped at max.
+func Backoff(failed int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < failed; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return min(d, max)
+}
+
+type Dispatcher struct {
+	log     *slog.Logger
+	storage Storage
+	cfg     config.Webhooks
+	client  *http.Client
+	now     func() time.Time
+}
+
+func New(log *slog.Logger, storage Storage, cfg config.Webhooks) *Dispatcher {
+	return &Dispatcher{
+		log:     log.With(slog.String("component", "webhooks")),
+		storage: storage,
+		cfg:     cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &tracing.Transport{},
+		},
+		now: time.Now,
+	}
+}
+
+// Run dispatches due deliveries every poll interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Drain the queue before waiting for the next tick
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil {
+				d.log.Error("failed to dispatch webhooks", sl.Err(err))
+				break
+			}
+			if int64(n) < d.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
+
+// Dispatch sends one batch of due deliveries and returns its size.
+// Deliveries to the same webhook are sent one by one in order; different
+// webhooks are served concurrently, so a slow receiver does not hold up the
+// others.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	const op = "webhooks.Dispatcher.Dispatch"
+
+	// The lease outlives a full attempt, so a delivery is only claimed again
+	// if this dispatcher died while sending it.
+	lease := 2*d.cfg.Timeout + time.Second
+
+	deliveries, err := d.storage.ClaimWebhookDeliveries(ctx, d.cfg.BatchSize, lease)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+
+	byWebhook := map[int64][]models.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		byWebhook[delivery.WebhookID] = append(byWebhook[delivery.WebhookID], delivery)
+	}
+
+	var wg sync.WaitGroup
+
+	for _, queue := range byWebhook {
+		wg.Add(1)
+		go func(queue []models.WebhookDelivery) {
+			defer wg.Done()
+			for _, delivery := range queue {
+				d.deliver(ctx, delivery)
+			}
+		}(queue)
+	}
+
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	log := d.log.With(
+		slog.Int64("delivery_id", delivery.ID),
+		slog.Int64("webhook_id", delivery.WebhookID),
+	)
+
+	attempt := d.send(ctx, delivery)
+
+	status, next := models.DeliveryDelivered, d.now()
+	if attempt.Error != "" {
+		failed := delivery.Attempts + 1
+		if failed >= d.cfg.MaxAttempts {
+			status = models.DeliveryDead
+			log.Warn("webhook delivery is dead", slog.Int("attempts", failed), slog.String("error", attempt.Error))
+		} else {
+			status = models.DeliveryPending
+			next = next.Add(Backoff(failed, d.cfg.RetryBase, d.cfg.RetryMax))
+			log.Debug("webhook delivery failed", slog.Int("attempts", failed), slog.String("error", attempt.Error))
+		}
+	}
+
+	// Record the outcome even if the dispatcher is stopping, otherwise the
+	// delivery would be sent again after its lease
+	if err := d.storage.FinishWebhookDelivery(context.WithoutCancel(ctx), delivery.ID, attempt, status, next); err != nil {
+		log.Error("failed to finish webhook delivery", sl.Err(err))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (attempt models.WebhookAttempt) {
+	start := d.now()
+	attempt.AttemptedAt = start
+	defer func() {
+		attempt.DurationMS = d.now().Sub(start).Milliseconds()
+	}()
+
+	ctx, span := tracing.Tracer().Start(ctx, "webhooks.deliver")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	timestamp := start.Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "segmentify-webhooks")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.ResponseStatus = resp.StatusCode
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		attempt.Error = fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return attempt
+}
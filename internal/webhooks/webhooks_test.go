@@ -0,0 +1,154 @@
+package webhooks_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/config"
+	"segmentify/internal/lib/logger/handlers/slogdiscard"
+	"segmentify/internal/models"
+	"segmentify/internal/webhooks"
+)
+
+type finished struct {
+	attempt models.WebhookAttempt
+	status  string
+	next    time.Time
+}
+
+type fakeStorage struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+	finished   map[int64]finished
+}
+
+func (f *fakeStorage) ClaimWebhookDeliveries(_ context.Context, limit int64, _ time.Duration) ([]models.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := min(int(limit), len(f.deliveries))
+	claimed := f.deliveries[:n]
+	f.deliveries = f.deliveries[n:]
+
+	return claimed, nil
+}
+
+func (f *fakeStorage) FinishWebhookDelivery(
+	_ context.Context,
+	id int64,
+	attempt models.WebhookAttempt,
+	status string,
+	next time.Time,
+) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.finished[id] = finished{attempt: attempt, status: status, next: next}
+
+	return nil
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"segment.user_added"}`)
+	signature := webhooks.Sign("secret", 1694500000, body)
+
+	require.True(t, webhooks.Verify("secret", 1694500000, body, signature))
+	require.False(t, webhooks.Verify("other", 1694500000, body, signature))
+	require.False(t, webhooks.Verify("secret", 1694500001, body, signature))
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 10*time.Second, time.Minute
+
+	require.Equal(t, 10*time.Second, webhooks.Backoff(1, base, max))
+	require.Equal(t, 20*time.Second, webhooks.Backoff(2, base, max))
+	require.Equal(t, 40*time.Second, webhooks.Backoff(3, base, max))
+	require.Equal(t, time.Minute, webhooks.Backoff(4, base, max))
+	require.Equal(t, time.Minute, webhooks.Backoff(40, base, max))
+}
+
+func TestDispatch(t *testing.T) {
+	const secret = "whsec"
+
+	var mu sync.Mutex
+	var received []string
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		require.True(t, webhooks.Verify(secret, timestamp, body, r.Header.Get(webhooks.HeaderSignature)))
+		require.Equal(t, models.EventUserAdded, r.Header.Get(webhooks.HeaderEvent))
+
+		mu.Lock()
+		received = append(received, r.Header.Get(webhooks.HeaderEventID))
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	delivery := func(id, webhookID int64, url string, attempts int) models.WebhookDelivery {
+		return models.WebhookDelivery{
+			ID:        id,
+			WebhookID: webhookID,
+			EventID:   "event-" + strconv.FormatInt(id, 10),
+			EventType: models.EventUserAdded,
+			Payload:   []byte(`{"user_id":1}`),
+			Attempts:  attempts,
+			URL:       url,
+			Secret:    secret,
+		}
+	}
+
+	storage := &fakeStorage{
+		deliveries: []models.WebhookDelivery{
+			delivery(1, 1, receiver.URL, 0),
+			delivery(2, 2, failing.URL, 0),
+			delivery(3, 1, receiver.URL, 0),
+			delivery(4, 2, failing.URL, 2),
+		},
+		finished: map[int64]finished{},
+	}
+
+	dispatcher := webhooks.New(slogdiscard.NewDiscardLogger(), storage, config.Webhooks{
+		BatchSize:   10,
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		RetryBase:   time.Minute,
+		RetryMax:    time.Hour,
+	})
+
+	start := time.Now()
+	n, err := dispatcher.Dispatch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	// Deliveries to one webhook keep their order
+	require.Equal(t, []string{"event-1", "event-3"}, received)
+	require.Equal(t, models.DeliveryDelivered, storage.finished[1].status)
+	require.Equal(t, http.StatusOK, storage.finished[1].attempt.ResponseStatus)
+
+	// A failed delivery is retried after a backoff
+	retried := storage.finished[2]
+	require.Equal(t, models.DeliveryPending, retried.status)
+	require.Equal(t, http.StatusServiceUnavailable, retried.attempt.ResponseStatus)
+	require.Contains(t, retried.attempt.Error, "try later")
+	require.WithinDuration(t, start.Add(time.Minute), retried.next, 5*time.Second)
+
+	// The last failed attempt makes the delivery dead
+	require.Equal(t, models.DeliveryDead, storage.finished[4].status)
+}
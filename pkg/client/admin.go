@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	createAPIKey "segmentify/internal/httpserver/handlers/apikeys/create"
+	listAudit "segmentify/internal/httpserver/handlers/audit/list"
+	"segmentify/internal/models"
+)
+
+// Projects, the audit log, jobs and API keys are global. Their methods ignore
+// the project of the client and need a key without a project.
+
+func (c *Client) CreateProject(ctx context.Context, project models.Project) (models.Project, error) {
+	var created models.Project
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/projects",
+		body:   project,
+		out:    &created,
+	})
+	return created, err
+}
+
+func (c *Client) ListProjects(ctx context.Context) ([]models.Project, error) {
+	var projects []models.Project
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/projects",
+		out:    &projects,
+	})
+	return projects, err
+}
+
+func (c *Client) DeleteProject(ctx context.Context, slug string) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/projects/" + url.PathEscape(slug),
+	})
+}
+
+// ListAuditLog returns a page of the audit log, latest entries first. Pass
+// NextCursor of the response as the cursor of the filter to get the next page.
+func (c *Client) ListAuditLog(ctx context.Context, filter models.AuditFilter) (listAudit.Response, error) {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"project":   filter.Project,
+		"actor":     filter.Actor,
+		"action":    filter.Action,
+		"entity":    filter.Entity,
+		"entity_id": filter.EntityID,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+	if filter.Cursor != 0 {
+		query.Set("cursor", strconv.FormatInt(filter.Cursor, 10))
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.FormatInt(filter.Limit, 10))
+	}
+
+	var page listAudit.Response
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/audit",
+		query:  query,
+		out:    &page,
+	})
+	return page, err
+}
+
+func (c *Client) ListJobs(ctx context.Context) ([]models.Job, error) {
+	var jobs []models.Job
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/admin/jobs",
+		out:    &jobs,
+	})
+	return jobs, err
+}
+
+// RunJob runs the job now and returns its run.
+func (c *Client) RunJob(ctx context.Context, name string) (models.JobRun, error) {
+	var run models.JobRun
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/admin/jobs/" + url.PathEscape(name) + "/run",
+		out:    &run,
+	})
+	return run, err
+}
+
+// CreateAPIKey returns the new key with its plain value, which is not
+// returned again.
+func (c *Client) CreateAPIKey(ctx context.Context, key createAPIKey.Request) (createAPIKey.Response, error) {
+	var created createAPIKey.Response
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/admin/api-keys",
+		body:   key,
+		out:    &created,
+	})
+	return created, err
+}
+
+func (c *Client) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/admin/api-keys",
+		out:    &keys,
+	})
+	return keys, err
+}
+
+func (c *Client) RevokeAPIKey(ctx context.Context, id int64) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/admin/api-keys/" + strconv.FormatInt(id, 10),
+	})
+}
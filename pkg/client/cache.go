@@ -0,0 +1,353 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"segmentify/internal/models"
+)
+
+// Refresh modes of the cache.
+const (
+	// RefreshStream follows the event stream of the project.
+	RefreshStream = "stream"
+	// RefreshPoll polls the changes of the project, for networks that do not
+	// pass long-lived streams.
+	RefreshPoll = "poll"
+)
+
+const (
+	defaultCacheTTL      = time.Minute
+	defaultCacheMaxUsers = 10000
+	defaultPollInterval  = 5 * time.Second
+	pollBatchSize        = 1000
+)
+
+// CacheConfig configures the cache. Zero values are replaced by defaults.
+type CacheConfig struct {
+	// TTL is how long the segments of a user are served without asking the
+	// server. Change events keep them up to date meanwhile, the TTL bounds
+	// the staleness if an event is missed.
+	TTL time.Duration
+	// MaxUsers bounds the cached users, the least recently used are evicted.
+	MaxUsers int
+	// Refresh is RefreshStream or RefreshPoll, RefreshStream by default.
+	Refresh string
+	// PollInterval is how often changes are polled. A failed stream is
+	// reopened after it too.
+	PollInterval time.Duration
+	// OnError, if set, is called with the errors of refreshing the cache.
+	OnError func(error)
+}
+
+// Cache evaluates segment membership locally. It keeps the segments of the
+// users it was asked about and applies membership changes of the project as
+// they happen, so most lookups do not reach the server. If the server cannot
+// be reached, the last known segments of the user are returned.
+//
+//	cache := client.NewCache(c, client.CacheConfig{})
+//	go cache.Run(ctx)
+//	ok, err := cache.InSegment(ctx, userID, "AVITO_VOICE_MESSAGES")
+type Cache struct {
+	client *Client
+	cfg    CacheConfig
+
+	mu    sync.Mutex
+	users map[int64]*list.Element
+	lru   *list.List
+	// cursor is the ID of the last applied event. Changes are followed from
+	// it once started is set.
+	cursor  int64
+	started bool
+	// fetches counts the fetches in flight per user, changed keeps the ID of
+	// the last event applied to those users and reset the ID of the last
+	// event applied to every user. A fetch that raced an event is stored
+	// expired, as it may predate the event.
+	fetches map[int64]int
+	changed map[int64]int64
+	reset   int64
+}
+
+type cacheEntry struct {
+	userID    int64
+	segments  []string
+	fetchedAt time.Time
+}
+
+func NewCache(client *Client, cfg CacheConfig) *Cache {
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultCacheTTL
+	}
+	if cfg.MaxUsers == 0 {
+		cfg.MaxUsers = defaultCacheMaxUsers
+	}
+	if cfg.Refresh == "" {
+		cfg.Refresh = RefreshStream
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	return &Cache{
+		client:  client,
+		cfg:     cfg,
+		users:   map[int64]*list.Element{},
+		lru:     list.New(),
+		fetches: map[int64]int{},
+		changed: map[int64]int64{},
+	}
+}
+
+// UserSegments returns the slugs of the user's segments.
+func (c *Cache) UserSegments(ctx context.Context, userID int64) ([]string, error) {
+	c.mu.Lock()
+	if el, ok := c.users[userID]; ok {
+		c.lru.MoveToFront(el)
+		entry := el.Value.(*cacheEntry)
+		if time.Since(entry.fetchedAt) < c.cfg.TTL {
+			segments := slices.Clone(entry.segments)
+			c.mu.Unlock()
+			return segments, nil
+		}
+	}
+	since := c.cursor
+	c.fetches[userID]++
+	c.mu.Unlock()
+
+	user, err := c.client.GetUserSegments(ctx, userID)
+	if err != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.fetched(userID)
+
+		el, ok := c.users[userID]
+		if !ok {
+			return nil, err
+		}
+		if !unavailable(err) {
+			c.lru.Remove(el)
+			delete(c.users, userID)
+			return nil, err
+		}
+		return slices.Clone(el.Value.(*cacheEntry).segments), nil
+	}
+
+	return c.store(userID, user.Segments, since), nil
+}
+
+// InSegment reports whether the user is in the segment.
+func (c *Cache) InSegment(ctx context.Context, userID int64, slug string) (bool, error) {
+	segments, err := c.UserSegments(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(segments, slug), nil
+}
+
+// Run applies the changes of the project to the cache until ctx is done.
+func (c *Cache) Run(ctx context.Context) {
+	for {
+		var err error
+		if c.cfg.Refresh == RefreshPoll {
+			err = c.poll(ctx)
+		} else {
+			err = c.stream(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if c.cfg.OnError != nil {
+			c.cfg.OnError(err)
+		}
+
+		if !sleep(ctx, c.cfg.PollInterval) {
+			return
+		}
+	}
+}
+
+func (c *Cache) stream(ctx context.Context) error {
+	cursor, err := c.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.client.StreamEvents(ctx, EventsFilter{LastEventID: cursor, Resume: true}, func(event models.OutboxEvent) error {
+		c.apply(event)
+		return nil
+	})
+}
+
+func (c *Cache) poll(ctx context.Context) error {
+	cursor, err := c.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		changes, err := c.client.GetChanges(ctx, cursor, pollBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range changes.Events {
+			c.apply(event)
+		}
+		cursor = changes.LastEventID
+
+		if len(changes.Events) == pollBatchSize {
+			continue
+		}
+		if !sleep(ctx, c.cfg.PollInterval) {
+			return ctx.Err()
+		}
+	}
+}
+
+// start returns the cursor to follow changes from. The first time it is the
+// latest event, earlier changes are in the segments fetched from now on.
+func (c *Cache) start(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	if c.started {
+		defer c.mu.Unlock()
+		return c.cursor, nil
+	}
+	c.mu.Unlock()
+
+	cursor, err := c.client.GetLastEventID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cursor = cursor
+	c.started = true
+
+	return cursor, nil
+}
+
+func (c *Cache) apply(event models.OutboxEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cursor = event.ID
+
+	switch event.Type {
+	case models.EventUserAdded, models.EventUserRemoved:
+		var membership models.MembershipEvent
+		if err := json.Unmarshal(event.Payload, &membership); err != nil {
+			return
+		}
+
+		if c.fetches[membership.UserID] > 0 {
+			c.changed[membership.UserID] = event.ID
+		}
+
+		el, ok := c.users[membership.UserID]
+		if !ok {
+			return
+		}
+		entry := el.Value.(*cacheEntry)
+
+		if event.Type == models.EventUserAdded {
+			if !slices.Contains(entry.segments, membership.Segment) {
+				entry.segments = append(entry.segments, membership.Segment)
+				slices.Sort(entry.segments)
+			}
+		} else {
+			entry.segments = deleteSegment(entry.segments, membership.Segment)
+		}
+
+	case models.EventSegmentDeleted:
+		var segment models.SegmentEvent
+		if err := json.Unmarshal(event.Payload, &segment); err != nil {
+			return
+		}
+
+		c.reset = event.ID
+		for _, el := range c.users {
+			entry := el.Value.(*cacheEntry)
+			entry.segments = deleteSegment(entry.segments, segment.Segment)
+		}
+
+	case models.EventSnapshotImported:
+		// Any user may have changed, they are fetched again
+		c.reset = event.ID
+		c.users = map[int64]*list.Element{}
+		c.lru.Init()
+	}
+}
+
+// store caches the segments of the user fetched at cursor since and returns
+// them sorted, the way they are kept. If an event for the user was applied
+// meanwhile the entry is stored expired: it is fetched again on the next
+// lookup and only serves as the last known state until then.
+func (c *Cache) store(userID int64, segments []string, since int64) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stale := c.changed[userID] > since || c.reset > since
+	c.fetched(userID)
+
+	entry := &cacheEntry{
+		userID:    userID,
+		segments:  slices.Clone(segments),
+		fetchedAt: time.Now(),
+	}
+	if stale {
+		entry.fetchedAt = time.Time{}
+	}
+	slices.Sort(entry.segments)
+
+	if el, ok := c.users[userID]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return slices.Clone(entry.segments)
+	}
+
+	c.users[userID] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.cfg.MaxUsers {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.users, oldest.Value.(*cacheEntry).userID)
+	}
+
+	return slices.Clone(entry.segments)
+}
+
+// fetched ends a fetch of the user's segments. c.mu must be held.
+func (c *Cache) fetched(userID int64) {
+	c.fetches[userID]--
+	if c.fetches[userID] > 0 {
+		return
+	}
+	delete(c.fetches, userID)
+	delete(c.changed, userID)
+}
+
+func deleteSegment(segments []string, slug string) []string {
+	return slices.DeleteFunc(segments, func(s string) bool {
+		return s == slug
+	})
+}
+
+// unavailable reports whether err means the server could not answer, rather
+// than that it rejected the request.
+func unavailable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+}
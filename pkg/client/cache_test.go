@@ -0,0 +1,280 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/models"
+	"segmentify/pkg/client"
+)
+
+// eventsStorage serves user 1 in segments A and B and the events added with
+// push. It counts how many times segments were read and keeps the last
+// cursor events were read after. If hold is set, it is called on every read
+// of segments.
+type eventsStorage struct {
+	fakeStorage
+
+	mu     sync.Mutex
+	events []models.OutboxEvent
+	reads  atomic.Int32
+	polls  atomic.Int32
+	after  atomic.Int64
+	hold   func()
+}
+
+func (s *eventsStorage) GetUserSegments(_ context.Context, _ string, _ int64) ([]string, error) {
+	s.reads.Add(1)
+	if s.hold != nil {
+		s.hold()
+	}
+	return []string{"B", "A"}, nil
+}
+
+func (s *eventsStorage) GetEventsAfter(_ context.Context, _ string, afterID, limit int64) ([]models.OutboxEvent, error) {
+	s.polls.Add(1)
+	s.after.Store(afterID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []models.OutboxEvent{}
+	for _, event := range s.events {
+		if event.ID > afterID && int64(len(events)) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *eventsStorage) GetLastEventID(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return int64(len(s.events)), nil
+}
+
+func (s *eventsStorage) push(t *testing.T, eventType string, payload any) {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, models.OutboxEvent{
+		ID:      int64(len(s.events) + 1),
+		Type:    eventType,
+		Project: models.DefaultProject,
+		Payload: data,
+	})
+}
+
+func TestCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := &eventsStorage{}
+	// An event before the cache started is not applied
+	storage.push(t, models.EventUserAdded, models.MembershipEvent{UserID: 1, Segment: "OLD"})
+
+	server := newServerWithStorage(t, storage, nil)
+
+	cache := client.NewCache(newClient(server, "admin"), client.CacheConfig{
+		TTL:          time.Hour,
+		Refresh:      client.RefreshPoll,
+		PollInterval: 10 * time.Millisecond,
+	})
+	go cache.Run(ctx)
+
+	segments, err := cache.UserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B"}, segments)
+
+	// Changes are applied without reading the segments again
+	storage.push(t, models.EventUserAdded, models.MembershipEvent{UserID: 1, Segment: "C"})
+	storage.push(t, models.EventUserRemoved, models.MembershipEvent{UserID: 1, Segment: "A"})
+	storage.push(t, models.EventSegmentDeleted, models.SegmentEvent{Segment: "B"})
+	storage.push(t, models.EventUserAdded, models.MembershipEvent{UserID: 2, Segment: "C"})
+
+	require.Eventually(t, func() bool {
+		segments, err := cache.UserSegments(ctx, 1)
+		return err == nil && len(segments) == 1 && segments[0] == "C"
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(1), storage.reads.Load())
+
+	ok, err := cache.InSegment(ctx, 1, "C")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// An imported snapshot drops the cached users
+	storage.push(t, models.EventSnapshotImported, models.SnapshotEvent{Mode: "replace"})
+
+	require.Eventually(t, func() bool {
+		segments, err := cache.UserSegments(ctx, 1)
+		return err == nil && len(segments) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(2), storage.reads.Load())
+}
+
+func TestCacheFallback(t *testing.T) {
+	ctx := context.Background()
+
+	storage := &eventsStorage{}
+
+	var down atomic.Bool
+	server := newServerWithStorage(t, storage, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	// Every lookup goes to the server, as the TTL is shorter than a request
+	cache := client.NewCache(newClient(server, "admin"), client.CacheConfig{TTL: time.Nanosecond})
+
+	segments, err := cache.UserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B"}, segments)
+
+	down.Store(true)
+
+	// The last known state is served while the server is unavailable
+	segments, err = cache.UserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B"}, segments)
+
+	// Unknown users are not
+	_, err = cache.UserSegments(ctx, 2)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+}
+
+func TestCacheStreamResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := &eventsStorage{}
+
+	// The first connection drops and an event is committed before the cache
+	// reconnects. Later connections replay the events after last_event_id.
+	var connections atomic.Int32
+	server := newServerWithStorage(t, storage, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/events" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+
+			if connections.Add(1) == 1 {
+				storage.push(t, models.EventUserAdded, models.MembershipEvent{UserID: 1, Segment: "C"})
+				return
+			}
+
+			lastEventID := r.URL.Query().Get("last_event_id")
+			if lastEventID != "" {
+				var afterID int64
+				_, err := fmt.Sscan(lastEventID, &afterID)
+				require.NoError(t, err)
+
+				events, err := storage.GetEventsAfter(r.Context(), models.DefaultProject, afterID, 100)
+				require.NoError(t, err)
+				for _, event := range events {
+					fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Payload)
+				}
+				w.(http.Flusher).Flush()
+			}
+			<-r.Context().Done()
+		})
+	})
+
+	cache := client.NewCache(newClient(server, "admin"), client.CacheConfig{
+		TTL:          time.Hour,
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	segments, err := cache.UserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B"}, segments)
+
+	go cache.Run(ctx)
+
+	// The stream resumes from the first event, though none was handled
+	require.Eventually(t, func() bool {
+		segments, err := cache.UserSegments(ctx, 1)
+		return err == nil && len(segments) == 3
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(1), storage.reads.Load())
+}
+
+func TestCacheRacingFetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+
+	// The first read of segments waits until it is released
+	storage := &eventsStorage{hold: func() {
+		once.Do(func() {
+			close(fetching)
+			<-release
+		})
+	}}
+	server := newServerWithStorage(t, storage, nil)
+
+	cache := client.NewCache(newClient(server, "admin"), client.CacheConfig{
+		TTL:          time.Hour,
+		Refresh:      client.RefreshPoll,
+		PollInterval: 10 * time.Millisecond,
+	})
+	go cache.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		return storage.polls.Load() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	fetched := make(chan []string)
+	go func() {
+		segments, err := cache.UserSegments(ctx, 1)
+		require.NoError(t, err)
+		fetched <- segments
+	}()
+	<-fetching
+
+	// The event is applied while the segments are fetched, and is dropped
+	// as the user is not cached yet
+	storage.push(t, models.EventUserAdded, models.MembershipEvent{UserID: 1, Segment: "C"})
+	require.Eventually(t, func() bool {
+		return storage.after.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	close(release)
+	require.Equal(t, []string{"A", "B"}, <-fetched)
+
+	// The fetch may predate the event, so it is not served from the cache
+	_, err := cache.UserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), storage.reads.Load())
+
+	// The next fetch is
+	_, err = cache.UserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), storage.reads.Load())
+}
@@ -0,0 +1,290 @@
+// Package client is the Go client of the segmentify HTTP API.
+//
+//	c := client.New(client.Config{BaseURL: "http://localhost:8080", APIKey: key})
+//	segment, err := c.CreateSegment(ctx, models.Segment{Slug: "AVITO_VOICE_MESSAGES"})
+//
+// Error responses are returned as *APIError. Errors the storage reported are
+// decoded into the typed errors of this package, so they can be checked with
+// errors.As.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	resp "segmentify/internal/lib/response"
+)
+
+// HeaderAPIKey carries the API key of requests.
+const HeaderAPIKey = "X-API-Key"
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryBase  = 100 * time.Millisecond
+	defaultRetryMax   = 5 * time.Second
+)
+
+// Config configures the client. Zero values are replaced by defaults.
+type Config struct {
+	// BaseURL is the address of the API, e.g. "http://localhost:8080".
+	BaseURL string
+	APIKey  string
+	// Project prefixes segment, user, webhook and event routes with
+	// /projects/{project}. The default project is used when it is empty.
+	Project string
+	// Timeout bounds every attempt of a request. Streams are only bounded
+	// by their context.
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt. Negative
+	// disables retries.
+	MaxRetries int
+	// RetryBase is the first retry delay. It doubles on every retry up to
+	// RetryMax.
+	RetryBase  time.Duration
+	RetryMax   time.Duration
+	HTTPClient *http.Client
+}
+
+type Client struct {
+	baseURL    string
+	apiKey     string
+	project    string
+	timeout    time.Duration
+	maxRetries int
+	retryBase  time.Duration
+	retryMax   time.Duration
+	httpClient *http.Client
+}
+
+func New(cfg Config) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:     cfg.APIKey,
+		project:    cfg.Project,
+		timeout:    cfg.Timeout,
+		maxRetries: cfg.MaxRetries,
+		retryBase:  cfg.RetryBase,
+		retryMax:   cfg.RetryMax,
+		httpClient: cfg.HTTPClient,
+	}
+	if c.timeout == 0 {
+		c.timeout = defaultTimeout
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultMaxRetries
+	}
+	if c.retryBase == 0 {
+		c.retryBase = defaultRetryBase
+	}
+	if c.retryMax == 0 {
+		c.retryMax = defaultRetryMax
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c
+}
+
+// WithProject returns a copy of the client addressing another project.
+func (c *Client) WithProject(project string) *Client {
+	clone := *c
+	clone.project = project
+	return &clone
+}
+
+// projectPath prefixes path with the project of the client.
+func (c *Client) projectPath(path string) string {
+	if c.project == "" {
+		return path
+	}
+	return "/projects/" + url.PathEscape(c.project) + path
+}
+
+// request describes a call to the API. Body is encoded as JSON and Out, if
+// not nil, is decoded from the JSON response.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	out    any
+	// raw receives the response body instead of decoding it into out.
+	raw *[]byte
+}
+
+func (c *Client) do(ctx context.Context, r request) error {
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return fmt.Errorf("segmentify: encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.attempt(ctx, r, body)
+		if err == nil {
+			return nil
+		}
+
+		retry, wait := c.shouldRetry(r.method, err, attempt)
+		if !retry {
+			return err
+		}
+
+		if !sleep(ctx, wait) {
+			return err
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, r request, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := c.send(ctx, r.method, r.path, r.query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("segmentify: read response: %w", err)
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return newAPIError(res, data)
+	}
+
+	if r.raw != nil {
+		*r.raw = data
+		return nil
+	}
+	if r.out != nil {
+		if err := json.Unmarshal(data, r.out); err != nil {
+			return fmt.Errorf("segmentify: decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("segmentify: create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set(HeaderAPIKey, c.apiKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("segmentify: %s %s: %w", method, path, err)
+	}
+	return res, nil
+}
+
+func newAPIError(res *http.Response, data []byte) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode}
+
+	var errResp resp.ErrResponse
+	if err := json.Unmarshal(data, &errResp); err == nil && errResp.ErrorText != "" {
+		apiErr.Detail = errResp.ErrorText
+	} else {
+		apiErr.Detail = strings.TrimSpace(string(data))
+	}
+
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	apiErr.Err = decodeError(apiErr.StatusCode, apiErr.Detail)
+
+	return apiErr
+}
+
+// shouldRetry reports whether a failed attempt is retried and after how long.
+// Rate limited requests are always retried, as the server did not handle
+// them. Network errors and unavailable servers are only retried for
+// idempotent methods, since the request may have been applied.
+func (c *Client) shouldRetry(method string, err error, attempt int) (bool, time.Duration) {
+	if attempt >= c.maxRetries {
+		return false, 0
+	}
+
+	wait := c.backoff(attempt)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests:
+			return true, max(wait, apiErr.RetryAfter)
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return idempotent(method), wait
+		}
+		return false, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return idempotent(method), wait
+	}
+	return false, 0
+}
+
+// backoff returns the exponential delay of the attempt, randomized in its
+// upper half so clients retrying together spread out.
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.retryBase << attempt
+	if wait <= 0 || wait > c.retryMax {
+		wait = c.retryMax
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
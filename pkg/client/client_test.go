@@ -0,0 +1,283 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"segmentify/internal/auth"
+	"segmentify/internal/config"
+	"segmentify/internal/health"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	"segmentify/internal/httpserver/router"
+	"segmentify/internal/metrics"
+	"segmentify/internal/models"
+	"segmentify/internal/storage"
+	"segmentify/pkg/client"
+)
+
+// fakeStorage knows the key "admin", segment A of the default project and
+// user 1. Other storage methods are not used by the tests and panic.
+type fakeStorage struct {
+	router.Storage
+}
+
+func (fakeStorage) GetAPIKeyByHash(_ context.Context, keyHash string) (models.APIKey, error) {
+	if keyHash != auth.Hash("admin") {
+		return models.APIKey{}, &storage.ErrAPIKeyNotFound{}
+	}
+	return models.APIKey{Name: "admin", Role: auth.RoleAdmin}, nil
+}
+
+func (fakeStorage) GetProject(_ context.Context, slug string) (models.Project, error) {
+	if slug != models.DefaultProject {
+		return models.Project{}, &storage.ErrProjectNotFound{Slug: slug}
+	}
+	return models.Project{Slug: slug}, nil
+}
+
+func (fakeStorage) CreateSegment(_ context.Context, _ string, segment models.Segment) (models.Segment, error) {
+	if segment.Slug == "A" {
+		return models.Segment{}, &storage.ErrSegmentExists{Slug: segment.Slug}
+	}
+	return segment, nil
+}
+
+func (fakeStorage) GetSegment(_ context.Context, _, slug string) (models.Segment, error) {
+	if slug != "A" {
+		return models.Segment{}, &storage.ErrSegmentNotFound{Slug: slug}
+	}
+	return models.Segment{Slug: slug, Percent: 10}, nil
+}
+
+func (fakeStorage) CreateUser(context.Context, string) (int64, error) {
+	return 1, nil
+}
+
+func (fakeStorage) GetUserSegments(_ context.Context, _ string, id int64) ([]string, error) {
+	if id != 1 {
+		return nil, &storage.ErrUserNotFound{ID: id}
+	}
+	return []string{"A"}, nil
+}
+
+func (fakeStorage) GetUsersSegments(_ context.Context, _ string, ids []int64) (map[int64][]string, error) {
+	users := map[int64][]string{}
+	for _, id := range ids {
+		if id == 1 {
+			users[id] = []string{"A"}
+		}
+	}
+	return users, nil
+}
+
+func (fakeStorage) UpdateUserSegments(_ context.Context, _ string, _ int64, add []models.SegmentToAdd, _ []models.SegmentToRemove) error {
+	for _, segment := range add {
+		if segment.Slug == "A" {
+			return &storage.ErrUserSegmentExists{Slug: segment.Slug}
+		}
+	}
+	return nil
+}
+
+func (fakeStorage) GetUserSegmentsHistory(context.Context, string, int64, time.Time) ([][]string, error) {
+	return [][]string{{"1", "A", "add", "2023-09-12T15:49:26Z"}}, nil
+}
+
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	return newServerWithStorage(t, fakeStorage{}, wrap)
+}
+
+func newServerWithStorage(t *testing.T, storage router.Storage, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	cfg := &config.Config{Auth: config.Auth{Enabled: true}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var handler http.Handler = router.New(log, cfg, router.Dependencies{
+		Storage: storage,
+		Probes:  health.New(),
+		Metrics: metrics.New(),
+	})
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newClient(server *httptest.Server, apiKey string) *client.Client {
+	return client.New(client.Config{
+		BaseURL:   server.URL,
+		APIKey:    apiKey,
+		RetryBase: time.Millisecond,
+		RetryMax:  time.Millisecond,
+	})
+}
+
+func TestSegments(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil), "admin")
+
+	segment, err := c.CreateSegment(ctx, models.Segment{Slug: "B", Percent: 5})
+	require.NoError(t, err)
+	require.Equal(t, models.Segment{Slug: "B", Percent: 5}, segment)
+
+	segment, err = c.GetSegment(ctx, "A")
+	require.NoError(t, err)
+	require.Equal(t, models.Segment{Slug: "A", Percent: 10}, segment)
+
+	_, err = c.CreateSegment(ctx, models.Segment{Slug: "A"})
+	var errSegmentExists *client.ErrSegmentExists
+	require.ErrorAs(t, err, &errSegmentExists)
+	require.Equal(t, "A", errSegmentExists.Slug)
+
+	_, err = c.GetSegment(ctx, "C")
+	var errSegmentNotFound *client.ErrSegmentNotFound
+	require.ErrorAs(t, err, &errSegmentNotFound)
+	require.Equal(t, "C", errSegmentNotFound.Slug)
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil), "admin")
+
+	user, err := c.CreateUser(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), user.ID)
+
+	segments, err := c.GetUserSegments(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A"}, segments.Segments)
+
+	_, err = c.GetUserSegments(ctx, 2)
+	var errUserNotFound *client.ErrUserNotFound
+	require.ErrorAs(t, err, &errUserNotFound)
+	require.Equal(t, int64(2), errUserNotFound.ID)
+
+	batch, err := c.BatchGetUserSegments(ctx, []int64{1, 2})
+	require.NoError(t, err)
+	require.Equal(t, map[int64][]string{1: {"A"}}, batch.Users)
+	require.Equal(t, []int64{2}, batch.NotFound)
+
+	err = c.UpdateUserSegments(ctx, 1, updateUserSegments.Request{
+		SegmentsToAdd:    []models.SegmentToAdd{{Slug: "A"}},
+		SegmentsToRemove: []models.SegmentToRemove{},
+	})
+	var errUserSegmentExists *client.ErrUserSegmentExists
+	require.ErrorAs(t, err, &errUserSegmentExists)
+
+	history, err := c.DownloadUserSegmentsHistory(ctx, 1, time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"1", "A", "add", "2023-09-12T15:49:26Z"}}, history)
+}
+
+func TestUnauthorized(t *testing.T) {
+	c := newClient(newServer(t, nil), "unknown")
+
+	_, err := c.GetSegment(context.Background(), "A")
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	require.Nil(t, apiErr.Err)
+}
+
+func TestRetries(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		failures int32
+		call     func(c *client.Client) error
+		wantErr  bool
+		wantHits int32
+	}{
+		{
+			name:     "Unavailable GET is retried",
+			status:   http.StatusServiceUnavailable,
+			failures: 2,
+			call: func(c *client.Client) error {
+				_, err := c.GetSegment(context.Background(), "A")
+				return err
+			},
+			wantHits: 3,
+		},
+		{
+			name:     "Unavailable POST is not retried",
+			status:   http.StatusServiceUnavailable,
+			failures: 1,
+			call: func(c *client.Client) error {
+				_, err := c.CreateUser(context.Background())
+				return err
+			},
+			wantErr:  true,
+			wantHits: 1,
+		},
+		{
+			name:     "Rate limited POST is retried",
+			status:   http.StatusTooManyRequests,
+			failures: 1,
+			call: func(c *client.Client) error {
+				_, err := c.CreateUser(context.Background())
+				return err
+			},
+			wantHits: 2,
+		},
+		{
+			name:     "Retries run out",
+			status:   http.StatusServiceUnavailable,
+			failures: 10,
+			call: func(c *client.Client) error {
+				_, err := c.GetSegment(context.Background(), "A")
+				return err
+			},
+			wantErr:  true,
+			wantHits: 4,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var hits atomic.Int32
+			server := newServer(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if hits.Add(1) <= tc.failures {
+						w.Header().Set("Retry-After", "0")
+						w.WriteHeader(tc.status)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+
+			err := tc.call(newClient(server, "admin"))
+			if tc.wantErr {
+				var apiErr *client.APIError
+				require.ErrorAs(t, err, &apiErr)
+				require.Equal(t, tc.status, apiErr.StatusCode)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.wantHits, hits.Load())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"segmentify/internal/storage"
+)
+
+// Typed errors of the API. They are the errors of the storage the server
+// reported, decoded from the response, so errors.As works the same way on
+// both sides:
+//
+//	var errSegmentNotFound *client.ErrSegmentNotFound
+//	if errors.As(err, &errSegmentNotFound) { ... }
+type (
+	ErrSegmentNotFound         = storage.ErrSegmentNotFound
+	ErrSegmentExists           = storage.ErrSegmentExists
+	ErrUserNotFound            = storage.ErrUserNotFound
+	ErrUserSegmentNotFound     = storage.ErrUserSegmentNotFound
+	ErrUserSegmentExists       = storage.ErrUserSegmentExists
+	ErrAPIKeyNotFound          = storage.ErrAPIKeyNotFound
+	ErrProjectNotFound         = storage.ErrProjectNotFound
+	ErrProjectExists           = storage.ErrProjectExists
+	ErrWebhookNotFound         = storage.ErrWebhookNotFound
+	ErrWebhookDeliveryNotFound = storage.ErrWebhookDeliveryNotFound
+)
+
+// APIError is returned for every response with an error status. Err holds
+// the typed error decoded from Detail, if any.
+type APIError struct {
+	StatusCode int
+	Detail     string
+	// RetryAfter is set for 429 responses.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("segmentify: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Detail)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// errorDecoders turn error details back into storage errors. The patterns
+// follow the Error methods in internal/storage/errors.go.
+var errorDecoders = []struct {
+	status int
+	re     *regexp.Regexp
+	decode func(arg string) error
+}{
+	{http.StatusNotFound, regexp.MustCompile(`^segment with slug=(.+) not found$`), func(arg string) error {
+		return &ErrSegmentNotFound{Slug: arg}
+	}},
+	{http.StatusBadRequest, regexp.MustCompile(`^segment with slug=(.+) exists$`), func(arg string) error {
+		return &ErrSegmentExists{Slug: arg}
+	}},
+	{http.StatusNotFound, regexp.MustCompile(`^user with id=(\d+) not found$`), func(arg string) error {
+		return &ErrUserNotFound{ID: parseID(arg)}
+	}},
+	{http.StatusNotFound, regexp.MustCompile(`^user segment with slug=(.+) not found$`), func(arg string) error {
+		return &ErrUserSegmentNotFound{Slug: arg}
+	}},
+	{http.StatusBadRequest, regexp.MustCompile(`^user segment with slug=(.+) exists$`), func(arg string) error {
+		return &ErrUserSegmentExists{Slug: arg}
+	}},
+	{http.StatusNotFound, regexp.MustCompile(`^api key with id=(\d+) not found$`), func(arg string) error {
+		return &ErrAPIKeyNotFound{ID: parseID(arg)}
+	}},
+	{http.StatusNotFound, regexp.MustCompile(`^project with slug=(.+) not found$`), func(arg string) error {
+		return &ErrProjectNotFound{Slug: arg}
+	}},
+	{http.StatusConflict, regexp.MustCompile(`^project with slug=(.+) exists$`), func(arg string) error {
+		return &ErrProjectExists{Slug: arg}
+	}},
+	{http.StatusNotFound, regexp.MustCompile(`^webhook with id=(\d+) not found$`), func(arg string) error {
+		return &ErrWebhookNotFound{ID: parseID(arg)}
+	}},
+	{http.StatusNotFound, regexp.MustCompile(`^(?:dead )?webhook delivery with id=(\d+) not found$`), func(arg string) error {
+		return &ErrWebhookDeliveryNotFound{ID: parseID(arg)}
+	}},
+}
+
+func decodeError(status int, detail string) error {
+	for _, d := range errorDecoders {
+		if d.status != status {
+			continue
+		}
+		if m := d.re.FindStringSubmatch(detail); m != nil {
+			return d.decode(m[1])
+		}
+	}
+	return nil
+}
+
+func parseID(s string) int64 {
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	eventChanges "segmentify/internal/httpserver/handlers/events/changes"
+	"segmentify/internal/models"
+)
+
+// EventsFilter selects the events of a stream. Zero fields are not applied.
+type EventsFilter struct {
+	UserID  int64
+	Segment string
+	// LastEventID resumes the stream after this event. Without it the
+	// stream starts with new events.
+	LastEventID int64
+	// Resume resumes the stream after LastEventID even if it is zero, that
+	// is from the first event. It is set once an event was handled.
+	Resume bool
+}
+
+// StreamEvents calls handle for every event of the project until ctx is done
+// or handle returns an error, which is then returned. Dropped connections
+// are reopened after the last handled event, so no event is lost or handled
+// twice. Only the ID, type, project, event ID and payload of events are set.
+func (c *Client) StreamEvents(ctx context.Context, filter EventsFilter, handle func(models.OutboxEvent) error) error {
+	for attempt := 0; ; attempt++ {
+		handled, err := c.stream(ctx, &filter, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var handlerErr *handlerError
+		if errors.As(err, &handlerErr) {
+			return handlerErr.err
+		}
+
+		// A connection that delivered events starts retries anew
+		if handled {
+			attempt = 0
+		}
+
+		retry, wait := c.shouldRetry(http.MethodGet, err, attempt)
+		if !retry {
+			return err
+		}
+		if !sleep(ctx, wait) {
+			return ctx.Err()
+		}
+	}
+}
+
+// GetChanges returns up to limit events of the project after the event with
+// the given ID, oldest first. A zero limit uses the server default. Pass
+// LastEventID of the response to get the next changes.
+func (c *Client) GetChanges(ctx context.Context, afterID, limit int64) (eventChanges.Response, error) {
+	query := url.Values{"after": {strconv.FormatInt(afterID, 10)}}
+	if limit != 0 {
+		query.Set("limit", strconv.FormatInt(limit, 10))
+	}
+
+	var changes eventChanges.Response
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/events/changes"),
+		query:  query,
+		out:    &changes,
+	})
+	return changes, err
+}
+
+// GetLastEventID returns the ID of the latest event, to get changes from now on.
+func (c *Client) GetLastEventID(ctx context.Context) (int64, error) {
+	var changes eventChanges.Response
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/events/changes"),
+		out:    &changes,
+	})
+	return changes.LastEventID, err
+}
+
+// handlerError marks errors of the event handler, which end the stream.
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// stream reads one connection of the event stream. It advances the last event
+// ID of the filter and reports whether any event was handled.
+func (c *Client) stream(ctx context.Context, filter *EventsFilter, handle func(models.OutboxEvent) error) (bool, error) {
+	query := url.Values{}
+	if filter.UserID != 0 {
+		query.Set("user_id", strconv.FormatInt(filter.UserID, 10))
+	}
+	if filter.Segment != "" {
+		query.Set("segment", filter.Segment)
+	}
+	if filter.Resume || filter.LastEventID != 0 {
+		query.Set("last_event_id", strconv.FormatInt(filter.LastEventID, 10))
+	}
+
+	res, err := c.send(ctx, http.MethodGet, c.projectPath("/events"), query, nil)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(res.Body)
+		return false, newAPIError(res, data)
+	}
+
+	handled := false
+	event := models.OutboxEvent{}
+	var data strings.Builder
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+
+			event.Payload = json.RawMessage(data.String())
+
+			var meta struct {
+				ID      string `json:"id"`
+				Project string `json:"project"`
+			}
+			if err := json.Unmarshal(event.Payload, &meta); err == nil {
+				event.EventID = meta.ID
+				event.Project = meta.Project
+			}
+
+			if err := handle(event); err != nil {
+				return handled, &handlerError{err: err}
+			}
+			handled = true
+			filter.LastEventID = event.ID
+			filter.Resume = true
+
+			event = models.OutboxEvent{}
+			data.Reset()
+			continue
+		}
+
+		// Comments are heartbeats
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "id":
+			if event.ID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return handled, fmt.Errorf("segmentify: invalid event id %q", value)
+			}
+		case "event":
+			event.Type = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return handled, fmt.Errorf("segmentify: read event stream: %w", err)
+	}
+
+	return handled, io.ErrUnexpectedEOF
+}
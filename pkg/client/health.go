@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"segmentify/internal/health"
+)
+
+// Ready checks whether the service can serve requests. A service that is not
+// ready is not an error, its report has a status other than health.StatusOK.
+// Probes are not retried.
+func (c *Client) Ready(ctx context.Context) (health.Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := c.send(ctx, http.MethodGet, "/readyz", nil, nil)
+	if err != nil {
+		return health.Report{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusServiceUnavailable {
+		return health.Report{}, &APIError{StatusCode: res.StatusCode, Detail: http.StatusText(res.StatusCode)}
+	}
+
+	var report health.Report
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		return health.Report{}, fmt.Errorf("segmentify: decode response: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"segmentify/internal/models"
+)
+
+func (c *Client) CreateSegment(ctx context.Context, segment models.Segment) (models.Segment, error) {
+	var created models.Segment
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   c.projectPath("/segments"),
+		body:   segment,
+		out:    &created,
+	})
+	return created, err
+}
+
+func (c *Client) GetSegment(ctx context.Context, slug string) (models.Segment, error) {
+	var segment models.Segment
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/segments/" + url.PathEscape(slug)),
+		out:    &segment,
+	})
+	return segment, err
+}
+
+// GetSegmentStats returns the members of the segment and its history for the
+// days from from to to. Zero times leave the range to the server, which
+// returns the last 30 days.
+func (c *Client) GetSegmentStats(ctx context.Context, slug string, from, to time.Time) (models.SegmentStats, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.DateOnly))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.DateOnly))
+	}
+
+	var stats models.SegmentStats
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/segments/" + url.PathEscape(slug) + "/stats"),
+		query:  query,
+		out:    &stats,
+	})
+	return stats, err
+}
+
+func (c *Client) DeleteSegment(ctx context.Context, slug string) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   c.projectPath("/segments/" + url.PathEscape(slug)),
+	})
+}
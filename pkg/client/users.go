@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	batchGetUsersSegments "segmentify/internal/httpserver/handlers/users/batchget"
+	createUser "segmentify/internal/httpserver/handlers/users/create"
+	getUserSegments "segmentify/internal/httpserver/handlers/users/get"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+)
+
+func (c *Client) CreateUser(ctx context.Context) (createUser.Response, error) {
+	var user createUser.Response
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   c.projectPath("/users"),
+		out:    &user,
+	})
+	return user, err
+}
+
+func (c *Client) GetUserSegments(ctx context.Context, userID int64) (getUserSegments.Response, error) {
+	var segments getUserSegments.Response
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath(userPath(userID, "/segments")),
+		out:    &segments,
+	})
+	return segments, err
+}
+
+// BatchGetUserSegments returns the segments of many users in one request.
+// Unknown users are listed in NotFound of the response.
+func (c *Client) BatchGetUserSegments(ctx context.Context, userIDs []int64) (batchGetUsersSegments.Response, error) {
+	var users batchGetUsersSegments.Response
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   c.projectPath("/users/segments:batchGet"),
+		body:   batchGetUsersSegments.Request{IDs: userIDs},
+		out:    &users,
+	})
+	return users, err
+}
+
+func (c *Client) UpdateUserSegments(ctx context.Context, userID int64, update updateUserSegments.Request) error {
+	return c.do(ctx, request{
+		method: http.MethodPatch,
+		path:   c.projectPath(userPath(userID, "/segments")),
+		body:   update,
+	})
+}
+
+// DownloadUserSegmentsHistory returns the history of the user's segments in
+// the month of period as CSV records of user ID, segment, operation and time.
+func (c *Client) DownloadUserSegmentsHistory(ctx context.Context, userID int64, period time.Time) ([][]string, error) {
+	var data []byte
+	if err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath(userPath(userID, "/download-segments-history")),
+		query:  url.Values{"period": {period.Format("2006-01")}},
+		raw:    &data,
+	}); err != nil {
+		return nil, err
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("segmentify: decode history: %w", err)
+	}
+	return records, nil
+}
+
+func userPath(userID int64, path string) string {
+	return "/users/" + strconv.FormatInt(userID, 10) + path
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	createWebhook "segmentify/internal/httpserver/handlers/webhooks/create"
+	"segmentify/internal/models"
+)
+
+// CreateWebhook subscribes a webhook. The returned webhook holds its secret,
+// which is not returned again.
+func (c *Client) CreateWebhook(ctx context.Context, webhook createWebhook.Request) (models.Webhook, error) {
+	var created models.Webhook
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   c.projectPath("/webhooks"),
+		body:   webhook,
+		out:    &created,
+	})
+	return created, err
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/webhooks"),
+		out:    &webhooks,
+	})
+	return webhooks, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   c.projectPath("/webhooks/" + strconv.FormatInt(id, 10)),
+	})
+}
+
+// ListWebhookDeliveries returns the latest deliveries first. Zero fields of
+// the filter are not applied.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	query := url.Values{}
+	if filter.WebhookID != 0 {
+		query.Set("webhook_id", strconv.FormatInt(filter.WebhookID, 10))
+	}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.FormatInt(filter.Limit, 10))
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/webhooks/deliveries"),
+		query:  query,
+		out:    &deliveries,
+	})
+	return deliveries, err
+}
+
+func (c *Client) GetWebhookDelivery(ctx context.Context, id int64) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   c.projectPath("/webhooks/deliveries/" + strconv.FormatInt(id, 10)),
+		out:    &delivery,
+	})
+	return delivery, err
+}
+
+// RetryWebhookDelivery schedules a dead delivery for another attempt.
+func (c *Client) RetryWebhookDelivery(ctx context.Context, id int64) error {
+	return c.do(ctx, request{
+		method: http.MethodPost,
+		path:   c.projectPath("/webhooks/deliveries/" + strconv.FormatInt(id, 10) + "/retry"),
+	})
+}
@@ -0,0 +1,65 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	listAudit "segmentify/internal/httpserver/handlers/audit/list"
+	"segmentify/internal/models"
+)
+
+func TestAuditLog(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	e.POST("/segments").
+		WithJSON(models.Segment{Slug: "A", Percent: 10}).
+		Expect().
+		Status(http.StatusCreated)
+	e.DELETE("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusNoContent)
+
+	var page listAudit.Response
+	e.GET("/audit").
+		WithQuery("entity", "segment").
+		WithQuery("entity_id", "A").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&page)
+
+	require.Len(t, page.Entries, 2)
+
+	deleted, created := page.Entries[0], page.Entries[1]
+	require.Equal(t, "delete", deleted.Action)
+	require.JSONEq(t, `{"slug":"A","percent":10}`, string(deleted.Before))
+	require.Empty(t, deleted.After)
+	require.Equal(t, "create", created.Action)
+	require.Empty(t, created.Before)
+	require.JSONEq(t, `{"slug":"A","percent":10}`, string(created.After))
+	require.Equal(t, "bootstrap", created.Actor)
+	require.Equal(t, models.DefaultProject, created.Project)
+	require.NotEmpty(t, created.RequestID)
+
+	// Pagination walks the log newest first
+	e.GET("/audit").
+		WithQuery("entity", "segment").
+		WithQuery("limit", 1).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&page)
+	require.Len(t, page.Entries, 1)
+	require.Equal(t, deleted.ID, page.Entries[0].ID)
+	require.Equal(t, deleted.ID, page.NextCursor)
+
+	e.GET("/audit").
+		WithQuery("entity", "segment").
+		WithQuery("limit", 1).
+		WithQuery("cursor", page.NextCursor).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&page)
+	require.Equal(t, created.ID, page.Entries[0].ID)
+}
@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/require"
+
+	createAPIKey "segmentify/internal/httpserver/handlers/apikeys/create"
+	"segmentify/internal/models"
+)
+
+func TestAPIKeyRoles(t *testing.T) {
+	cleanDB(t)
+	admin := newExpect(t)
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	anonymous := httpexpect.Default(t, u.String())
+
+	// Requests without a key are rejected
+	anonymous.GET("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// Creating a reader key
+	var keyResp createAPIKey.Response
+	admin.POST("/admin/api-keys").
+		WithJSON(createAPIKey.Request{Name: "reader", Role: "reader"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Decode(&keyResp)
+	require.NotEmpty(t, keyResp.Key)
+
+	reader := anonymous.Builder(func(req *httpexpect.Request) {
+		req.WithHeader("X-API-Key", keyResp.Key)
+	})
+
+	admin.POST("/segments").
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	// A reader can read but not create or delete segments
+	reader.GET("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusOK)
+	reader.POST("/segments").
+		WithJSON(models.Segment{Slug: "B"}).
+		Expect().
+		Status(http.StatusForbidden)
+	reader.DELETE("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusForbidden)
+
+	// A revoked key is rejected
+	admin.DELETE("/admin/api-keys/{id}", keyResp.ID).
+		Expect().
+		Status(http.StatusNoContent)
+	reader.GET("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusUnauthorized)
+}
@@ -0,0 +1,285 @@
+package integration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	eventChanges "segmentify/internal/httpserver/handlers/events/changes"
+	createUser "segmentify/internal/httpserver/handlers/users/create"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	"segmentify/internal/models"
+)
+
+type streamEvent struct {
+	id      int64
+	payload models.MembershipEvent
+}
+
+// openEventStream connects to GET /events and sends received events to the
+// returned channel until ctx is done.
+func openEventStream(ctx context.Context, t *testing.T, query url.Values, lastEventID int64) <-chan streamEvent {
+	t.Helper()
+
+	u := url.URL{Scheme: "http", Host: host, Path: "/events", RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", adminKey)
+	if lastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan streamEvent, 10)
+
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		var event streamEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.id, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.payload)
+			case line == "" && event.id != 0:
+				events <- event
+				event = streamEvent{}
+			}
+		}
+	}()
+
+	return events
+}
+
+func receiveEvent(t *testing.T, events <-chan streamEvent) streamEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "event stream is closed")
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("event was not streamed")
+		return streamEvent{}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, slug := range []string{"A", "B"} {
+		e.POST("/segments").
+			WithJSON(models.Segment{Slug: slug}).
+			Expect().
+			Status(http.StatusCreated)
+	}
+
+	var user, other createUser.Response
+	for _, u := range []*createUser.Response{&user, &other} {
+		e.POST("/users").
+			Expect().
+			Status(http.StatusCreated).
+			JSON().Object().Decode(u)
+	}
+
+	stream := openEventStream(ctx, t, url.Values{"user_id": {strconv.FormatInt(user.ID, 10)}}, 0)
+
+	// Changes of the other user are filtered out
+	for _, id := range []int64{other.ID, user.ID} {
+		e.PATCH("/users/{id}/segments", id).
+			WithJSON(updateUserSegments.Request{
+				SegmentsToAdd:    []models.SegmentToAdd{{Slug: "A"}, {Slug: "B"}},
+				SegmentsToRemove: []models.SegmentToRemove{},
+			}).
+			Expect().
+			Status(http.StatusNoContent)
+	}
+
+	first := receiveEvent(t, stream)
+	require.Equal(t, models.EventUserAdded, first.payload.Type)
+	require.Equal(t, user.ID, first.payload.UserID)
+	require.Equal(t, "A", first.payload.Segment)
+
+	second := receiveEvent(t, stream)
+	require.Equal(t, user.ID, second.payload.UserID)
+	require.Equal(t, "B", second.payload.Segment)
+	require.Greater(t, second.id, first.id)
+
+	// Resuming after the first event replays the second one
+	resumed := openEventStream(ctx, t, url.Values{"user_id": {strconv.FormatInt(user.ID, 10)}}, first.id)
+
+	replayed := receiveEvent(t, resumed)
+	require.Equal(t, second.id, replayed.id)
+	require.Equal(t, second.payload.ID, replayed.payload.ID)
+}
+
+func TestEventChanges(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	// Without after only the cursor is returned
+	var start eventChanges.Response
+	e.GET("/events/changes").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&start)
+	require.Empty(t, start.Events)
+
+	for _, slug := range []string{"A", "B"} {
+		e.POST("/segments").
+			WithJSON(models.Segment{Slug: slug}).
+			Expect().
+			Status(http.StatusCreated)
+	}
+
+	var changes eventChanges.Response
+	e.GET("/events/changes").
+		WithQuery("after", start.LastEventID).
+		WithQuery("limit", 1).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&changes)
+	require.Len(t, changes.Events, 1)
+	require.Equal(t, models.EventSegmentCreated, changes.Events[0].Type)
+	require.Equal(t, changes.Events[0].ID, changes.LastEventID)
+
+	e.GET("/events/changes").
+		WithQuery("after", changes.LastEventID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("events").Array().Length().IsEqual(1)
+}
+
+func TestDeleteSegmentEvents(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	e.POST("/segments").
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	var user createUser.Response
+	e.POST("/users").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Decode(&user)
+
+	e.PATCH("/users/{id}/segments", user.ID).
+		WithJSON(updateUserSegments.Request{
+			SegmentsToAdd:    []models.SegmentToAdd{{Slug: "A"}},
+			SegmentsToRemove: []models.SegmentToRemove{},
+		}).
+		Expect().
+		Status(http.StatusNoContent)
+
+	var start eventChanges.Response
+	e.GET("/events/changes").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&start)
+
+	e.DELETE("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusNoContent)
+
+	// Members are removed before the segment is deleted
+	var changes eventChanges.Response
+	e.GET("/events/changes").
+		WithQuery("after", start.LastEventID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&changes)
+	require.Len(t, changes.Events, 2)
+	require.Equal(t, models.EventUserRemoved, changes.Events[0].Type)
+	require.Equal(t, models.EventSegmentDeleted, changes.Events[1].Type)
+
+	var removed models.MembershipEvent
+	require.NoError(t, json.Unmarshal(changes.Events[0].Payload, &removed))
+	require.Equal(t, user.ID, removed.UserID)
+	require.Equal(t, "A", removed.Segment)
+	require.Equal(t, models.ReasonSegmentDeleted, removed.Reason)
+}
+
+func TestEventChangesCommitOrder(t *testing.T) {
+	ctx := context.Background()
+	e := newExpect(t)
+
+	var start eventChanges.Response
+	e.GET("/events/changes").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&start)
+
+	insert := func(tx pgx.Tx, segment string) {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO outbox(event_id, event_type, project_slug, payload)
+			SELECT e.id, $1, 'default', jsonb_build_object('id', e.id, 'type', $1::text, 'segment', $2::text)
+			FROM (SELECT gen_random_uuid() AS id) AS e
+		`, models.EventSegmentCreated, segment)
+		require.NoError(t, err)
+	}
+
+	begin := func() pgx.Tx {
+		conn, err := pgx.Connect(ctx, PGURL)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close(ctx) })
+
+		tx, err := conn.Begin(ctx)
+		require.NoError(t, err)
+		return tx
+	}
+
+	// A takes the lower id but commits after B
+	a, b := begin(), begin()
+	insert(a, "A")
+	insert(b, "B")
+	require.NoError(t, b.Commit(ctx))
+
+	segment := func(event models.OutboxEvent) string {
+		var payload models.SegmentEvent
+		require.NoError(t, json.Unmarshal(event.Payload, &payload))
+		return payload.Segment
+	}
+
+	var changes eventChanges.Response
+	e.GET("/events/changes").
+		WithQuery("after", start.LastEventID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&changes)
+	require.Len(t, changes.Events, 1)
+	require.Equal(t, "B", segment(changes.Events[0]))
+
+	require.NoError(t, a.Commit(ctx))
+
+	// Resuming after B still gets A
+	e.GET("/events/changes").
+		WithQuery("after", changes.LastEventID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&changes)
+	require.Len(t, changes.Events, 1)
+	require.Equal(t, "A", segment(changes.Events[0]))
+}
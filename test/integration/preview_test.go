@@ -0,0 +1,88 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	"segmentify/internal/models"
+)
+
+func TestDryRun(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	// Four users, no segments
+	var usersIDs []int64
+	for i := 0; i < 4; i++ {
+		var userResp map[string]int64
+		e.POST("/users").
+			Expect().
+			Status(http.StatusCreated).
+			JSON().Object().Decode(&userResp)
+		usersIDs = append(usersIDs, userResp["id"])
+	}
+
+	// Previewing a segment for half of them
+	var preview models.Preview
+	e.POST("/segments").
+		WithQuery("dry_run", true).
+		WithJSON(models.Segment{Slug: "A", Percent: 50}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&preview)
+	require.Equal(t, int64(2), preview.UsersAffectedCount)
+	require.Len(t, preview.UsersAffected, 2)
+	require.Equal(t, int64(2), preview.Added)
+	require.Equal(t, map[string]int64{"A": 2}, preview.SegmentSizes)
+	require.Empty(t, preview.Conflicts)
+
+	// Nothing was created
+	e.GET("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/segments").
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	// Previewing the creation of an existing segment reports a conflict
+	e.POST("/segments").
+		WithQuery("dry_run", true).
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&preview)
+	require.Len(t, preview.Conflicts, 1)
+
+	// Previewing an update with a missing segment and membership
+	e.PATCH("/users/{id}/segments", usersIDs[0]).
+		WithQuery("dry_run", true).
+		WithJSON(updateUserSegments.Request{
+			SegmentsToAdd:    []models.SegmentToAdd{{Slug: "A"}, {Slug: "B"}},
+			SegmentsToRemove: []models.SegmentToRemove{{Slug: "C"}},
+		}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&preview)
+	require.Equal(t, []int64{usersIDs[0]}, preview.UsersAffected)
+	require.Equal(t, int64(1), preview.Added)
+	require.Equal(t, int64(1), preview.SegmentSizes["A"])
+	require.Len(t, preview.Conflicts, 2)
+
+	// Nothing was changed
+	e.GET("/users/{id}/segments", usersIDs[0]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("segments").Array().IsEmpty()
+
+	// Unknown users are still not found
+	e.PATCH("/users/{id}/segments", usersIDs[3]+1).
+		WithQuery("dry_run", true).
+		WithJSON(updateUserSegments.Request{SegmentsToAdd: []models.SegmentToAdd{{Slug: "A"}}, SegmentsToRemove: []models.SegmentToRemove{}}).
+		Expect().
+		Status(http.StatusNotFound)
+}
@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/require"
+
+	createAPIKey "segmentify/internal/httpserver/handlers/apikeys/create"
+	createUser "segmentify/internal/httpserver/handlers/users/create"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	"segmentify/internal/models"
+)
+
+func TestProjectIsolation(t *testing.T) {
+	cleanDB(t)
+	admin := newExpect(t)
+
+	admin.POST("/projects").
+		WithJSON(models.Project{Slug: "mobile", Name: "Mobile app"}).
+		Expect().
+		Status(http.StatusCreated)
+	admin.POST("/projects").
+		WithJSON(models.Project{Slug: "mobile", Name: "Mobile app"}).
+		Expect().
+		Status(http.StatusConflict)
+
+	// The same slug can exist in different projects
+	admin.POST("/segments").
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusCreated)
+	admin.POST("/projects/{project}/segments", "mobile").
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusCreated)
+	admin.POST("/projects/{project}/segments", "mobile").
+		WithJSON(models.Segment{Slug: "B"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	// Segments of another project are not visible
+	admin.GET("/segments/{slug}", "B").
+		Expect().
+		Status(http.StatusNotFound)
+	admin.GET("/projects/{project}/segments/{slug}", "nowhere", "A").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// Users can only be added to segments of their own project
+	var user createUser.Response
+	admin.POST("/users").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Decode(&user)
+
+	admin.PATCH("/users/{id}/segments", user.ID).
+		WithJSON(updateUserSegments.Request{
+			SegmentsToAdd:    []models.SegmentToAdd{{Slug: "B"}},
+			SegmentsToRemove: []models.SegmentToRemove{},
+		}).
+		Expect().
+		Status(http.StatusNotFound)
+	admin.GET("/projects/{project}/users/{id}/segments", "mobile", user.ID).
+		Expect().
+		Status(http.StatusNotFound)
+
+	// A key bound to a project can not access other projects or admin routes
+	var keyResp createAPIKey.Response
+	admin.POST("/admin/api-keys").
+		WithJSON(createAPIKey.Request{Name: "mobile", Role: "admin", Project: "mobile"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Decode(&keyResp)
+	require.Equal(t, "mobile", keyResp.Project)
+
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	mobile := httpexpect.Default(t, u.String()).Builder(func(req *httpexpect.Request) {
+		req.WithHeader("X-API-Key", keyResp.Key)
+	})
+
+	mobile.GET("/projects/{project}/segments/{slug}", "mobile", "B").
+		Expect().
+		Status(http.StatusOK)
+	mobile.GET("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusForbidden)
+	mobile.GET("/admin/api-keys").
+		Expect().
+		Status(http.StatusForbidden)
+
+	// Deleting a project removes its segments and keys
+	admin.DELETE("/projects/{project}", models.DefaultProject).
+		Expect().
+		Status(http.StatusBadRequest)
+	admin.DELETE("/projects/{project}", "mobile").
+		Expect().
+		Status(http.StatusNoContent)
+	mobile.GET("/projects/{project}/segments/{slug}", "mobile", "B").
+		Expect().
+		Status(http.StatusUnauthorized)
+	admin.GET("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusOK)
+}
@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	eventChanges "segmentify/internal/httpserver/handlers/events/changes"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	"segmentify/internal/models"
+	"segmentify/internal/snapshot"
+)
+
+func TestSnapshot(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	// A user in segment A
+	e.POST("/segments").
+		WithJSON(models.Segment{Slug: "A"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	var userResp map[string]int64
+	e.POST("/users").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Decode(&userResp)
+	userID := userResp["id"]
+
+	e.PATCH("/users/{id}/segments", userID).
+		WithJSON(updateUserSegments.Request{SegmentsToAdd: []models.SegmentToAdd{{Slug: "A"}}}).
+		Expect().
+		Status(http.StatusNoContent)
+
+	// Exporting
+	archive := []byte(e.GET("/admin/snapshot").
+		Expect().
+		Status(http.StatusOK).
+		ContentType("application/zip").
+		Body().Raw())
+
+	data, err := snapshot.Read(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), data.Manifest.Counts[snapshot.TableSegments])
+	require.Equal(t, int64(1), data.Manifest.Counts[snapshot.TableUsers])
+	require.Equal(t, int64(1), data.Manifest.Counts[snapshot.TableMemberships])
+	require.Equal(t, int64(1), data.Manifest.Counts[snapshot.TableHistory])
+
+	// Deleting the segment drops the membership and history
+	e.DELETE("/segments/{slug}", "A").
+		Expect().
+		Status(http.StatusNoContent)
+
+	var start eventChanges.Response
+	e.GET("/events/changes").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&start)
+
+	// Importing brings them back
+	var res snapshot.Result
+	e.POST("/admin/snapshot").
+		WithQuery("mode", "upsert").
+		WithBytes(archive).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&res)
+	require.Equal(t, int64(1), res.Tables[snapshot.TableSegments].Inserted)
+	require.Equal(t, int64(1), res.Tables[snapshot.TableMemberships].Inserted)
+	require.Empty(t, res.Conflicts)
+
+	e.GET("/users/{id}/segments", userID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("segments").Array().ContainsOnly("A")
+
+	// The import is announced by one event per project
+	var changes eventChanges.Response
+	e.GET("/events/changes").
+		WithQuery("after", start.LastEventID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&changes)
+	require.Len(t, changes.Events, 1)
+	require.Equal(t, models.EventSnapshotImported, changes.Events[0].Type)
+	require.Equal(t, "default", changes.Events[0].Project)
+
+	// Importing again changes nothing
+	e.POST("/admin/snapshot").
+		WithBytes(archive).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&res)
+	require.Equal(t, snapshot.TableResult{}, res.Tables[snapshot.TableMemberships])
+	require.Equal(t, snapshot.TableResult{}, res.Tables[snapshot.TableHistory])
+
+	// Replacing deletes first
+	e.POST("/admin/snapshot").
+		WithQuery("mode", "replace").
+		WithBytes(archive).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&res)
+	require.Equal(t, snapshot.TableResult{Inserted: 1, Deleted: 1}, res.Tables[snapshot.TableUsers])
+
+	// Invalid archives and modes are rejected
+	e.POST("/admin/snapshot").
+		WithBytes([]byte("not a zip")).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/admin/snapshot").
+		WithQuery("mode", "merge").
+		WithBytes(archive).
+		Expect().
+		Status(http.StatusBadRequest)
+}
@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	"segmentify/internal/models"
+)
+
+func TestSegmentStats(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	// Four users, half of them in segment A
+	var usersIDs []int64
+	for i := 0; i < 4; i++ {
+		var userResp map[string]int64
+		e.POST("/users").
+			Expect().
+			Status(http.StatusCreated).
+			JSON().Object().Decode(&userResp)
+		usersIDs = append(usersIDs, userResp["id"])
+	}
+
+	e.POST("/segments").
+		WithJSON(models.Segment{Slug: "A", Percent: 50}).
+		Expect().
+		Status(http.StatusCreated)
+
+	// Adding one more with a TTL and removing one
+	var added, removed int64
+	for _, id := range usersIDs {
+		var resp map[string][]string
+		e.GET("/users/{id}/segments", id).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().Decode(&resp)
+
+		switch {
+		case len(resp["segments"]) == 0 && added == 0:
+			e.PATCH("/users/{id}/segments", id).
+				WithJSON(updateUserSegments.Request{SegmentsToAdd: []models.SegmentToAdd{{Slug: "A", ExpireAt: time.Now().Add(time.Hour)}}, SegmentsToRemove: []models.SegmentToRemove{}}).
+				Expect().
+				Status(http.StatusNoContent)
+			added++
+		case len(resp["segments"]) == 1 && removed == 0:
+			e.PATCH("/users/{id}/segments", id).
+				WithJSON(updateUserSegments.Request{SegmentsToAdd: []models.SegmentToAdd{}, SegmentsToRemove: []models.SegmentToRemove{{Slug: "A"}}}).
+				Expect().
+				Status(http.StatusNoContent)
+			removed++
+		}
+	}
+
+	var stats models.SegmentStats
+	e.GET("/segments/{slug}/stats", "A").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&stats)
+	require.Equal(t, int64(50), stats.Percent)
+	require.Equal(t, int64(2), stats.Members)
+	require.Equal(t, int64(1), stats.MembersWithTTL)
+	require.Equal(t, int64(4), stats.Users)
+	require.Equal(t, 50.0, stats.ActualPercent)
+	require.Len(t, stats.Days, 30)
+
+	today := stats.Days[len(stats.Days)-1]
+	require.Equal(t, models.SegmentDay{Date: today.Date, Added: 3, Removed: 1, Size: 2}, today)
+	require.Equal(t, int64(0), stats.Days[0].Size)
+
+	// Expiring the membership with a TTL is recorded in the history too
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, PGURL)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `
+		UPDATE users_segments
+		SET expire_at = NOW() - INTERVAL '1 second'
+		WHERE expire_at IS NOT NULL
+	`)
+	require.NoError(t, err)
+
+	e.POST("/admin/jobs/{name}/run", "expire").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/segments/{slug}/stats", "A").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Decode(&stats)
+	require.Equal(t, int64(1), stats.Members)
+
+	today = stats.Days[len(stats.Days)-1]
+	require.Equal(t, models.SegmentDay{Date: today.Date, Added: 3, Removed: 2, Size: 1}, today)
+
+	// Unknown segments and invalid ranges
+	e.GET("/segments/{slug}/stats", "B").
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET("/segments/{slug}/stats", "A").
+		WithQuery("from", "2023-09-30").
+		WithQuery("to", "2023-09-01").
+		Expect().
+		Status(http.StatusBadRequest)
+}
@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	createUser "segmentify/internal/httpserver/handlers/users/create"
+	updateUserSegments "segmentify/internal/httpserver/handlers/users/update"
+	createWebhook "segmentify/internal/httpserver/handlers/webhooks/create"
+	"segmentify/internal/models"
+	"segmentify/internal/webhooks"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	cleanDB(t)
+	e := newExpect(t)
+
+	const secret = "integration-secret"
+
+	events := make(chan models.MembershipEvent, 10)
+
+	// The app runs in Docker, so the receiver listens on all interfaces and
+	// is addressed through host.docker.internal
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	require.NoError(t, err)
+	receiver := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		if !webhooks.Verify(secret, timestamp, body, r.Header.Get(webhooks.HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var event models.MembershipEvent
+		require.NoError(t, json.Unmarshal(body, &event))
+		events <- event
+	}))
+	receiver.Listener.Close()
+	receiver.Listener = listener
+	receiver.Start()
+	defer receiver.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	e.POST("/webhooks").
+		WithJSON(createWebhook.Request{
+			URL:      "http://host.docker.internal:" + strconv.Itoa(port),
+			Secret:   secret,
+			Segments: []string{"A"},
+		}).
+		Expect().
+		Status(http.StatusCreated)
+
+	for _, slug := range []string{"A", "B"} {
+		e.POST("/segments").
+			WithJSON(models.Segment{Slug: slug}).
+			Expect().
+			Status(http.StatusCreated)
+	}
+
+	var user createUser.Response
+	e.POST("/users").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Decode(&user)
+
+	e.PATCH("/users/{id}/segments", user.ID).
+		WithJSON(updateUserSegments.Request{
+			SegmentsToAdd:    []models.SegmentToAdd{{Slug: "A"}, {Slug: "B"}},
+			SegmentsToRemove: []models.SegmentToRemove{},
+		}).
+		Expect().
+		Status(http.StatusNoContent)
+
+	select {
+	case event := <-events:
+		require.Equal(t, models.EventUserAdded, event.Type)
+		require.Equal(t, "A", event.Segment)
+		require.Equal(t, user.ID, event.UserID)
+		require.Equal(t, models.ReasonManual, event.Reason)
+		require.Equal(t, models.DefaultProject, event.Project)
+	case <-time.After(10 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	// Segment B is filtered out
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event for segment %s", event.Segment)
+	case <-time.After(2 * time.Second):
+	}
+}